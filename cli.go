@@ -0,0 +1,474 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"oppgaave/internal/database"
+	"oppgaave/internal/format"
+	"oppgaave/internal/ical"
+	"oppgaave/internal/models"
+)
+
+const taskUsage = "usage: oppgaave task add <title> | list [--status S] | done <id>"
+
+// openDB opens the database for a command, keeping the startup log lines out of its output
+func openDB(dbPath string) (*database.DB, error) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	return database.New(dbPath)
+}
+
+// parseArgs parses flags that may appear before, between or after the
+// positional arguments, which it returns
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// splitFlag splits a comma-separated flag value, dropping empty items
+func splitFlag(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printTasks writes tasks as a table
+func printTasks(tasks []models.Task) error {
+	if len(tasks) == 0 {
+		fmt.Println("No tasks")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tPRIORITY\tDURATION\tCOST\tDEADLINE\tTITLE")
+	for _, t := range tasks {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.ID, t.Status, format.PriorityText(t.Priority),
+			format.Duration(t.EstimatedDurationMins), format.Currency(t.MoneyCost),
+			format.Date(t.Deadline), t.Title)
+	}
+	return tw.Flush()
+}
+
+// runTask handles the task subcommands
+func runTask(dbPath string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(taskUsage)
+	}
+
+	switch args[0] {
+	case "add":
+		return runTaskAdd(dbPath, args[1:])
+	case "list":
+		return runTaskList(dbPath, args[1:])
+	case "done":
+		return runTaskDone(dbPath, args[1:])
+	}
+	return errors.New(taskUsage)
+}
+
+// runTaskAdd creates a task from the command line
+func runTaskAdd(dbPath string, args []string) error {
+	fs := flag.NewFlagSet("task add", flag.ContinueOnError)
+	description := fs.String("description", "", "longer description")
+	duration := fs.Int("duration", 30, "estimated minutes")
+	priority := fs.Int("priority", 2, "priority 1-3")
+	energy := fs.Int("energy", 2, "energy needed 1-3")
+	difficulty := fs.Int("difficulty", 2, "difficulty 1-3")
+	deadline := fs.String("deadline", "", "deadline as YYYY-MM-DD, today or tomorrow")
+	tags := fs.String("tags", "", "comma-separated tags")
+	parent := fs.Int("parent", 0, "ID of the parent task")
+	repeat := fs.String("repeat", "", "recurrence: daily, every N days, weekdays, weekly:mon,thu, monthly:15|last or after:N")
+	asJSON := fs.Bool("json", false, "print the task as JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	title := strings.TrimSpace(strings.Join(positional, " "))
+	if title == "" {
+		return errors.New("usage: oppgaave task add <title> [flags]")
+	}
+
+	req := &models.CreateTaskRequest{
+		Title:                 title,
+		Description:           *description,
+		EstimatedDurationMins: *duration,
+		Priority:              *priority,
+		EnergyLevel:           *energy,
+		Difficulty:            *difficulty,
+	}
+	if *deadline != "" {
+		date, err := format.ParseDate(*deadline)
+		if err != nil {
+			return err
+		}
+		// Due by the end of that day
+		due := date.Add(24*time.Hour - time.Minute)
+		req.Deadline = &due
+	}
+	req.Tags = splitFlag(*tags)
+	if *parent != 0 {
+		req.ParentID = parent
+	}
+	if *repeat != "" {
+		rule, err := models.ParseRecurrence(*repeat)
+		if err != nil {
+			return err
+		}
+		req.Recurrence = rule
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	task, err := db.CreateTask(req)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(task)
+	}
+	fmt.Printf("Added task #%d: %s (%s, %s)\n", task.ID, task.Title,
+		format.Duration(task.EstimatedDurationMins), format.Currency(task.MoneyCost))
+	if task.Recurrence != nil {
+		fmt.Printf("Repeats %s\n", task.Recurrence)
+	}
+	return nil
+}
+
+// runTaskList lists tasks, optionally only those with a given status
+func runTaskList(dbPath string, args []string) error {
+	fs := flag.NewFlagSet("task list", flag.ContinueOnError)
+	status := fs.String("status", "", "only list tasks with these statuses, comma-separated")
+	taskType := fs.String("type", "", "only list tasks of these types, comma-separated")
+	tags := fs.String("tag", "", "only list tasks with any of these tags, comma-separated")
+	sortBy := fs.String("sort", "", "sort by priority, deadline, created, updated, title or duration")
+	limit := fs.Int("limit", 0, "list at most this many tasks")
+	asJSON := fs.Bool("json", false, "print the tasks as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	filter := database.TaskFilter{Sort: database.TaskSort(*sortBy), Limit: *limit}
+	for _, s := range splitFlag(*status) {
+		filter.Statuses = append(filter.Statuses, models.TaskStatus(s))
+	}
+	for _, t := range splitFlag(*taskType) {
+		filter.TaskTypes = append(filter.TaskTypes, models.TaskType(t))
+	}
+	filter.Tags = splitFlag(*tags)
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tasks, _, err := db.ListTasks(filter)
+	if err != nil {
+		return err
+	}
+	if tasks == nil {
+		tasks = []models.Task{}
+	}
+
+	if *asJSON {
+		return printJSON(tasks)
+	}
+	return printTasks(tasks)
+}
+
+// runTaskDone marks a task as done
+func runTaskDone(dbPath string, args []string) error {
+	fs := flag.NewFlagSet("task done", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the task as JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: oppgaave task done <id>")
+	}
+	id, err := strconv.Atoi(positional[0])
+	if err != nil {
+		return fmt.Errorf("invalid task ID %q", positional[0])
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.UpdateTaskStatus(id, models.StatusDone); err != nil {
+		return err
+	}
+	task, err := db.GetTask(id)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(task)
+	}
+	fmt.Printf("%s Done: #%d %s (%s)\n", format.StatusIcon(task.Status), task.ID, task.Title,
+		format.Currency(task.MoneyCost))
+	return nil
+}
+
+// runNext suggests the tasks to do next
+func runNext(dbPath string, args []string) error {
+	fs := flag.NewFlagSet("next", flag.ContinueOnError)
+	var opts database.NextTaskOptions
+	fs.IntVar(&opts.Energy, "energy", 0, "current energy level 1-3")
+	fs.IntVar(&opts.FreeMinutes, "minutes", 0, "minutes available right now")
+	fs.IntVar(&opts.Limit, "limit", 3, "maximum number of suggestions, 0 for all")
+	asJSON := fs.Bool("json", false, "print the tasks as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tasks, err := db.GetNextTasks(opts)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if tasks == nil {
+			tasks = []models.Task{}
+		}
+		return printJSON(tasks)
+	}
+	if len(tasks) == 0 {
+		fmt.Println("Nothing to do right now 🎉")
+		return nil
+	}
+	for i, t := range tasks {
+		fmt.Printf("%d. #%d %s (%s, %s, %s priority)\n", i+1, t.ID, t.Title,
+			format.Duration(t.EstimatedDurationMins), format.EnergyText(t.EnergyLevel),
+			strings.ToLower(format.PriorityText(t.Priority)))
+	}
+	return nil
+}
+
+// runBudget shows the budget and ledger of a day
+func runBudget(dbPath string, args []string) error {
+	fs := flag.NewFlagSet("budget", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the budget as JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return errors.New("usage: oppgaave budget [today|tomorrow|YYYY-MM-DD]")
+	}
+	var value string
+	if len(positional) == 1 {
+		value = positional[0]
+	}
+	date, err := format.ParseDate(value)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	budget, err := db.GetDailyBudget(date)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(budget)
+	}
+
+	transactions, err := db.GetBudgetTransactions(date)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Budget for %s: %s of %s spent, %s left\n", date.Format("Monday, Jan 2"),
+		format.Currency(budget.SpentCoins), format.Currency(budget.TotalBudgetCoins),
+		format.Currency(budget.RemainingCoins()))
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, t := range transactions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", format.Time(&t.CreatedAt), format.Currency(t.Amount), t.Note)
+	}
+	return tw.Flush()
+}
+
+// runExport writes all tasks to stdout or a file
+func runExport(dbPath string, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("output", "", "file to write to instead of stdout")
+	exportFormat := fs.String("format", "json", "export format: json, or ics for events and deadlines")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *exportFormat != "json" && *exportFormat != "ics" {
+		return fmt.Errorf("unknown export format %q", *exportFormat)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var tasks []models.Task
+	if *exportFormat == "ics" {
+		tasks, err = db.GetCalendarTasks(database.CalendarFilter{})
+	} else {
+		tasks, err = db.GetAllTasks()
+	}
+	if err != nil {
+		return err
+	}
+	if tasks == nil {
+		tasks = []models.Task{}
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *exportFormat == "ics" {
+		err = ical.Encode(w, ical.FromTasks("ADHD Task Manager", tasks))
+	} else {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(tasks)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d tasks to %s\n", len(tasks), *output)
+	}
+	return nil
+}
+
+// runImport handles the import subcommand, which only knows iCalendar files
+func runImport(dbPath string, args []string) error {
+	const importUsage = "usage: oppgaave import ics <file> [--days N]"
+	if len(args) == 0 || args[0] != "ics" {
+		return errors.New(importUsage)
+	}
+
+	fs := flag.NewFlagSet("import ics", flag.ContinueOnError)
+	days := fs.Int("days", ical.DefaultImportDays, "days ahead to create instances of recurring events for")
+	asJSON := fs.Bool("json", false, "print the import result as JSON")
+	positional, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New(importUsage)
+	}
+	if *days < 1 {
+		return fmt.Errorf("invalid number of days %d", *days)
+	}
+
+	f, err := os.Open(positional[0])
+	if err != nil {
+		return fmt.Errorf("failed to open calendar: %w", err)
+	}
+	defer f.Close()
+
+	cal, err := ical.Decode(f)
+	if err != nil {
+		return fmt.Errorf("invalid calendar: %w", err)
+	}
+	from, until := ical.ImportWindow(*days)
+	entries, err := ical.ToEntries(cal, from, until)
+	if err != nil {
+		return fmt.Errorf("invalid calendar: %w", err)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := db.ImportCalendar(entries)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(result)
+	}
+	for _, e := range result.Entries {
+		switch e.Action {
+		case "created":
+			fmt.Printf("Created #%d %s\n", e.TaskID, e.Title)
+		case "updated":
+			fmt.Printf("Updated #%d %s\n", e.TaskID, e.Title)
+		case "skipped":
+			fmt.Printf("Skipped %s (%s)\n", e.Title, e.Reason)
+		case "failed":
+			fmt.Printf("Failed %s: %s\n", e.Title, e.Reason)
+		}
+	}
+	fmt.Printf("Imported %s: %d created, %d updated, %d unchanged, %d skipped, %d failed\n", positional[0],
+		result.Created, result.Updated, result.Unchanged, result.Skipped, result.Failed)
+	return nil
+}
@@ -6,9 +6,13 @@
 echo "🧠 ADHD Task Management System Demo"
 echo "=================================="
 
+echo ""
+echo "Adding demo data..."
+go run -tags sqlite_fts5 . seed --demo
+
 echo ""
 echo "Starting server..."
-go run main.go &
+go run -tags sqlite_fts5 . &
 SERVER_PID=$!
 
 # Wait for server to start
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// attachmentColumns are the columns of an attachment
+const attachmentColumns = `id, task_id, contact_id, filename, original_filename, file_path,
+	file_size, mime_type, description, attachment_type, created_at`
+
+// scanAttachment reads a row selected with attachmentColumns
+func scanAttachment(row rowScanner) (*models.Attachment, error) {
+	attachment := &models.Attachment{}
+	var (
+		taskID, contactID, fileSize           sql.NullInt64
+		mimeType, description, attachmentType sql.NullString
+	)
+	err := row.Scan(&attachment.ID, &taskID, &contactID, &attachment.Filename,
+		&attachment.OriginalFilename, &attachment.FilePath, &fileSize, &mimeType,
+		&description, &attachmentType, &attachment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if taskID.Valid {
+		id := int(taskID.Int64)
+		attachment.TaskID = &id
+	}
+	if contactID.Valid {
+		id := int(contactID.Int64)
+		attachment.ContactID = &id
+	}
+	attachment.FileSize = fileSize.Int64
+	attachment.MimeType = mimeType.String
+	attachment.Description = description.String
+	attachment.AttachmentType = attachmentType.String
+	return attachment, nil
+}
+
+// queryAttachments returns the attachments matching a condition, oldest first
+func (db *DB) queryAttachments(where string, args ...interface{}) ([]models.Attachment, error) {
+	rows, err := db.conn.Query(`SELECT `+attachmentColumns+` FROM attachments WHERE `+where+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, *attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// loadTaskAttachments loads attachments for a task
+func (db *DB) loadTaskAttachments(task *models.Task) error {
+	attachments, err := db.queryAttachments(`task_id = ?`, task.ID)
+	if err != nil {
+		return err
+	}
+	if len(attachments) > 0 {
+		task.Attachments = attachments
+	}
+	return nil
+}
+
+// GetTaskAttachments returns the attachments of a task
+func (db *DB) GetTaskAttachments(taskID int) ([]models.Attachment, error) {
+	if _, err := db.taskTitles(taskID); err != nil {
+		return nil, err
+	}
+	return db.queryAttachments(`task_id = ?`, taskID)
+}
+
+// GetContactAttachments returns the attachments of a contact
+func (db *DB) GetContactAttachments(contactID int) ([]models.Attachment, error) {
+	if err := contactExists(db.conn, contactID); err != nil {
+		return nil, err
+	}
+	return db.queryAttachments(`contact_id = ?`, contactID)
+}
+
+// GetAttachment returns a single attachment
+func (db *DB) GetAttachment(id int) (*models.Attachment, error) {
+	attachment, err := scanAttachment(db.conn.QueryRow(`SELECT `+attachmentColumns+` FROM attachments WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("attachment %d: %w", id, ErrNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return attachment, nil
+}
+
+// CreateAttachment records a stored file as the attachment of either a task
+// or a contact. The attachment type follows from the MIME type when unset.
+func (db *DB) CreateAttachment(a *models.Attachment) (*models.Attachment, error) {
+	switch {
+	case (a.TaskID == nil) == (a.ContactID == nil):
+		return nil, fmt.Errorf("%w: an attachment belongs to a task or a contact", ErrInvalid)
+	case a.Filename == "" || a.FilePath == "":
+		return nil, fmt.Errorf("%w: attachment has no file", ErrInvalid)
+	}
+	if a.TaskID != nil {
+		if _, err := db.taskTitles(*a.TaskID); err != nil {
+			return nil, err
+		}
+	} else if err := contactExists(db.conn, *a.ContactID); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(filepath.Base(filepath.ToSlash(a.OriginalFilename)))
+	if name == "" || name == "." || name == "/" {
+		name = a.Filename
+	}
+	attachmentType := a.AttachmentType
+	if attachmentType == "" {
+		attachmentType = models.AttachmentTypeFor(a.MimeType)
+	}
+
+	result, err := db.conn.Exec(`
+		INSERT INTO attachments (task_id, contact_id, filename, original_filename, file_path,
+			file_size, mime_type, description, attachment_type, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.TaskID, a.ContactID, a.Filename, name, a.FilePath, a.FileSize,
+		nullString(a.MimeType), nullString(strings.TrimSpace(a.Description)), attachmentType, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attachment: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment ID: %w", err)
+	}
+	return db.GetAttachment(int(id))
+}
+
+// DeleteAttachment deletes an attachment and returns it. Its file stays on
+// disk; see AttachmentFileInUse.
+func (db *DB) DeleteAttachment(id int) (*models.Attachment, error) {
+	attachment, err := db.GetAttachment(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.conn.Exec(`DELETE FROM attachments WHERE id = ?`, id); err != nil {
+		return nil, fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return attachment, nil
+}
+
+// AttachmentFileInUse reports whether any attachment still has the stored
+// file of the given name; identical uploads share one file
+func (db *DB) AttachmentFileInUse(filename string) (bool, error) {
+	var n int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM attachments WHERE filename = ?`, filename).Scan(&n); err != nil {
+		return false, fmt.Errorf("failed to check attachment file: %w", err)
+	}
+	return n > 0, nil
+}
+
+// AttachmentFiles returns the names of the stored files attachments have
+func (db *DB) AttachmentFiles() (map[string]bool, error) {
+	rows, err := db.conn.Query(`SELECT DISTINCT filename FROM attachments`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment files: %w", err)
+	}
+	defer rows.Close()
+
+	files := make(map[string]bool)
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment file: %w", err)
+		}
+		files[filename] = true
+	}
+	return files, rows.Err()
+}
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// Budget transaction kinds
+const (
+	TransactionCompletion = "completion"
+	TransactionProgress   = "progress"
+	TransactionAdjustment = "adjustment"
+)
+
+// recordTransaction appends an entry to the budget ledger and keeps the
+// spent_coins column of that day's budget in sync with it
+func recordTransaction(q querier, date time.Time, taskID *int, amount int, kind, note string) error {
+	dateStr := date.Format("2006-01-02")
+	now := time.Now()
+
+	_, err := q.Exec(`INSERT INTO budget_transactions (date, task_id, amount, kind, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, dateStr, taskID, amount, kind, note, now)
+	if err != nil {
+		return fmt.Errorf("failed to record budget transaction: %w", err)
+	}
+
+	_, err = q.Exec(`UPDATE daily_budgets SET updated_at = ?,
+		spent_coins = (SELECT COALESCE(SUM(amount), 0) FROM budget_transactions WHERE date = ?)
+		WHERE date = ?`, now, dateStr, dateStr)
+	if err != nil {
+		return fmt.Errorf("failed to update spent coins: %w", err)
+	}
+
+	return nil
+}
+
+// spentCoins sums the ledger entries of a day
+func spentCoins(q querier, date time.Time) (int, error) {
+	var spent int
+	err := q.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM budget_transactions WHERE date = ?`,
+		date.Format("2006-01-02")).Scan(&spent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum budget transactions: %w", err)
+	}
+	return spent, nil
+}
+
+// chargeStatusChange books the coins of a task status change on the ledger.
+// Finishing a task charges whatever part of its cost has not been charged
+// yet, pausing it charges the share of the estimate actually worked, and
+// reopening it refunds the last completion charge.
+func chargeStatusChange(q querier, taskID int, oldStatus, newStatus models.TaskStatus, workedMins float64, now time.Time) error {
+	var (
+		title     string
+		cost, est int
+		charged   int
+	)
+	err := q.QueryRow(`SELECT title, money_cost, estimated_duration_minutes FROM tasks WHERE id = ?`, taskID).
+		Scan(&title, &cost, &est)
+	if err != nil {
+		return fmt.Errorf("failed to get task cost: %w", err)
+	}
+	err = q.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM budget_transactions WHERE task_id = ?`, taskID).
+		Scan(&charged)
+	if err != nil {
+		return fmt.Errorf("failed to sum task charges: %w", err)
+	}
+
+	switch {
+	case newStatus == models.StatusDone:
+		if amount := cost - charged; amount > 0 {
+			return recordTransaction(q, now, &taskID, amount, TransactionCompletion, "Finished "+title)
+		}
+
+	case oldStatus == models.StatusDone:
+		var amount int
+		err := q.QueryRow(`SELECT amount FROM budget_transactions WHERE task_id = ? AND kind = ?
+			ORDER BY id DESC LIMIT 1`, taskID, TransactionCompletion).Scan(&amount)
+		if err == sql.ErrNoRows {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to get completion charge: %w", err)
+		}
+		return recordTransaction(q, now, &taskID, -amount, TransactionAdjustment, "Reopened "+title)
+
+	case oldStatus == models.StatusInProgress && workedMins > 0:
+		amount := int(math.Round(float64(cost) * workedMins / math.Max(1, float64(est))))
+		if amount > cost-charged {
+			amount = cost - charged
+		}
+		if amount > 0 {
+			note := fmt.Sprintf("Worked %d min on %s", int(workedMins), title)
+			return recordTransaction(q, now, &taskID, amount, TransactionProgress, note)
+		}
+	}
+
+	return nil
+}
+
+// SetBudgetOverride fixes the budget of a single date regardless of the
+// settings, or with nil coins hands it back to the settings
+func (db *DB) SetBudgetOverride(date time.Time, coins *int) (*models.DailyBudget, error) {
+	if coins != nil && *coins <= 0 {
+		return nil, fmt.Errorf("%w: budget must be positive", ErrInvalid)
+	}
+
+	// Make sure the day has a budget row to override
+	if _, err := db.GetDailyBudget(date); err != nil {
+		return nil, err
+	}
+
+	var total int
+	if coins != nil {
+		total = *coins
+	} else {
+		var err error
+		if total, err = db.budgetCoinsFor(date); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err := db.conn.Exec(`UPDATE daily_budgets SET total_budget_coins = ?, budget_override = ?, updated_at = ?
+		WHERE date = ?`, total, coins, time.Now(), date.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to override daily budget: %w", err)
+	}
+
+	return db.GetDailyBudget(date)
+}
+
+// refreshUpcomingBudgets resizes the budgets of today and later that were not
+// set by hand, after the budget settings changed
+func (db *DB) refreshUpcomingBudgets() error {
+	rows, err := db.conn.Query(`SELECT date FROM daily_budgets WHERE budget_override IS NULL AND date >= ?`,
+		time.Now().Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("failed to query upcoming budgets: %w", err)
+	}
+
+	var dates []time.Time
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan budget date: %w", err)
+		}
+		dates = append(dates, date)
+	}
+	rows.Close()
+
+	for _, date := range dates {
+		total, err := db.budgetCoinsFor(date)
+		if err != nil {
+			return err
+		}
+		_, err = db.conn.Exec(`UPDATE daily_budgets SET total_budget_coins = ?, updated_at = ?
+			WHERE date = ? AND total_budget_coins != ?`, total, time.Now(), date.Format("2006-01-02"), total)
+		if err != nil {
+			return fmt.Errorf("failed to update daily budget: %w", err)
+		}
+	}
+	return nil
+}
+
+// AddBudgetAdjustment records a manual ledger entry; positive amounts spend coins, negative ones refund them
+func (db *DB) AddBudgetAdjustment(date time.Time, taskID *int, amount int, note string) (*models.BudgetTransaction, error) {
+	if amount == 0 {
+		return nil, fmt.Errorf("%w: amount must not be zero", ErrInvalid)
+	}
+	if taskID != nil {
+		if _, err := db.taskTitles(*taskID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Make sure the day has a budget row to keep in sync
+	if _, err := db.GetDailyBudget(date); err != nil {
+		return nil, err
+	}
+	if err := recordTransaction(db.conn, date, taskID, amount, TransactionAdjustment, note); err != nil {
+		return nil, err
+	}
+
+	transactions, err := db.GetBudgetTransactions(date)
+	if err != nil {
+		return nil, err
+	}
+	return &transactions[len(transactions)-1], nil
+}
+
+// GetBudgetTransactions lists the ledger entries of a day in the order they were booked
+func (db *DB) GetBudgetTransactions(date time.Time) ([]models.BudgetTransaction, error) {
+	query := `
+		SELECT bt.id, bt.date, bt.task_id, t.title, bt.amount, bt.kind, bt.note, bt.created_at
+		FROM budget_transactions bt
+		LEFT JOIN tasks t ON t.id = bt.task_id
+		WHERE bt.date = ?
+		ORDER BY bt.id`
+
+	rows, err := db.conn.Query(query, date.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budget transactions: %w", err)
+	}
+	defer rows.Close()
+
+	transactions := []models.BudgetTransaction{}
+	for rows.Next() {
+		var (
+			transaction models.BudgetTransaction
+			taskID      sql.NullInt64
+			taskTitle   sql.NullString
+			note        sql.NullString
+		)
+		err := rows.Scan(&transaction.ID, &transaction.Date, &taskID, &taskTitle,
+			&transaction.Amount, &transaction.Kind, &note, &transaction.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan budget transaction: %w", err)
+		}
+		if taskID.Valid {
+			transaction.TaskID = &[]int{int(taskID.Int64)}[0]
+		}
+		transaction.TaskTitle = taskTitle.String
+		transaction.Note = note.String
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// TestLedgerFollowsStatusChanges walks a task through work, completion and
+// reopening, checking the ledger entries each change books and the coins
+// spent on the day
+func TestLedgerFollowsStatusChanges(t *testing.T) {
+	db := newTestDB(t)
+	today := time.Now()
+	if _, err := db.GetDailyBudget(today); err != nil {
+		t.Fatalf("GetDailyBudget: %v", err)
+	}
+	task, err := db.CreateTask(&models.CreateTaskRequest{Title: "Write report", EstimatedDurationMins: 60})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	cost, half := task.MoneyCost, (task.MoneyCost+1)/2
+	if cost < 2 {
+		t.Fatalf("money cost = %d, want at least 2", cost)
+	}
+	// workHalf makes the running time entry cover half the estimate
+	workHalf := func() {
+		if _, err := db.conn.Exec(`UPDATE task_schedule SET actual_start_time = ? WHERE task_id = ? AND actual_end_time IS NULL`,
+			time.Now().Add(-30*time.Minute), task.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		status  models.TaskStatus
+		before  func()
+		entries string // kind:amount of the entries booked
+		spent   int
+	}{
+		{models.StatusInProgress, nil, "", 0},
+		{models.StatusPending, workHalf, fmt.Sprintf("progress:%d", half), half},
+		{models.StatusDone, nil, fmt.Sprintf("completion:%d", cost-half), cost},
+		{models.StatusPending, nil, fmt.Sprintf("adjustment:%d", half-cost), half},
+		{models.StatusDone, nil, fmt.Sprintf("completion:%d", cost-half), cost},
+		{models.StatusDone, nil, "", cost},
+	}
+	booked := 0
+	for i, tt := range tests {
+		if tt.before != nil {
+			tt.before()
+		}
+		if err := db.UpdateTaskStatus(task.ID, tt.status); err != nil {
+			t.Fatalf("step %d: UpdateTaskStatus: %v", i, err)
+		}
+
+		transactions, err := db.GetBudgetTransactions(today)
+		if err != nil {
+			t.Fatalf("GetBudgetTransactions: %v", err)
+		}
+		var entries []string
+		for _, tr := range transactions[booked:] {
+			entries = append(entries, fmt.Sprintf("%s:%d", tr.Kind, tr.Amount))
+		}
+		booked = len(transactions)
+		if got := strings.Join(entries, " "); got != tt.entries {
+			t.Errorf("step %d, %s: booked %q, want %q", i, tt.status, got, tt.entries)
+		}
+
+		budget, err := db.GetDailyBudget(today)
+		if err != nil {
+			t.Fatalf("GetDailyBudget: %v", err)
+		}
+		if budget.SpentCoins != tt.spent {
+			t.Errorf("step %d, %s: spent %d coins, want %d", i, tt.status, budget.SpentCoins, tt.spent)
+		}
+	}
+}
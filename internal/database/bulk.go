@@ -0,0 +1,202 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"oppgaave/internal/models"
+)
+
+// MaxBulkOperations bounds the number of operations of one bulk request
+const MaxBulkOperations = 500
+
+// dbConn is what a DB runs its statements on
+type dbConn interface {
+	querier
+	Begin() (dbTx, error)
+}
+
+// dbTx is a transaction begun on a dbConn
+type dbTx interface {
+	querier
+	Commit() error
+	Rollback() error
+}
+
+// sqlConn runs statements on the database itself
+type sqlConn struct {
+	*sql.DB
+}
+
+func (c sqlConn) Begin() (dbTx, error) {
+	return c.DB.Begin()
+}
+
+// bulkConn runs statements inside one transaction spanning several calls,
+// such as those of a bulk request.
+// Transactions begun on it are savepoints, so the methods of DB that commit
+// their own work can still be undone with the whole request.
+type bulkConn struct {
+	*sql.Tx
+	savepoints  int
+	calibration *models.Calibration // Learned once for the whole transaction
+}
+
+func (c *bulkConn) Begin() (dbTx, error) {
+	c.savepoints++
+	name := fmt.Sprintf("bulk_%d", c.savepoints)
+	if _, err := c.Exec("SAVEPOINT " + name); err != nil {
+		return nil, err
+	}
+	return &savepoint{querier: c.Tx, name: name}, nil
+}
+
+// savepoint is a nested transaction. Like *sql.Tx, rolling back after a
+// commit does nothing, so it can be deferred.
+type savepoint struct {
+	querier
+	name string
+	done bool
+}
+
+func (s *savepoint) Commit() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	_, err := s.Exec("RELEASE " + s.name)
+	return err
+}
+
+func (s *savepoint) Rollback() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	if _, err := s.Exec("ROLLBACK TO " + s.name); err != nil {
+		return err
+	}
+	_, err := s.Exec("RELEASE " + s.name)
+	return err
+}
+
+// inTransaction runs fn with a DB whose statements all go into one
+// transaction, committed when fn succeeds. A DB already inside such a
+// transaction is passed on as it is.
+func (db *DB) inTransaction(fn func(tx *DB) error) error {
+	if _, ok := db.conn.(*bulkConn); ok {
+		return fn(db)
+	}
+
+	tx, err := db.sqlDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&DB{conn: &bulkConn{Tx: tx}, sqlDB: db.sqlDB}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RunBulk applies the operations of a bulk request in one transaction, in
+// order, so later operations see the changes of earlier ones. Each operation
+// runs in its own savepoint: a failed one leaves no trace and the rest still
+// run. An atomic request is rolled back entirely when any operation fails.
+func (db *DB) RunBulk(req *models.BulkRequest) (*models.BulkResult, error) {
+	switch {
+	case len(req.Operations) == 0:
+		return nil, fmt.Errorf("%w: no operations", ErrInvalid)
+	case len(req.Operations) > MaxBulkOperations:
+		return nil, fmt.Errorf("%w: at most %d operations per request", ErrInvalid, MaxBulkOperations)
+	}
+
+	tx, err := db.sqlDB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	bulk := &DB{conn: &bulkConn{Tx: tx}, sqlDB: db.sqlDB}
+
+	result := &models.BulkResult{Results: make([]models.BulkItemResult, len(req.Operations))}
+	for i := range req.Operations {
+		op := &req.Operations[i]
+		item := &result.Results[i]
+		item.Index, item.Action, item.TaskID = i, op.Action, op.TaskID
+
+		sp, err := bulk.conn.Begin()
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin savepoint: %w", err)
+		}
+		item.Task, err = bulk.applyBulkOperation(op)
+		if err != nil {
+			if rbErr := sp.Rollback(); rbErr != nil {
+				return nil, fmt.Errorf("failed to roll back operation %d: %w", i, rbErr)
+			}
+			item.Error = err.Error()
+			result.Failed++
+			continue
+		}
+		if err := sp.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+		if item.Task != nil {
+			item.TaskID = item.Task.ID
+		}
+		item.OK = true
+		result.Succeeded++
+	}
+
+	if req.Atomic && result.Failed > 0 {
+		result.RolledBack = true
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bulk operations: %w", err)
+	}
+	return result, nil
+}
+
+// applyBulkOperation runs one operation, returning the task it left behind
+func (db *DB) applyBulkOperation(op *models.BulkOperation) (*models.Task, error) {
+	if err := op.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	switch op.Action {
+	case models.BulkCreate:
+		return db.CreateTask(op.Task)
+	case models.BulkUpdate:
+		return db.UpdateTask(op.TaskID, op.Update)
+	case models.BulkSetStatus:
+		if err := db.UpdateTaskStatus(op.TaskID, op.Status); err != nil {
+			return nil, err
+		}
+	case models.BulkDelete:
+		return nil, db.DeleteTask(op.TaskID, op.Cascade)
+	case models.BulkAddTag:
+		task, err := db.GetTask(op.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		tags := append([]string(task.Tags), op.Tag)
+		return db.UpdateTask(op.TaskID, &models.UpdateTaskRequest{Tags: &tags})
+	case models.BulkReparent:
+		parentID := 0
+		if op.ParentID != nil {
+			parentID = *op.ParentID
+		}
+		return db.UpdateTask(op.TaskID, &models.UpdateTaskRequest{ParentID: &parentID})
+	case models.BulkAddPrerequisite:
+		if err := db.AddPrerequisite(op.TaskID, op.PrerequisiteID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalid, op.Action)
+	}
+	return db.GetTask(op.TaskID)
+}
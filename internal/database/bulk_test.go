@@ -0,0 +1,65 @@
+package database
+
+import (
+	"testing"
+
+	"oppgaave/internal/models"
+)
+
+func TestRunBulkRollback(t *testing.T) {
+	tests := []struct {
+		name       string
+		atomic     bool
+		rolledBack bool
+		titles     []string // Tasks left afterwards, in id order
+	}{
+		{"atomic", true, true, []string{"Existing"}},
+		{"not atomic", false, false, []string{"Renamed", "New"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			existing, err := db.CreateTask(&models.CreateTaskRequest{Title: "Existing"})
+			if err != nil {
+				t.Fatalf("CreateTask: %v", err)
+			}
+			renamed := "Renamed"
+			result, err := db.RunBulk(&models.BulkRequest{
+				Atomic: tt.atomic,
+				Operations: []models.BulkOperation{
+					{Action: models.BulkUpdate, TaskID: existing.ID, Update: &models.UpdateTaskRequest{Title: &renamed}},
+					{Action: models.BulkCreate, Task: &models.CreateTaskRequest{Title: "New"}},
+					{Action: models.BulkAddPrerequisite, TaskID: existing.ID, PrerequisiteID: existing.ID},
+				},
+			})
+			if err != nil {
+				t.Fatalf("RunBulk: %v", err)
+			}
+			if result.Succeeded != 2 || result.Failed != 1 || result.RolledBack != tt.rolledBack {
+				t.Errorf("RunBulk() = %d succeeded, %d failed, rolled back %v, want 2, 1, %v",
+					result.Succeeded, result.Failed, result.RolledBack, tt.rolledBack)
+			}
+			if item := result.Results[2]; item.OK || item.Error == "" {
+				t.Errorf("self-prerequisite = %+v, want an error", item)
+			}
+
+			tasks, _, err := db.ListTasks(TaskFilter{})
+			if err != nil {
+				t.Fatalf("ListTasks: %v", err)
+			}
+			var titles []string
+			for _, task := range tasks {
+				titles = append(titles, task.Title)
+			}
+			if len(titles) != len(tt.titles) {
+				t.Fatalf("tasks = %q, want %q", titles, tt.titles)
+			}
+			for i := range titles {
+				if titles[i] != tt.titles[i] {
+					t.Errorf("tasks = %q, want %q", titles, tt.titles)
+					break
+				}
+			}
+		})
+	}
+}
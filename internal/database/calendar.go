@@ -0,0 +1,249 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// CalendarFilter narrows down the tasks put on the calendar feed
+type CalendarFilter struct {
+	TaskTypes []models.TaskType // Only these task types, empty for all
+	Tags      []string          // Only tasks with any of these tags, empty for all
+}
+
+// matches reports whether a task passes the filter
+func (f CalendarFilter) matches(task *models.Task) bool {
+	if len(f.TaskTypes) > 0 {
+		found := false
+		for _, taskType := range f.TaskTypes {
+			if task.TaskType == taskType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Tags) > 0 {
+		for _, want := range f.Tags {
+			for _, tag := range task.Tags {
+				if strings.EqualFold(tag, want) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// GetCalendarTasks returns the tasks that belong on a calendar, events with a
+// start time and tasks with a deadline, with their contacts loaded
+func (db *DB) GetCalendarTasks(filter CalendarFilter) ([]models.Task, error) {
+	tasks, err := db.GetAllTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	var calendar []models.Task
+	for i := range tasks {
+		task := &tasks[i]
+		if !(task.IsEvent() && task.EventStart != nil) && task.Deadline == nil {
+			continue
+		}
+		if !filter.matches(task) {
+			continue
+		}
+		if err := db.loadTaskContacts(task); err != nil {
+			return nil, err
+		}
+		calendar = append(calendar, *task)
+	}
+
+	return calendar, nil
+}
+
+// ImportCalendar creates a task for every calendar entry not seen before and
+// updates the tasks imported earlier, matched by UID. Only the fields that
+// come from the calendar are updated, so priority, energy and status changed
+// since are kept. Organizers and attendees are linked as contacts, matched by
+// email address and created when they are new. Entries exported by this app
+// match the task they came from. The import runs in one transaction, each
+// entry in a savepoint of its own, so a failed entry leaves no half-made task
+// behind; it is reported as failed and the import goes on with the rest.
+func (db *DB) ImportCalendar(entries []models.CalendarEntry) (*models.CalendarImportResult, error) {
+	result := &models.CalendarImportResult{Entries: []models.CalendarImportEntry{}}
+	err := db.inTransaction(func(tx *DB) error {
+		for i := range entries {
+			entry := &entries[i]
+			outcome := models.CalendarImportEntry{UID: entry.UID, Title: entry.Task.Title}
+			if entry.SkipReason != "" {
+				outcome.Action = "skipped"
+				outcome.Reason = entry.SkipReason
+				result.Count(outcome)
+				continue
+			}
+
+			sp, err := tx.conn.Begin()
+			if err != nil {
+				return fmt.Errorf("failed to begin savepoint: %w", err)
+			}
+			taskID, action, err := tx.importCalendarEntry(entry)
+			if err != nil {
+				if rbErr := sp.Rollback(); rbErr != nil {
+					return fmt.Errorf("failed to roll back %s: %w", entry.UID, rbErr)
+				}
+				outcome.Action = "failed"
+				outcome.Reason = err.Error()
+				result.Count(outcome)
+				continue
+			}
+			if err := sp.Commit(); err != nil {
+				return fmt.Errorf("failed to release savepoint: %w", err)
+			}
+			outcome.TaskID = taskID
+			outcome.Action = action
+			result.Count(outcome)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importCalendarEntry creates or updates the task of one calendar entry and
+// reports whether it was created, updated or unchanged
+func (db *DB) importCalendarEntry(entry *models.CalendarEntry) (int, string, error) {
+	var (
+		taskID int
+		action = "unchanged"
+	)
+	err := db.conn.QueryRow(`SELECT id FROM tasks WHERE ical_uid = ?`, entry.UID).Scan(&taskID)
+	if err == sql.ErrNoRows && entry.TaskID != 0 {
+		err = db.conn.QueryRow(`SELECT id FROM tasks WHERE id = ?`, entry.TaskID).Scan(&taskID)
+	}
+	switch {
+	case err == sql.ErrNoRows:
+		task, err := db.createTask(&entry.Task, taskOrigin{icalUID: entry.UID})
+		if err != nil {
+			return 0, "", err
+		}
+		taskID = task.ID
+		action = "created"
+
+	case err != nil:
+		return 0, "", fmt.Errorf("failed to look up calendar UID: %w", err)
+
+	default:
+		task, err := db.GetTask(taskID)
+		if err != nil {
+			return 0, "", err
+		}
+		if update := calendarUpdate(task, &entry.Task); update != nil {
+			if _, err := db.UpdateTask(taskID, update); err != nil {
+				return 0, "", err
+			}
+			action = "updated"
+		}
+	}
+
+	linked, err := db.linkCalendarContacts(taskID, entry.Contacts)
+	if err != nil {
+		return 0, "", err
+	}
+	if linked && action == "unchanged" {
+		action = "updated"
+	}
+	return taskID, action, nil
+}
+
+// calendarUpdate returns the changes a re-imported calendar entry makes to
+// its task, or nil if there are none. The estimate only follows the calendar
+// for events with an end, since to-dos usually carry the default.
+func calendarUpdate(task *models.Task, req *models.CreateTaskRequest) *models.UpdateTaskRequest {
+	update := &models.UpdateTaskRequest{}
+	changed := false
+	setString := func(field **string, old, new string) {
+		if old != new {
+			value := new
+			*field = &value
+			changed = true
+		}
+	}
+	setTime := func(field **time.Time, old, new *time.Time) {
+		if new != nil && (old == nil || !old.Equal(*new)) {
+			*field = new
+			changed = true
+		}
+	}
+
+	setString(&update.Title, task.Title, req.Title)
+	setString(&update.Description, task.Description, req.Description)
+	setString(&update.EventLocation, task.EventLocation, req.EventLocation)
+	setTime(&update.Deadline, task.Deadline, req.Deadline)
+	setTime(&update.EventStart, task.EventStart, req.EventStart)
+	setTime(&update.EventEnd, task.EventEnd, req.EventEnd)
+	if task.TaskType != req.TaskType {
+		taskType := req.TaskType
+		update.TaskType = &taskType
+		changed = true
+	}
+	if req.EventStart != nil && req.EventEnd != nil && task.EstimatedDurationMins != req.EstimatedDurationMins {
+		mins := req.EstimatedDurationMins
+		update.EstimatedDurationMins = &mins
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return update
+}
+
+// linkCalendarContacts links the contacts of a calendar entry to a task with
+// their roles, creating contacts for unknown email addresses, and reports
+// whether any link was added or changed
+func (db *DB) linkCalendarContacts(taskID int, contacts []models.CalendarContact) (bool, error) {
+	changed := false
+	for _, contact := range contacts {
+		var contactID int
+		err := db.conn.QueryRow(`SELECT id FROM contacts WHERE lower(email) = lower(?) ORDER BY id LIMIT 1`,
+			contact.Email).Scan(&contactID)
+		if err == sql.ErrNoRows {
+			now := time.Now()
+			result, err := db.conn.Exec(`INSERT INTO contacts (name, email, type, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?)`, contact.Name, contact.Email, contact.Type, now, now)
+			if err != nil {
+				return false, fmt.Errorf("failed to create contact: %w", err)
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return false, fmt.Errorf("failed to get contact ID: %w", err)
+			}
+			contactID = int(id)
+		} else if err != nil {
+			return false, fmt.Errorf("failed to look up contact: %w", err)
+		}
+
+		result, err := db.conn.Exec(`
+			INSERT INTO task_contacts (task_id, contact_id, role) VALUES (?, ?, ?)
+			ON CONFLICT(task_id, contact_id) DO UPDATE SET role = excluded.role
+			WHERE role IS NOT excluded.role`, taskID, contactID, contact.Role)
+		if err != nil {
+			return false, fmt.Errorf("failed to link contact: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			changed = true
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"testing"
+
+	"oppgaave/internal/models"
+)
+
+func TestImportCalendar(t *testing.T) {
+	db := newTestDB(t)
+	own, err := db.CreateTask(&models.CreateTaskRequest{Title: "Dentist", EstimatedDurationMins: 30})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	entry := func(uid, title string, priority int) models.CalendarEntry {
+		return models.CalendarEntry{UID: uid, Task: models.CreateTaskRequest{
+			Title: title, EstimatedDurationMins: 30, Priority: priority, TaskType: models.TypeTask,
+		}}
+	}
+	ownEntry := entry("task-1@oppgaave", "Dentist at noon", 2)
+	ownEntry.TaskID = own.ID
+	entries := []models.CalendarEntry{
+		entry("a@example.com", "Standup", 2),
+		entry("b@example.com", "Broken", 9),
+		{UID: "c@example.com", Task: models.CreateTaskRequest{Title: "Cancelled"}, SkipReason: "cancelled"},
+		ownEntry,
+		entry("d@example.com", "Retro", 2),
+	}
+
+	tests := []struct {
+		name    string
+		actions []string
+		tasks   int
+	}{
+		{"first import", []string{"created", "failed", "skipped", "updated", "created"}, 3},
+		{"again", []string{"unchanged", "failed", "skipped", "unchanged", "unchanged"}, 3},
+	}
+	for _, tt := range tests {
+		result, err := db.ImportCalendar(entries)
+		if err != nil {
+			t.Fatalf("%s: ImportCalendar: %v", tt.name, err)
+		}
+		for i, e := range result.Entries {
+			if e.Action != tt.actions[i] {
+				t.Errorf("%s: %s was %s, want %s", tt.name, e.Title, e.Action, tt.actions[i])
+			}
+		}
+		if result.Failed != 1 || result.Entries[1].Reason == "" {
+			t.Errorf("%s: failed %d entries with reason %q, want 1 with a reason", tt.name, result.Failed, result.Entries[1].Reason)
+		}
+		if result.Entries[3].TaskID != own.ID {
+			t.Errorf("%s: own entry matched task %d, want %d", tt.name, result.Entries[3].TaskID, own.ID)
+		}
+		tasks, _, err := db.ListTasks(TaskFilter{})
+		if err != nil {
+			t.Fatalf("ListTasks: %v", err)
+		}
+		if len(tasks) != tt.tasks {
+			t.Errorf("%s: %d tasks, want %d", tt.name, len(tasks), tt.tasks)
+		}
+	}
+}
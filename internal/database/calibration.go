@@ -0,0 +1,112 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"oppgaave/internal/models"
+)
+
+// GetCalibration learns estimate accuracy from finished tasks with tracked
+// time, against their estimates as entered rather than as calibrated. Tasks
+// calibrated before the estimate as entered was kept are left out.
+func (db *DB) GetCalibration() (*models.Calibration, error) {
+	query := `
+		SELECT t.id, COALESCE(t.raw_estimate_minutes, t.estimated_duration_minutes), t.task_type, t.difficulty, t.energy_level, t.tags,
+			s.actual_start_time, s.actual_end_time
+		FROM tasks t
+		JOIN task_schedule s ON s.task_id = t.id
+		WHERE t.status = ? AND s.actual_start_time IS NOT NULL AND s.actual_end_time IS NOT NULL
+		AND (t.raw_estimate_minutes IS NOT NULL OR NOT t.estimate_calibrated)
+		ORDER BY t.id`
+
+	rows, err := db.conn.Query(query, models.StatusDone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query estimate samples: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []models.EstimateSample
+	for rows.Next() {
+		var (
+			sample     models.EstimateSample
+			taskType   sql.NullString
+			start, end sql.NullTime
+		)
+		err := rows.Scan(&sample.TaskID, &sample.EstimatedMinutes, &taskType, &sample.Difficulty,
+			&sample.EnergyLevel, &sample.Tags, &start, &end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan estimate sample: %w", err)
+		}
+		sample.TaskType = models.TaskType(taskType.String)
+		sample.ActualMinutes = end.Time.Sub(start.Time).Minutes()
+
+		// Several time entries of one task add up to a single sample
+		if n := len(samples); n > 0 && samples[n-1].TaskID == sample.TaskID {
+			samples[n-1].ActualMinutes += sample.ActualMinutes
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read estimate samples: %w", err)
+	}
+
+	return models.BuildCalibration(samples), nil
+}
+
+// currentCalibration returns what GetCalibration learns, once per transaction
+// spanning several calls, so bulk requests and imports do not go through the
+// tracked time again for every task
+func (db *DB) currentCalibration() (*models.Calibration, error) {
+	bulk, ok := db.conn.(*bulkConn)
+	if ok && bulk.calibration != nil {
+		return bulk.calibration, nil
+	}
+	calibration, err := db.GetCalibration()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		bulk.calibration = calibration
+	}
+	return calibration, nil
+}
+
+// AttachCalibration fills in the suggested estimate correction of each task
+func (db *DB) AttachCalibration(tasks []models.Task) error {
+	calibration, err := db.GetCalibration()
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.conn.Query(`SELECT id FROM tasks WHERE estimate_calibrated = 1`)
+	if err != nil {
+		return fmt.Errorf("failed to query calibrated tasks: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("failed to scan calibrated task: %w", err)
+		}
+		applied[id] = true
+	}
+
+	for i := range tasks {
+		tasks[i].Calibrate(calibration, applied[tasks[i].ID])
+	}
+	return nil
+}
+
+// isEstimateCalibrated reports whether a task's stored estimate already includes the correction
+func (db *DB) isEstimateCalibrated(taskID int) (bool, error) {
+	var calibrated bool
+	err := db.conn.QueryRow(`SELECT estimate_calibrated FROM tasks WHERE id = ?`, taskID).Scan(&calibrated)
+	if err != nil {
+		return false, fmt.Errorf("failed to check task calibration: %w", err)
+	}
+	return calibrated, nil
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// finishTask creates a task and finishes it after the given minutes of tracked time
+func finishTask(t *testing.T, db *DB, req *models.CreateTaskRequest, minutes int) *models.Task {
+	t.Helper()
+	task, err := db.CreateTask(req)
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	end := time.Now()
+	start := end.Add(-time.Duration(minutes) * time.Minute)
+	if _, err := db.conn.Exec(`INSERT INTO task_schedule (task_id, scheduled_date, actual_start_time, actual_end_time)
+		VALUES (?, ?, ?, ?)`, task.ID, end.Format("2006-01-02"), start, end); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateTaskStatus(task.ID, models.StatusDone); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	return task
+}
+
+func TestCalibrationLearnsFromEstimatesAsEntered(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < models.MinCalibrationSamples; i++ {
+		finishTask(t, db, &models.CreateTaskRequest{Title: "Write report", EstimatedDurationMins: 30}, 60)
+	}
+
+	tests := []struct {
+		name     string
+		apply    bool
+		estimate int // stored estimate of the new task
+	}{
+		{"suggested only", false, 30},
+		{"applied", true, 60},
+		{"applied again", true, 60},
+	}
+	for _, tt := range tests {
+		task := finishTask(t, db, &models.CreateTaskRequest{
+			Title: "Write report", EstimatedDurationMins: 30, ApplyCalibration: tt.apply,
+		}, 60)
+		if task.EstimatedDurationMins != tt.estimate {
+			t.Errorf("%s: estimate = %d, want %d", tt.name, task.EstimatedDurationMins, tt.estimate)
+		}
+
+		c, err := db.GetCalibration()
+		if err != nil {
+			t.Fatalf("GetCalibration: %v", err)
+		}
+		if c.Overall == nil || c.Overall.Ratio != 2 {
+			t.Errorf("%s: overall factor = %+v, want a ratio of 2", tt.name, c.Overall)
+		}
+	}
+}
+
+func TestEditedEstimateDropsTheEstimateAsEntered(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < models.MinCalibrationSamples; i++ {
+		finishTask(t, db, &models.CreateTaskRequest{Title: "Write report", EstimatedDurationMins: 30}, 60)
+	}
+	task, err := db.CreateTask(&models.CreateTaskRequest{Title: "Write report", EstimatedDurationMins: 30, ApplyCalibration: true})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		update models.UpdateTaskRequest
+		raw    *int
+	}{
+		{"title changed", models.UpdateTaskRequest{Title: ptr("Write the report")}, ptr(30)},
+		{"estimate changed", models.UpdateTaskRequest{EstimatedDurationMins: ptr(45)}, nil},
+	}
+	for _, tt := range tests {
+		if _, err := db.UpdateTask(task.ID, &tt.update); err != nil {
+			t.Fatalf("%s: UpdateTask: %v", tt.name, err)
+		}
+		var raw *int
+		if err := db.conn.QueryRow(`SELECT raw_estimate_minutes FROM tasks WHERE id = ?`, task.ID).Scan(&raw); err != nil {
+			t.Fatal(err)
+		}
+		if (raw == nil) != (tt.raw == nil) || raw != nil && *raw != *tt.raw {
+			t.Errorf("%s: raw estimate = %v, want %v", tt.name, raw, tt.raw)
+		}
+	}
+}
+
+// ptr returns a pointer to a copy of v
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,241 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// contactColumns are the columns of a contact aliased c
+const contactColumns = `c.id, c.name, c.email, c.phone, c.type, c.notes, c.avatar_url, c.created_at, c.updated_at`
+
+// scanContact reads a row selected with contactColumns, followed by the
+// destinations in extra
+func scanContact(row rowScanner, extra ...interface{}) (*models.Contact, error) {
+	contact := &models.Contact{}
+	var email, phone, contactType, notes, avatarURL sql.NullString
+	dest := append([]interface{}{
+		&contact.ID, &contact.Name, &email, &phone, &contactType, &notes, &avatarURL,
+		&contact.CreatedAt, &contact.UpdatedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	contact.Email = email.String
+	contact.Phone = phone.String
+	contact.Type = contactType.String
+	contact.Notes = notes.String
+	contact.AvatarURL = avatarURL.String
+	return contact, nil
+}
+
+// GetAllContacts retrieves all contacts by name
+func (db *DB) GetAllContacts() ([]models.Contact, error) {
+	rows, err := db.conn.Query(`SELECT ` + contactColumns + ` FROM contacts c ORDER BY c.name COLLATE NOCASE`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contacts: %w", err)
+	}
+	defer rows.Close()
+
+	contacts := []models.Contact{}
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		contacts = append(contacts, *contact)
+	}
+	return contacts, rows.Err()
+}
+
+// GetContact returns a single contact
+func (db *DB) GetContact(id int) (*models.Contact, error) {
+	contact, err := scanContact(db.conn.QueryRow(`SELECT `+contactColumns+` FROM contacts c WHERE c.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("contact %d: %w", id, ErrNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get contact: %w", err)
+	}
+	return contact, nil
+}
+
+// CreateContact adds a contact; it is a person unless another type is given
+func (db *DB) CreateContact(req *models.ContactRequest) (*models.Contact, error) {
+	if req.Name == nil {
+		return nil, fmt.Errorf("%w: contact name is required", ErrInvalid)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	contact := &models.Contact{Type: string(models.ContactPerson)}
+	req.Apply(contact)
+
+	now := time.Now()
+	result, err := db.conn.Exec(`
+		INSERT INTO contacts (name, email, phone, type, notes, avatar_url, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		contact.Name, nullString(contact.Email), nullString(contact.Phone), contact.Type,
+		nullString(contact.Notes), nullString(contact.AvatarURL), now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create contact: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contact ID: %w", err)
+	}
+	return db.GetContact(int(id))
+}
+
+// UpdateContact changes the fields of a contact that were sent
+func (db *DB) UpdateContact(id int, req *models.ContactRequest) (*models.Contact, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	contact, err := db.GetContact(id)
+	if err != nil {
+		return nil, err
+	}
+	req.Apply(contact)
+
+	_, err = db.conn.Exec(`
+		UPDATE contacts SET name = ?, email = ?, phone = ?, type = ?, notes = ?, avatar_url = ?, updated_at = ?
+		WHERE id = ?`,
+		contact.Name, nullString(contact.Email), nullString(contact.Phone), contact.Type,
+		nullString(contact.Notes), nullString(contact.AvatarURL), time.Now(), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update contact: %w", err)
+	}
+	return db.GetContact(id)
+}
+
+// DeleteContact deletes a contact with its threads and its links to tasks.
+// Its attachments go too, unless they also belong to a task.
+func (db *DB) DeleteContact(id int) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := contactExists(tx, id); err != nil {
+		return err
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM task_contacts WHERE contact_id = ?`,
+		`DELETE FROM follow_ups WHERE thread_id IN (SELECT id FROM contact_threads WHERE contact_id = ?)`,
+		`DELETE FROM contact_threads WHERE contact_id = ?`,
+		`DELETE FROM attachments WHERE contact_id = ? AND task_id IS NULL`,
+		`UPDATE attachments SET contact_id = NULL WHERE contact_id = ?`,
+		`DELETE FROM contacts WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return fmt.Errorf("failed to delete contact: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit contact deletion: %w", err)
+	}
+	return nil
+}
+
+// LinkContact links a contact to a task in a role, participant when empty.
+// Linking a contact that already is on the task changes their role.
+func (db *DB) LinkContact(taskID, contactID int, role models.ContactRole) error {
+	if role == "" {
+		role = models.RoleParticipant
+	}
+	if !role.Valid() {
+		return fmt.Errorf("%w: unknown role %q, expected organizer, participant, venue or vendor", ErrInvalid, role)
+	}
+	if _, err := db.taskTitles(taskID); err != nil {
+		return err
+	}
+	if err := contactExists(db.conn, contactID); err != nil {
+		return err
+	}
+
+	_, err := db.conn.Exec(`
+		INSERT INTO task_contacts (task_id, contact_id, role, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (task_id, contact_id) DO UPDATE SET role = excluded.role`,
+		taskID, contactID, role, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to link contact: %w", err)
+	}
+	return nil
+}
+
+// UnlinkContact removes a contact from a task
+func (db *DB) UnlinkContact(taskID, contactID int) error {
+	result, err := db.conn.Exec(`DELETE FROM task_contacts WHERE task_id = ? AND contact_id = ?`, taskID, contactID)
+	if err != nil {
+		return fmt.Errorf("failed to unlink contact: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to unlink contact: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("contact %d on task %d: %w", contactID, taskID, ErrNotFound)
+	}
+	return nil
+}
+
+// GetContactTasks returns the tasks a contact is linked to with their role,
+// open tasks first by nearest deadline
+func (db *DB) GetContactTasks(contactID int) ([]models.ContactTask, error) {
+	if err := contactExists(db.conn, contactID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT tc.task_id, tc.role FROM task_contacts tc
+		JOIN tasks t ON t.id = tc.task_id
+		WHERE tc.contact_id = ?
+		ORDER BY t.status = 'done', COALESCE(t.deadline, '9999-12-31'), t.id`, contactID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contact tasks: %w", err)
+	}
+	type link struct {
+		taskID int
+		role   sql.NullString
+	}
+	var links []link
+	for rows.Next() {
+		var l link
+		if err := rows.Scan(&l.taskID, &l.role); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan contact task: %w", err)
+		}
+		links = append(links, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query contact tasks: %w", err)
+	}
+
+	tasks := []models.ContactTask{}
+	for _, l := range links {
+		task, err := db.GetTask(l.taskID)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, models.ContactTask{Task: *task, Role: models.ContactRole(l.role.String)})
+	}
+	return tasks, nil
+}
+
+// contactExists fails with ErrNotFound for an unknown contact
+func contactExists(q querier, id int) error {
+	var exists bool
+	if err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM contacts WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check contact: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("contact %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"oppgaave/internal/models"
@@ -12,266 +14,86 @@ import (
 )
 
 type DB struct {
-	conn *sql.DB
+	conn  dbConn  // Where statements run, a transaction inside RunBulk
+	sqlDB *sql.DB // The underlying database
 }
 
+var (
+	// ErrNotFound is returned when a requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrInvalid is returned when a request fails validation
+	ErrInvalid = errors.New("invalid request")
+	// ErrConflict is returned when a change conflicts with existing data
+	ErrConflict = errors.New("conflict")
+	// ErrUnavailable is returned when this build lacks a feature, such as
+	// SQLite without full-text search
+	ErrUnavailable = errors.New("unavailable")
+)
+
 // New creates a new database connection and initializes schema
 func New(dbPath string) (*DB, error) {
-	conn, err := sql.Open("sqlite3", dbPath)
+	db, err := Open(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
-
-	db := &DB{conn: conn}
 	
 	if err := db.initSchema(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
 	return db, nil
 }
 
-// initSchema initializes database schema and runs migrations
-func (db *DB) initSchema() error {
-	// First, create core tables
-	if err := db.createCoreTables(); err != nil {
-		return fmt.Errorf("failed to create core tables: %w", err)
+// Open creates a new database connection without touching the schema,
+// for managing migrations by hand
+func Open(dbPath string) (*DB, error) {
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Run migrations to add new columns
-	if err := db.runMigrations(); err != nil {
+	return &DB{conn: sqlConn{conn}, sqlDB: conn}, nil
+}
+
+// initSchema brings the schema up to date by applying pending migrations
+func (db *DB) initSchema() error {
+	applied, err := db.MigrateUp()
+	if err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
-
-	// Insert sample data
-	if err := db.insertSampleData(); err != nil {
-		return fmt.Errorf("failed to insert sample data: %w", err)
+	for _, m := range applied {
+		log.Printf("Applied migration %d: %s", m.Version, m.Name)
 	}
 
 	log.Println("Database schema initialized successfully")
 	return nil
 }
 
-// createCoreTables creates the core database tables
-func (db *DB) createCoreTables() error {
-	coreSchema := `
--- ADHD Task Management System Database Schema
-
--- Tasks table with recursive structure
-CREATE TABLE IF NOT EXISTS tasks (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    title TEXT NOT NULL,
-    description TEXT,
-    parent_id INTEGER, -- For subtasks
-    estimated_duration_minutes INTEGER DEFAULT 30,
-    deadline DATETIME,
-    priority INTEGER DEFAULT 1, -- 1=low, 2=medium, 3=high
-    status TEXT DEFAULT 'pending', -- pending, in_progress, done, blocked
-    tags TEXT, -- JSON array of tags
-    energy_level INTEGER DEFAULT 2, -- 1=low, 2=medium, 3=high energy needed
-    difficulty INTEGER DEFAULT 2, -- 1=easy, 2=medium, 3=hard
-    money_cost INTEGER DEFAULT 0, -- Time budget cost in "coins"
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    completed_at DATETIME,
-    FOREIGN KEY (parent_id) REFERENCES tasks(id)
-);
-
--- Task prerequisites (DAG structure)
-CREATE TABLE IF NOT EXISTS task_prerequisites (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    task_id INTEGER NOT NULL,
-    prerequisite_task_id INTEGER NOT NULL,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (task_id) REFERENCES tasks(id),
-    FOREIGN KEY (prerequisite_task_id) REFERENCES tasks(id),
-    UNIQUE(task_id, prerequisite_task_id)
-);
-
--- Daily budgets for time management
-CREATE TABLE IF NOT EXISTS daily_budgets (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    date DATE NOT NULL UNIQUE,
-    total_budget_coins INTEGER DEFAULT 500, -- Daily budget in "coins"
-    spent_coins INTEGER DEFAULT 0,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-);
-
--- Task assignments to days
-CREATE TABLE IF NOT EXISTS task_schedule (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    task_id INTEGER NOT NULL,
-    scheduled_date DATE NOT NULL,
-    start_time TIME,
-    estimated_end_time TIME,
-    actual_start_time DATETIME,
-    actual_end_time DATETIME,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (task_id) REFERENCES tasks(id)
-);
-
--- User settings and preferences
-CREATE TABLE IF NOT EXISTS settings (
-    key TEXT PRIMARY KEY,
-    value TEXT,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-);
-
--- Contacts for communication and task management
-CREATE TABLE IF NOT EXISTS contacts (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    name TEXT NOT NULL,
-    email TEXT,
-    phone TEXT,
-    type TEXT DEFAULT 'person', -- person, organization, venue
-    notes TEXT,
-    avatar_url TEXT,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-);
-
--- Contact threads for communication history
-CREATE TABLE IF NOT EXISTS contact_threads (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    contact_id INTEGER NOT NULL,
-    task_id INTEGER, -- Optional link to task
-    subject TEXT,
-    message TEXT NOT NULL,
-    thread_type TEXT DEFAULT 'message', -- message, email, call, meeting
-    direction TEXT DEFAULT 'outbound', -- inbound, outbound
-    status TEXT DEFAULT 'sent', -- sent, received, pending, failed
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (contact_id) REFERENCES contacts(id),
-    FOREIGN KEY (task_id) REFERENCES tasks(id)
-);
-
--- Attachments for tasks and events
-CREATE TABLE IF NOT EXISTS attachments (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    task_id INTEGER,
-    contact_id INTEGER,
-    filename TEXT NOT NULL,
-    original_filename TEXT NOT NULL,
-    file_path TEXT NOT NULL,
-    file_size INTEGER,
-    mime_type TEXT,
-    description TEXT,
-    attachment_type TEXT DEFAULT 'document', -- document, image, audio, video, link
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (task_id) REFERENCES tasks(id),
-    FOREIGN KEY (contact_id) REFERENCES contacts(id)
-);
-
--- Task contacts relationship (many-to-many)
-CREATE TABLE IF NOT EXISTS task_contacts (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    task_id INTEGER NOT NULL,
-    contact_id INTEGER NOT NULL,
-    role TEXT DEFAULT 'participant', -- organizer, participant, venue, vendor
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (task_id) REFERENCES tasks(id),
-    FOREIGN KEY (contact_id) REFERENCES contacts(id),
-    UNIQUE(task_id, contact_id)
-);
-`
-
-	if _, err := db.conn.Exec(coreSchema); err != nil {
-		return fmt.Errorf("failed to execute core schema: %w", err)
-	}
-
-	return nil
+// Close closes the database connection
+func (db *DB) Close() error {
+	return db.sqlDB.Close()
 }
 
-// runMigrations adds new columns for existing databases
-func (db *DB) runMigrations() error {
-	migrations := []string{
-		// Add new task columns for radar visualization and events
-		`ALTER TABLE tasks ADD COLUMN task_type TEXT DEFAULT 'task'`,
-		`ALTER TABLE tasks ADD COLUMN event_location TEXT`,
-		`ALTER TABLE tasks ADD COLUMN event_start DATETIME`,
-		`ALTER TABLE tasks ADD COLUMN event_end DATETIME`,
-		`ALTER TABLE tasks ADD COLUMN radar_position_x REAL DEFAULT 0`,
-		`ALTER TABLE tasks ADD COLUMN radar_position_y REAL DEFAULT 0`,
-	}
-
-	for _, migration := range migrations {
-		if _, err := db.conn.Exec(migration); err != nil {
-			// Ignore "duplicate column name" errors - column already exists
-			if !isColumnExistsError(err) {
-				return fmt.Errorf("failed to run migration '%s': %w", migration, err)
-			}
-		}
-	}
-
-	return nil
+// CreateTask creates a new task
+func (db *DB) CreateTask(req *models.CreateTaskRequest) (*models.Task, error) {
+	return db.createTask(req, taskOrigin{})
 }
 
-// isColumnExistsError checks if the error is due to column already existing
-func isColumnExistsError(err error) bool {
-	return err != nil && (
-		err.Error() == "duplicate column name: task_type" ||
-		err.Error() == "duplicate column name: event_location" ||
-		err.Error() == "duplicate column name: event_start" ||
-		err.Error() == "duplicate column name: event_end" ||
-		err.Error() == "duplicate column name: radar_position_x" ||
-		err.Error() == "duplicate column name: radar_position_y")
+// taskOrigin records where a task the application creates by itself came
+// from, written along with the rest of the task
+type taskOrigin struct {
+	seriesID       *int       // Template of the series the task is an occurrence of
+	occurrenceDate *time.Time // Day the occurrence is for
+	icalUID        string     // UID of the calendar entry the task was imported from
 }
 
-// insertSampleData inserts initial settings and sample data
-func (db *DB) insertSampleData() error {
-	sampleData := `
--- Initial settings
-INSERT OR REPLACE INTO settings (key, value) VALUES 
-    ('daily_budget_coins', '500'),
-    ('coin_per_minute', '10'),
-    ('energy_multiplier', '1.5'),
-    ('difficulty_multiplier', '1.3');
-
--- Sample tasks for demonstration
-INSERT OR REPLACE INTO tasks (id, title, description, estimated_duration_minutes, priority, status, energy_level, difficulty, money_cost, task_type, event_start, event_end) VALUES
-    (1, 'Morning Coffee & Journal', 'Start the day with coffee and journaling to set intentions', 15, 1, 'pending', 1, 1, 15, 'task', NULL, NULL),
-    (2, 'Write Project Draft', 'Complete first draft of the project proposal', 120, 3, 'pending', 3, 3, 180, 'task', NULL, NULL),
-    (3, 'Yoga Class', 'Attend morning yoga session for physical and mental wellness', 45, 2, 'pending', 2, 1, 45, 'appointment', '2025-08-12 09:00:00', '2025-08-12 09:45:00'),
-    (4, 'Call Landlord', 'Important call about lease renewal - deadline approaching', 30, 3, 'pending', 2, 2, 60, 'task', NULL, NULL),
-    (5, 'Grocery Shopping', 'Buy ingredients for meal prep', 60, 2, 'pending', 2, 2, 60, 'task', NULL, NULL),
-    (6, 'Meal Prep', 'Prepare meals for the week', 90, 2, 'pending', 3, 2, 90, 'task', NULL, NULL),
-    (7, 'Team Meeting', 'Weekly standup with development team', 60, 2, 'pending', 2, 1, 60, 'meeting', '2025-08-12 14:00:00', '2025-08-12 15:00:00'),
-    (8, 'Concert Planning', 'Plan upcoming jazz concert attendance', 30, 1, 'pending', 1, 1, 30, 'event', '2025-08-15 19:00:00', '2025-08-15 22:00:00');
-
--- Sample contacts
-INSERT OR REPLACE INTO contacts (id, name, email, phone, type, notes) VALUES
-    (1, 'Dr. Sarah Johnson', 'sarah.johnson@yogastudio.com', '+1-555-0123', 'person', 'Yoga instructor'),
-    (2, 'Development Team', 'team@company.com', NULL, 'organization', 'Work team for standups'),
-    (3, 'Jazz Venue', 'info@jazzclub.com', '+1-555-0456', 'venue', 'Downtown jazz club'),
-    (4, 'Property Manager', 'landlord@property.com', '+1-555-0789', 'person', 'Lease renewal contact');
-
--- Link contacts to tasks
-INSERT OR REPLACE INTO task_contacts (task_id, contact_id, role) VALUES
-    (3, 1, 'organizer'), -- Yoga class with instructor
-    (7, 2, 'participant'), -- Team meeting
-    (8, 3, 'venue'); -- Concert at jazz venue
-
--- Add some prerequisites
-INSERT OR REPLACE INTO task_prerequisites (task_id, prerequisite_task_id) VALUES
-    (6, 5), -- Meal prep requires grocery shopping first
-    (2, 1); -- Writing requires coffee/journal first for focus
-`
-
-	if _, err := db.conn.Exec(sampleData); err != nil {
-		return fmt.Errorf("failed to insert sample data: %w", err)
+// createTask creates a task from a request and its origin
+func (db *DB) createTask(req *models.CreateTaskRequest, origin taskOrigin) (*models.Task, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
 	}
 
-	return nil
-}
-
-// Close closes the database connection
-func (db *DB) Close() error {
-	return db.conn.Close()
-}
-
-// CreateTask creates a new task
-func (db *DB) CreateTask(req *models.CreateTaskRequest) (*models.Task, error) {
 	task := &models.Task{
 		Title:                 req.Title,
 		Description:           req.Description,
@@ -287,12 +109,47 @@ func (db *DB) CreateTask(req *models.CreateTaskRequest) (*models.Task, error) {
 		EventStart:            req.EventStart,
 		EventEnd:              req.EventEnd,
 		Status:                models.StatusPending,
+		SeriesID:              origin.seriesID,
+		OccurrenceDate:        origin.occurrenceDate,
 		CreatedAt:             time.Now(),
 		UpdatedAt:             time.Now(),
 	}
+
+	tags, err := models.NormalizeTags(req.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	task.Tags = tags
+
+	// A recurring task is the first occurrence and template of its series
+	if req.Recurrence != nil {
+		if err := req.Recurrence.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		rule := *req.Recurrence
+		task.Recurrence = &rule
+		date := seriesStart(task)
+		task.OccurrenceDate = &date
+	}
 	
+	// Suggest a corrected estimate from past tasks, replacing ours if asked
+	// to. The estimate as entered is kept to keep learning from.
+	calibration, err := db.currentCalibration()
+	if err != nil {
+		return nil, err
+	}
+	task.Calibrate(calibration, false)
+	var rawEstimate *int
+	if req.ApplyCalibration && task.ApplyCalibration() {
+		rawEstimate = &req.EstimatedDurationMins
+	}
+
 	// Calculate money cost
-	task.MoneyCost = task.CalculateMoneyCost()
+	multipliers, err := db.GetCostMultipliers()
+	if err != nil {
+		return nil, err
+	}
+	task.MoneyCost = task.CalculateMoneyCost(multipliers)
 	
 	// Calculate radar position
 	task.CalculateRadarPosition()
@@ -301,14 +158,16 @@ func (db *DB) CreateTask(req *models.CreateTaskRequest) (*models.Task, error) {
 		INSERT INTO tasks (title, description, parent_id, estimated_duration_minutes, 
 			deadline, priority, status, tags, energy_level, difficulty, money_cost,
 			task_type, event_location, event_start, event_end, radar_position_x, radar_position_y,
+			estimate_calibrated, raw_estimate_minutes, recurrence, series_id, occurrence_date, ical_uid,
 			created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	result, err := db.conn.Exec(query, task.Title, task.Description, task.ParentID,
 		task.EstimatedDurationMins, task.Deadline, task.Priority, task.Status,
 		task.Tags, task.EnergyLevel, task.Difficulty, task.MoneyCost,
 		task.TaskType, task.EventLocation, task.EventStart, task.EventEnd,
-		task.RadarPositionX, task.RadarPositionY, task.CreatedAt, task.UpdatedAt)
+		task.RadarPositionX, task.RadarPositionY, rawEstimate != nil, rawEstimate, task.Recurrence,
+		task.SeriesID, dateValue(task.OccurrenceDate), nullString(origin.icalUID), task.CreatedAt, task.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create task: %w", err)
 	}
@@ -319,184 +178,507 @@ func (db *DB) CreateTask(req *models.CreateTaskRequest) (*models.Task, error) {
 	}
 
 	task.ID = int(id)
+	if _, err := setTaskTags(db.conn, task.ID, task.Tags); err != nil {
+		return nil, err
+	}
+	// Load tags
+	if err := db.loadTaskTags(task); err != nil {
+		return nil, err
+	}
 	return task, nil
 }
 
 // GetTask retrieves a task by ID with its prerequisites and subtasks
 func (db *DB) GetTask(id int) (*models.Task, error) {
-	task := &models.Task{}
-	var (
-		parentID sql.NullInt64
-		deadline, eventStart, eventEnd, completedAt sql.NullTime
-		description, eventLocation sql.NullString
-	)
-	
-	query := `
-		SELECT id, title, description, parent_id, estimated_duration_minutes,
-			deadline, priority, status, tags, energy_level, difficulty, money_cost,
-			task_type, event_location, event_start, event_end, radar_position_x, radar_position_y,
-			created_at, updated_at, completed_at
-		FROM tasks WHERE id = ?`
-
-	err := db.conn.QueryRow(query, id).Scan(
-		&task.ID, &task.Title, &description, &parentID,
-		&task.EstimatedDurationMins, &deadline, &task.Priority,
-		&task.Status, &task.Tags, &task.EnergyLevel, &task.Difficulty,
-		&task.MoneyCost, &task.TaskType, &eventLocation, &eventStart,
-		&eventEnd, &task.RadarPositionX, &task.RadarPositionY,
-		&task.CreatedAt, &task.UpdatedAt, &completedAt)
-	if err != nil {
+	task, err := scanTask(db.conn.QueryRow(`SELECT `+taskColumns+` FROM tasks t WHERE t.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("task %d: %w", id, ErrNotFound)
+	} else if err != nil {
 		return nil, fmt.Errorf("failed to get task: %w", err)
 	}
 
-	// Handle nullable fields
-	if parentID.Valid {
-		task.ParentID = &[]int{int(parentID.Int64)}[0]
-	}
-	if deadline.Valid {
-		task.Deadline = &deadline.Time
-	}
-	if description.Valid {
-		task.Description = description.String
-	}
-	if eventLocation.Valid {
-		task.EventLocation = eventLocation.String
-	}
-	if eventStart.Valid {
-		task.EventStart = &eventStart.Time
-	}
-	if eventEnd.Valid {
-		task.EventEnd = &eventEnd.Time
-	}
-	if completedAt.Valid {
-		task.CompletedAt = &completedAt.Time
-	}
-
 	// Load prerequisites
-	if err := db.loadTaskPrerequisites(task); err != nil {
+	if err := db.loadTaskPrerequisites(&task); err != nil {
 		return nil, fmt.Errorf("failed to load prerequisites: %w", err)
 	}
 
 	// Load subtasks
-	if err := db.loadTaskSubtasks(task); err != nil {
+	if err := db.loadTaskSubtasks(&task); err != nil {
 		return nil, fmt.Errorf("failed to load subtasks: %w", err)
 	}
 
 	// Load contacts
-	if err := db.loadTaskContacts(task); err != nil {
+	if err := db.loadTaskContacts(&task); err != nil {
 		return nil, fmt.Errorf("failed to load contacts: %w", err)
 	}
 
+	if err := db.loadTaskTags(&task); err != nil {
+		return nil, err
+	}
+
 	// Load attachments
-	if err := db.loadTaskAttachments(task); err != nil {
+	if err := db.loadTaskAttachments(&task); err != nil {
 		return nil, fmt.Errorf("failed to load attachments: %w", err)
 	}
 
-	return task, nil
+	return &task, nil
 }
 
-// GetAllTasks retrieves all tasks
+// GetAllTasks retrieves all tasks, highest priority first
 func (db *DB) GetAllTasks() ([]models.Task, error) {
-	query := `
-		SELECT id, title, description, parent_id, estimated_duration_minutes,
-			deadline, priority, status, tags, energy_level, difficulty, money_cost,
-			task_type, event_location, event_start, event_end, radar_position_x, radar_position_y,
-			created_at, updated_at, completed_at
-		FROM tasks ORDER BY priority DESC, deadline ASC`
+	tasks, _, err := db.ListTasks(TaskFilter{})
+	return tasks, err
+}
 
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get tasks: %w", err)
+// ListTasks retrieves the tasks matching a filter in its order. With a limit
+// it also returns the cursor of the next page, empty on the last one.
+func (db *DB) ListTasks(filter TaskFilter) ([]models.Task, string, error) {
+	if err := filter.validate(); err != nil {
+		return nil, "", err
 	}
-	defer rows.Close()
 
-	var tasks []models.Task
-	for rows.Next() {
-		var task models.Task
-		var (
-			parentID sql.NullInt64
-			deadline, eventStart, eventEnd, completedAt sql.NullTime
-			description, eventLocation sql.NullString
-		)
-		
-		err := rows.Scan(
-			&task.ID, &task.Title, &description, &parentID,
-			&task.EstimatedDurationMins, &deadline, &task.Priority,
-			&task.Status, &task.Tags, &task.EnergyLevel, &task.Difficulty,
-			&task.MoneyCost, &task.TaskType, &eventLocation, &eventStart,
-			&eventEnd, &task.RadarPositionX, &task.RadarPositionY,
-			&task.CreatedAt, &task.UpdatedAt, &completedAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan task: %w", err)
-		}
+	query := `SELECT ` + taskColumns + ` FROM tasks t`
+	where, args := filter.where()
+	keys := filter.sortKeys()
 
-		// Handle nullable fields
-		if parentID.Valid {
-			task.ParentID = &[]int{int(parentID.Int64)}[0]
-		}
-		if deadline.Valid {
-			task.Deadline = &deadline.Time
+	// Pages continue after the task the cursor points at
+	if filter.Cursor != "" {
+		cursorID, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
 		}
-		if description.Valid {
-			task.Description = description.String
+		var exists bool
+		if err := db.conn.QueryRow(`SELECT EXISTS (SELECT 1 FROM tasks WHERE id = ?)`, cursorID).Scan(&exists); err != nil {
+			return nil, "", fmt.Errorf("failed to look up cursor: %w", err)
 		}
-		if eventLocation.Valid {
-			task.EventLocation = eventLocation.String
+		if !exists {
+			return nil, "", fmt.Errorf("%w: cursor points at a deleted task, start from the first page", ErrInvalid)
 		}
-		if eventStart.Valid {
-			task.EventStart = &eventStart.Time
+
+		query += " JOIN tasks c ON c.id = ?"
+		args = append([]interface{}{cursorID}, args...)
+		if where == "" {
+			where = " WHERE " + after(keys)
+		} else {
+			where += " AND " + after(keys)
 		}
-		if eventEnd.Valid {
-			task.EventEnd = &eventEnd.Time
+	}
+	query += where + orderBy(keys)
+
+	// One extra row tells whether there is a next page
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit+1)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		tasks []models.Task
+		next  string
+	)
+	for rows.Next() {
+		if filter.Limit > 0 && len(tasks) == filter.Limit {
+			next = encodeCursor(tasks[len(tasks)-1].ID)
+			break
 		}
-		if completedAt.Valid {
-			task.CompletedAt = &completedAt.Time
+
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan task: %w", err)
 		}
 
 		// Load prerequisites for each task
 		if err := db.loadTaskPrerequisites(&task); err != nil {
-			return nil, fmt.Errorf("failed to load prerequisites: %w", err)
+			return nil, "", fmt.Errorf("failed to load prerequisites: %w", err)
 		}
 
 		// Load contacts for each task
 		if err := db.loadTaskContacts(&task); err != nil {
-			return nil, fmt.Errorf("failed to load contacts: %w", err)
+			return nil, "", fmt.Errorf("failed to load contacts: %w", err)
+		}
+
+		// Load tags for each task
+		if err := db.loadTaskTags(&task); err != nil {
+			return nil, "", err
 		}
 
 		tasks = append(tasks, task)
 	}
 
-	return tasks, nil
+	return tasks, next, rows.Err()
 }
 
-// UpdateTaskStatus updates a task's status
+// UpdateTaskStatus updates a task's status. Finishing or reopening a task
+// unblocks or blocks its dependents in the same transaction, moving in and
+// out of in_progress opens and closes a time entry in task_schedule, the
+// coins spent are booked on the budget ledger, and every change is recorded
+// in task_status_history. Finishing a recurring task creates its next
+// occurrence, still within the same transaction.
 func (db *DB) UpdateTaskStatus(id int, status models.TaskStatus) error {
+	if !status.Valid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalid, status)
+	}
+
+	return db.inTransaction(func(tx *DB) error {
+		return tx.updateTaskStatus(id, status)
+	})
+}
+
+// updateTaskStatus changes the status of a task with all that comes with it
+func (db *DB) updateTaskStatus(id int, status models.TaskStatus) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		title     string
+		oldStatus models.TaskStatus
+	)
+	err = tx.QueryRow(`SELECT title, status FROM tasks WHERE id = ?`, id).Scan(&title, &oldStatus)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("task %d: %w", id, ErrNotFound)
+	} else if err != nil {
+		return fmt.Errorf("failed to get task status: %w", err)
+	}
+
+	now := time.Now()
 	var completedAt *time.Time
 	if status == models.StatusDone {
-		now := time.Now()
 		completedAt = &now
 	}
 
 	query := `UPDATE tasks SET status = ?, completed_at = ?, updated_at = ? WHERE id = ?`
-	_, err := db.conn.Exec(query, status, completedAt, time.Now(), id)
+	_, err = tx.Exec(query, status, completedAt, now, id)
 	if err != nil {
 		return fmt.Errorf("failed to update task status: %w", err)
 	}
 
+	if oldStatus != status {
+		if err := recordStatusChange(tx, id, oldStatus, status, nil, ""); err != nil {
+			return err
+		}
+
+		// Starting a task opens a time entry, leaving in_progress closes it
+		var workedMins float64
+		switch {
+		case status == models.StatusInProgress:
+			err = openTimeEntry(tx, id, now)
+		case oldStatus == models.StatusInProgress:
+			workedMins, err = closeTimeEntries(tx, id, now)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := chargeStatusChange(tx, id, oldStatus, status, workedMins, now); err != nil {
+			return err
+		}
+
+		switch {
+		case status == models.StatusDone:
+			err = refreshDependents(tx, id, title+" was finished")
+		case oldStatus == models.StatusDone:
+			err = refreshDependents(tx, id, title+" was reopened")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit status update: %w", err)
+	}
+
+	// Finishing an occurrence of a recurring task brings on the next one
+	if status == models.StatusDone && oldStatus != status {
+		return db.generateNextOccurrence(id)
+	}
+
 	return nil
 }
 
+// UpdateTask applies a partial update to a task and recalculates its cost and
+// radar position. The fields and the status change in one transaction.
+func (db *DB) UpdateTask(id int, req *models.UpdateTaskRequest) (*models.Task, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	var task *models.Task
+	err := db.inTransaction(func(tx *DB) error {
+		current, err := tx.GetTask(id)
+		if err != nil {
+			return err
+		}
+
+		req.Apply(current)
+		if err := tx.saveTask(current, req.EstimatedDurationMins != nil); err != nil {
+			return err
+		}
+
+		if req.Status != nil && *req.Status != current.Status {
+			if err := tx.UpdateTaskStatus(id, *req.Status); err != nil {
+				return err
+			}
+		}
+
+		task, err = tx.GetTask(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// ReplaceTask overwrites all editable fields of a task, keeping its status,
+// in one transaction. The request is checked like that of a new task, with
+// the same defaults.
+func (db *DB) ReplaceTask(id int, req *models.CreateTaskRequest) (*models.Task, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	var task *models.Task
+	err := db.inTransaction(func(tx *DB) error {
+		current, err := tx.GetTask(id)
+		if err != nil {
+			return err
+		}
+
+		req.ApplyTo(current)
+		if err := tx.saveTask(current, true); err != nil {
+			return err
+		}
+
+		task, err = tx.GetTask(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// saveTask validates and writes the editable fields of an existing task.
+// estimateEdited means the estimate was just entered by hand, so it no longer
+// includes a previously applied calibration.
+func (db *DB) saveTask(task *models.Task, estimateEdited bool) error {
+	if err := task.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if task.ParentID != nil {
+		if err := db.checkParent(task.ID, *task.ParentID); err != nil {
+			return err
+		}
+	}
+	tags, err := models.NormalizeTags(task.Tags)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	task.Tags = tags
+
+	calibrated := false
+	if !estimateEdited {
+		var err error
+		if calibrated, err = db.isEstimateCalibrated(task.ID); err != nil {
+			return err
+		}
+	}
+	calibration, err := db.currentCalibration()
+	if err != nil {
+		return err
+	}
+	task.Calibrate(calibration, calibrated)
+
+	multipliers, err := db.GetCostMultipliers()
+	if err != nil {
+		return err
+	}
+	task.MoneyCost = task.CalculateMoneyCost(multipliers)
+	task.CalculateRadarPosition()
+	task.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE tasks SET title = ?, description = ?, parent_id = ?, estimated_duration_minutes = ?,
+			deadline = ?, priority = ?, tags = ?, energy_level = ?, difficulty = ?, money_cost = ?,
+			task_type = ?, event_location = ?, event_start = ?, event_end = ?,
+			radar_position_x = ?, radar_position_y = ?, estimate_calibrated = ?,
+			raw_estimate_minutes = CASE WHEN ? THEN raw_estimate_minutes END, updated_at = ?
+		WHERE id = ?`
+
+	_, err = db.conn.Exec(query, task.Title, task.Description, task.ParentID,
+		task.EstimatedDurationMins, task.Deadline, task.Priority, task.Tags,
+		task.EnergyLevel, task.Difficulty, task.MoneyCost,
+		task.TaskType, task.EventLocation, task.EventStart, task.EventEnd,
+		task.RadarPositionX, task.RadarPositionY, calibrated, calibrated, task.UpdatedAt, task.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
+	task.Tags, err = setTaskTags(db.conn, task.ID, task.Tags)
+	return err
+}
+
+// checkParent verifies that parentID exists and is not the task itself or one of its descendants
+func (db *DB) checkParent(taskID, parentID int) error {
+	if taskID == parentID {
+		return fmt.Errorf("%w: task %d cannot be its own parent", ErrInvalid, taskID)
+	}
+
+	current := parentID
+	for {
+		var next sql.NullInt64
+		err := db.conn.QueryRow(`SELECT parent_id FROM tasks WHERE id = ?`, current).Scan(&next)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("parent task %d: %w", current, ErrNotFound)
+		} else if err != nil {
+			return fmt.Errorf("failed to check parent: %w", err)
+		}
+		if !next.Valid {
+			return nil
+		}
+		if int(next.Int64) == taskID {
+			return fmt.Errorf("%w: task %d is an ancestor of task %d", ErrInvalid, taskID, parentID)
+		}
+		current = int(next.Int64)
+	}
+}
+
+// DeleteTask deletes a task together with its prerequisite edges, contact links,
+// schedule entries, attachments and status history. The coins spent on it stay
+// on the budget ledger, detached from the task and noted with its title. A task
+// with subtasks is only deleted when cascade is set, in which case the whole
+// subtree goes with it.
+func (db *DB) DeleteTask(id int, cascade bool) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM tasks WHERE id = ?`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check task: %w", err)
+	}
+	if exists == 0 {
+		return fmt.Errorf("task %d: %w", id, ErrNotFound)
+	}
+
+	rows, err := tx.Query(`
+		WITH RECURSIVE subtree(id) AS (
+			SELECT ?
+			UNION ALL
+			SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id = s.id
+		)
+		SELECT id FROM subtree`, id)
+	if err != nil {
+		return fmt.Errorf("failed to query subtasks: %w", err)
+	}
+	var ids []interface{}
+	for rows.Next() {
+		var taskID int
+		if err := rows.Scan(&taskID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan subtask: %w", err)
+		}
+		ids = append(ids, taskID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query subtasks: %w", err)
+	}
+
+	if len(ids) > 1 && !cascade {
+		return fmt.Errorf("%w: task %d has %d subtasks", ErrConflict, id, len(ids)-1)
+	}
+
+	in := placeholders(len(ids))
+	dependents, err := tx.Query(`
+		SELECT DISTINCT tp.task_id, t.title FROM task_prerequisites tp
+		JOIN tasks t ON t.id = tp.prerequisite_task_id
+		WHERE tp.prerequisite_task_id IN (`+in+`) AND tp.task_id NOT IN (`+in+`)`,
+		append(append([]interface{}{}, ids...), ids...)...)
+	if err != nil {
+		return fmt.Errorf("failed to query dependents: %w", err)
+	}
+	unblock := make(map[int]string)
+	for dependents.Next() {
+		var (
+			dependentID int
+			title       string
+		)
+		if err := dependents.Scan(&dependentID, &title); err != nil {
+			dependents.Close()
+			return fmt.Errorf("failed to scan dependent: %w", err)
+		}
+		unblock[dependentID] = title
+	}
+	dependents.Close()
+
+	statements := []string{
+		`DELETE FROM task_prerequisites WHERE task_id IN (` + in + `) OR prerequisite_task_id IN (` + in + `)`,
+		`DELETE FROM task_contacts WHERE task_id IN (` + in + `)`,
+		`DELETE FROM task_tags WHERE task_id IN (` + in + `)`,
+		`DELETE FROM task_schedule WHERE task_id IN (` + in + `)`,
+		`DELETE FROM attachments WHERE task_id IN (` + in + `)`,
+		`DELETE FROM task_status_history WHERE task_id IN (` + in + `)`,
+		`UPDATE task_status_history SET cause_task_id = NULL WHERE cause_task_id IN (` + in + `)`,
+		`UPDATE budget_transactions SET task_id = NULL,
+			note = COALESCE(NULLIF(note, ''), (SELECT title FROM tasks WHERE tasks.id = budget_transactions.task_id))
+			WHERE task_id IN (` + in + `)`,
+		`UPDATE contact_threads SET task_id = NULL WHERE task_id IN (` + in + `)`,
+		`UPDATE follow_ups SET task_id = NULL WHERE task_id IN (` + in + `)`,
+		`UPDATE tasks SET series_id = NULL WHERE series_id IN (` + in + `)`,
+		`DELETE FROM tasks WHERE id IN (` + in + `)`,
+	}
+	for _, stmt := range statements {
+		args := ids
+		if strings.Count(stmt, "?") > len(ids) {
+			args = append(append([]interface{}{}, ids...), ids...)
+		}
+		if _, err := tx.Exec(stmt, args...); err != nil {
+			return fmt.Errorf("failed to delete task: %w", err)
+		}
+	}
+
+	for dependentID, title := range unblock {
+		if err := refreshBlocked(tx, dependentID, nil, title+" was deleted"); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit task deletion: %w", err)
+	}
+
+	return nil
+}
+
+// placeholders returns n comma-separated SQL placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
 // GetDailyBudget gets or creates a daily budget for the given date
 func (db *DB) GetDailyBudget(date time.Time) (*models.DailyBudget, error) {
 	dateStr := date.Format("2006-01-02")
 	
 	budget := &models.DailyBudget{}
-	query := `SELECT id, date, total_budget_coins, spent_coins, created_at, updated_at 
+	var override sql.NullInt64
+	query := `SELECT id, date, total_budget_coins, spent_coins, budget_override, created_at, updated_at 
 		FROM daily_budgets WHERE date = ?`
 
 	err := db.conn.QueryRow(query, dateStr).Scan(
 		&budget.ID, &budget.Date, &budget.TotalBudgetCoins,
-		&budget.SpentCoins, &budget.CreatedAt, &budget.UpdatedAt)
+		&budget.SpentCoins, &override, &budget.CreatedAt, &budget.UpdatedAt)
 	
 	if err == sql.ErrNoRows {
 		// Create new budget for the day
@@ -504,19 +686,30 @@ func (db *DB) GetDailyBudget(date time.Time) (*models.DailyBudget, error) {
 	} else if err != nil {
 		return nil, fmt.Errorf("failed to get daily budget: %w", err)
 	}
+	budget.IsOverride = override.Valid
 
 	return budget, nil
 }
 
-// CreateDailyBudget creates a new daily budget
+// CreateDailyBudget creates a new daily budget sized by the settings for that weekday
 func (db *DB) CreateDailyBudget(date time.Time) (*models.DailyBudget, error) {
 	dateStr := date.Format("2006-01-02")
 	now := time.Now()
+
+	total, err := db.budgetCoinsFor(date)
+	if err != nil {
+		return nil, err
+	}
+
+	spent, err := spentCoins(db.conn, date)
+	if err != nil {
+		return nil, err
+	}
 	
 	query := `INSERT INTO daily_budgets (date, total_budget_coins, spent_coins, created_at, updated_at)
-		VALUES (?, 500, 0, ?, ?)`
+		VALUES (?, ?, ?, ?, ?)`
 	
-	result, err := db.conn.Exec(query, dateStr, now, now)
+	result, err := db.conn.Exec(query, dateStr, total, spent, now, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create daily budget: %w", err)
 	}
@@ -529,8 +722,8 @@ func (db *DB) CreateDailyBudget(date time.Time) (*models.DailyBudget, error) {
 	return &models.DailyBudget{
 		ID:               int(id),
 		Date:            date,
-		TotalBudgetCoins: 500,
-		SpentCoins:      0,
+		TotalBudgetCoins: total,
+		SpentCoins:      spent,
 		CreatedAt:       now,
 		UpdatedAt:       now,
 	}, nil
@@ -538,11 +731,7 @@ func (db *DB) CreateDailyBudget(date time.Time) (*models.DailyBudget, error) {
 
 // loadTaskPrerequisites loads prerequisites for a task
 func (db *DB) loadTaskPrerequisites(task *models.Task) error {
-	query := `
-		SELECT t.id, t.title, t.description, t.parent_id, t.estimated_duration_minutes,
-			t.deadline, t.priority, t.status, t.tags, t.energy_level, t.difficulty, 
-			t.money_cost, t.task_type, t.event_location, t.event_start, t.event_end,
-			t.radar_position_x, t.radar_position_y, t.created_at, t.updated_at, t.completed_at
+	query := `SELECT ` + taskColumns + `
 		FROM tasks t
 		JOIN task_prerequisites tp ON t.id = tp.prerequisite_task_id
 		WHERE tp.task_id = ?`
@@ -555,64 +744,20 @@ func (db *DB) loadTaskPrerequisites(task *models.Task) error {
 
 	var prerequisites []models.Task
 	for rows.Next() {
-		var prereq models.Task
-		var (
-			parentID sql.NullInt64
-			deadline, eventStart, eventEnd, completedAt sql.NullTime
-			description, eventLocation sql.NullString
-		)
-		
-		err := rows.Scan(
-			&prereq.ID, &prereq.Title, &description, &parentID,
-			&prereq.EstimatedDurationMins, &deadline, &prereq.Priority,
-			&prereq.Status, &prereq.Tags, &prereq.EnergyLevel, &prereq.Difficulty,
-			&prereq.MoneyCost, &prereq.TaskType, &eventLocation, &eventStart,
-			&eventEnd, &prereq.RadarPositionX, &prereq.RadarPositionY,
-			&prereq.CreatedAt, &prereq.UpdatedAt, &completedAt)
+		prereq, err := scanTask(rows)
 		if err != nil {
 			return fmt.Errorf("failed to scan prerequisite: %w", err)
 		}
-
-		// Handle nullable fields
-		if parentID.Valid {
-			prereq.ParentID = &[]int{int(parentID.Int64)}[0]
-		}
-		if deadline.Valid {
-			prereq.Deadline = &deadline.Time
-		}
-		if description.Valid {
-			prereq.Description = description.String
-		}
-		if eventLocation.Valid {
-			prereq.EventLocation = eventLocation.String
-		}
-		if eventStart.Valid {
-			prereq.EventStart = &eventStart.Time
-		}
-		if eventEnd.Valid {
-			prereq.EventEnd = &eventEnd.Time
-		}
-		if completedAt.Valid {
-			prereq.CompletedAt = &completedAt.Time
-		}
-		
 		prerequisites = append(prerequisites, prereq)
 	}
 
 	task.Prerequisites = prerequisites
-	return nil
+	return rows.Err()
 }
 
 // loadTaskSubtasks loads subtasks for a task
 func (db *DB) loadTaskSubtasks(task *models.Task) error {
-	query := `
-		SELECT id, title, description, parent_id, estimated_duration_minutes,
-			deadline, priority, status, tags, energy_level, difficulty, money_cost,
-			task_type, event_location, event_start, event_end, radar_position_x, radar_position_y,
-			created_at, updated_at, completed_at
-		FROM tasks WHERE parent_id = ?`
-
-	rows, err := db.conn.Query(query, task.ID)
+	rows, err := db.conn.Query(`SELECT `+taskColumns+` FROM tasks t WHERE t.parent_id = ?`, task.ID)
 	if err != nil {
 		return fmt.Errorf("failed to query subtasks: %w", err)
 	}
@@ -620,61 +765,24 @@ func (db *DB) loadTaskSubtasks(task *models.Task) error {
 
 	var subtasks []models.Task
 	for rows.Next() {
-		var subtask models.Task
-		var (
-			parentID sql.NullInt64
-			deadline, eventStart, eventEnd, completedAt sql.NullTime
-			description, eventLocation sql.NullString
-		)
-		
-		err := rows.Scan(
-			&subtask.ID, &subtask.Title, &description, &parentID,
-			&subtask.EstimatedDurationMins, &deadline, &subtask.Priority,
-			&subtask.Status, &subtask.Tags, &subtask.EnergyLevel, &subtask.Difficulty,
-			&subtask.MoneyCost, &subtask.TaskType, &eventLocation, &eventStart,
-			&eventEnd, &subtask.RadarPositionX, &subtask.RadarPositionY,
-			&subtask.CreatedAt, &subtask.UpdatedAt, &completedAt)
+		subtask, err := scanTask(rows)
 		if err != nil {
 			return fmt.Errorf("failed to scan subtask: %w", err)
 		}
-
-		// Handle nullable fields
-		if parentID.Valid {
-			subtask.ParentID = &[]int{int(parentID.Int64)}[0]
-		}
-		if deadline.Valid {
-			subtask.Deadline = &deadline.Time
-		}
-		if description.Valid {
-			subtask.Description = description.String
-		}
-		if eventLocation.Valid {
-			subtask.EventLocation = eventLocation.String
-		}
-		if eventStart.Valid {
-			subtask.EventStart = &eventStart.Time
-		}
-		if eventEnd.Valid {
-			subtask.EventEnd = &eventEnd.Time
-		}
-		if completedAt.Valid {
-			subtask.CompletedAt = &completedAt.Time
-		}
-		
 		subtasks = append(subtasks, subtask)
 	}
 
 	task.Subtasks = subtasks
-	return nil
+	return rows.Err()
 }
 
 // loadTaskContacts loads contacts associated with a task
 func (db *DB) loadTaskContacts(task *models.Task) error {
-	query := `
-		SELECT c.id, c.name, c.email, c.phone, c.type, c.notes, c.avatar_url, c.created_at, c.updated_at
+	query := `SELECT ` + contactColumns + `, tc.role
 		FROM contacts c
 		JOIN task_contacts tc ON c.id = tc.contact_id
-		WHERE tc.task_id = ?`
+		WHERE tc.task_id = ?
+		ORDER BY tc.id`
 
 	rows, err := db.conn.Query(query, task.ID)
 	if err != nil {
@@ -684,140 +792,15 @@ func (db *DB) loadTaskContacts(task *models.Task) error {
 
 	var contacts []models.Contact
 	for rows.Next() {
-		var contact models.Contact
-		var (
-			email, phone, notes, avatarURL sql.NullString
-		)
-		
-		err := rows.Scan(
-			&contact.ID, &contact.Name, &email, &phone,
-			&contact.Type, &notes, &avatarURL,
-			&contact.CreatedAt, &contact.UpdatedAt)
+		var role sql.NullString
+		contact, err := scanContact(rows, &role)
 		if err != nil {
 			return fmt.Errorf("failed to scan contact: %w", err)
 		}
-
-		// Handle nullable fields
-		if email.Valid {
-			contact.Email = email.String
-		}
-		if phone.Valid {
-			contact.Phone = phone.String
-		}
-		if notes.Valid {
-			contact.Notes = notes.String
-		}
-		if avatarURL.Valid {
-			contact.AvatarURL = avatarURL.String
-		}
-		
-		contacts = append(contacts, contact)
+		contact.Role = role.String
+		contacts = append(contacts, *contact)
 	}
 
 	task.Contacts = contacts
-	return nil
+	return rows.Err()
 }
-
-// loadTaskAttachments loads attachments for a task
-func (db *DB) loadTaskAttachments(task *models.Task) error {
-	query := `
-		SELECT id, task_id, contact_id, filename, original_filename, file_path,
-			file_size, mime_type, description, attachment_type, created_at
-		FROM attachments WHERE task_id = ?`
-
-	rows, err := db.conn.Query(query, task.ID)
-	if err != nil {
-		return fmt.Errorf("failed to query attachments: %w", err)
-	}
-	defer rows.Close()
-
-	var attachments []models.Attachment
-	for rows.Next() {
-		var attachment models.Attachment
-		err := rows.Scan(
-			&attachment.ID, &attachment.TaskID, &attachment.ContactID,
-			&attachment.Filename, &attachment.OriginalFilename, &attachment.FilePath,
-			&attachment.FileSize, &attachment.MimeType, &attachment.Description,
-			&attachment.AttachmentType, &attachment.CreatedAt)
-		if err != nil {
-			return fmt.Errorf("failed to scan attachment: %w", err)
-		}
-		attachments = append(attachments, attachment)
-	}
-
-	task.Attachments = attachments
-	return nil
-}
-
-// GetAllContacts retrieves all contacts
-func (db *DB) GetAllContacts() ([]models.Contact, error) {
-	query := `SELECT id, name, email, phone, type, notes, avatar_url, created_at, updated_at FROM contacts ORDER BY name`
-
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get contacts: %w", err)
-	}
-	defer rows.Close()
-
-	var contacts []models.Contact
-	for rows.Next() {
-		var contact models.Contact
-		var (
-			email, phone, notes, avatarURL sql.NullString
-		)
-		
-		err := rows.Scan(
-			&contact.ID, &contact.Name, &email, &phone,
-			&contact.Type, &notes, &avatarURL,
-			&contact.CreatedAt, &contact.UpdatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan contact: %w", err)
-		}
-
-		// Handle nullable fields
-		if email.Valid {
-			contact.Email = email.String
-		}
-		if phone.Valid {
-			contact.Phone = phone.String
-		}
-		if notes.Valid {
-			contact.Notes = notes.String
-		}
-		if avatarURL.Valid {
-			contact.AvatarURL = avatarURL.String
-		}
-		
-		contacts = append(contacts, contact)
-	}
-
-	return contacts, nil
-}
-
-// GetContactThreads retrieves communication threads for a contact
-func (db *DB) GetContactThreads(contactID int) ([]models.ContactThread, error) {
-	query := `
-		SELECT id, contact_id, task_id, subject, message, thread_type, direction, status, created_at
-		FROM contact_threads WHERE contact_id = ? ORDER BY created_at DESC`
-
-	rows, err := db.conn.Query(query, contactID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get contact threads: %w", err)
-	}
-	defer rows.Close()
-
-	var threads []models.ContactThread
-	for rows.Next() {
-		var thread models.ContactThread
-		err := rows.Scan(
-			&thread.ID, &thread.ContactID, &thread.TaskID, &thread.Subject,
-			&thread.Message, &thread.ThreadType, &thread.Direction,
-			&thread.Status, &thread.CreatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan thread: %w", err)
-		}
-		threads = append(threads, thread)
-	}
-
-	return threads, nil
-}
\ No newline at end of file
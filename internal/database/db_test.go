@@ -0,0 +1,51 @@
+package database
+
+import (
+	"testing"
+
+	"oppgaave/internal/models"
+)
+
+// TestTaskEditsAreAllOrNothing checks that an edit failing after the task row
+// was written leaves the row and its tags as they were
+func TestTaskEditsAreAllOrNothing(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(db *DB, id int) error
+	}{
+		{"PUT", func(db *DB, id int) error {
+			_, err := db.ReplaceTask(id, &models.CreateTaskRequest{Title: "Renamed", Tags: []string{"boom"}})
+			return err
+		}},
+		{"PATCH", func(db *DB, id int) error {
+			title, tags := "Renamed", []string{"boom"}
+			_, err := db.UpdateTask(id, &models.UpdateTaskRequest{Title: &title, Tags: &tags})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			task, err := db.CreateTask(&models.CreateTaskRequest{Title: "Pay rent", Tags: []string{"home"}})
+			if err != nil {
+				t.Fatalf("CreateTask: %v", err)
+			}
+			// Tagging fails once the task row has been updated
+			if _, err := db.conn.Exec(`CREATE TRIGGER fail_tag BEFORE INSERT ON tags WHEN NEW.name = 'boom'
+				BEGIN SELECT RAISE(ABORT, 'boom'); END`); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.edit(db, task.ID); err == nil {
+				t.Fatal("edit succeeded, want an error")
+			}
+			got, err := db.GetTask(task.ID)
+			if err != nil {
+				t.Fatalf("GetTask: %v", err)
+			}
+			if got.Title != "Pay rent" || len(got.Tags) != 1 || got.Tags[0] != "home" {
+				t.Errorf("task = %q tagged %v, want it unchanged", got.Title, got.Tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package database
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// TaskSort is a field tasks can be listed by
+type TaskSort string
+
+const (
+	SortPriority TaskSort = "priority" // Then by nearest deadline
+	SortDeadline TaskSort = "deadline" // Tasks without a deadline count as due last
+	SortCreated  TaskSort = "created"
+	SortUpdated  TaskSort = "updated"
+	SortTitle    TaskSort = "title"
+	SortDuration TaskSort = "duration"
+)
+
+// sortColumns holds the expression of each sort field, with %[1]s for the
+// table alias, and whether it sorts descending by default
+var sortColumns = map[TaskSort]struct {
+	expr string
+	desc bool
+}{
+	SortPriority: {"%[1]s.priority", true},
+	SortDeadline: {"COALESCE(%[1]s.deadline, '9999-12-31')", false},
+	SortCreated:  {"%[1]s.created_at", true},
+	SortUpdated:  {"%[1]s.updated_at", true},
+	SortTitle:    {"%[1]s.title COLLATE NOCASE", false},
+	SortDuration: {"%[1]s.estimated_duration_minutes", false},
+}
+
+// TaskFilter narrows down, orders and pages the tasks listed by ListTasks.
+// The zero value lists every task by priority.
+type TaskFilter struct {
+	Statuses         []models.TaskStatus // Only these statuses, empty for all
+	TaskTypes        []models.TaskType   // Only these task types, empty for all
+	Tags             []string            // Only tasks with any of these tags, empty for all
+	AllTags          bool                // Tasks must have every one of Tags instead
+	MinPriority      int                 // Lowest priority 1-3, 0 for any
+	MaxPriority      int                 // Highest priority 1-3, 0 for any
+	EnergyLevel      int                 // Only this energy level 1-3, 0 for any
+	DeadlineBefore   *time.Time          // Only tasks due before this time
+	DeadlineAfter    *time.Time          // Only tasks due at or after this time
+	ParentID         *int                // Only subtasks of this task
+	TopLevel         bool                // Only tasks without a parent
+	HasPrerequisites *bool               // Only tasks with, or without, prerequisites
+
+	Sort       TaskSort // Field to order by, priority when empty
+	Descending *bool    // Direction, the field's natural one when nil
+	Limit      int      // Maximum number of tasks, 0 for all
+	Cursor     string   // Where the previous page ended, from its next cursor
+}
+
+// validate checks the ranges and the sort field
+func (f *TaskFilter) validate() error {
+	for _, status := range f.Statuses {
+		if !status.Valid() {
+			return fmt.Errorf("%w: unknown status %q", ErrInvalid, status)
+		}
+	}
+	for _, taskType := range f.TaskTypes {
+		if !taskType.Valid() {
+			return fmt.Errorf("%w: unknown task type %q", ErrInvalid, taskType)
+		}
+	}
+	for _, level := range []struct {
+		name  string
+		value int
+	}{
+		{"min_priority", f.MinPriority},
+		{"max_priority", f.MaxPriority},
+		{"energy", f.EnergyLevel},
+	} {
+		if level.value < 0 || level.value > 3 {
+			return fmt.Errorf("%w: %s must be between 1 and 3", ErrInvalid, level.name)
+		}
+	}
+	if f.MinPriority > 0 && f.MaxPriority > 0 && f.MinPriority > f.MaxPriority {
+		return fmt.Errorf("%w: min_priority is above max_priority", ErrInvalid)
+	}
+	if f.ParentID != nil && f.TopLevel {
+		return fmt.Errorf("%w: a task cannot both have a parent and be top level", ErrInvalid)
+	}
+	if f.Sort != "" {
+		if _, ok := sortColumns[f.Sort]; !ok {
+			return fmt.Errorf("%w: cannot sort by %q", ErrInvalid, f.Sort)
+		}
+	}
+	if f.Limit < 0 {
+		return fmt.Errorf("%w: limit must be positive", ErrInvalid)
+	}
+	return nil
+}
+
+// where builds the conditions of the filter on the tasks aliased t
+func (f *TaskFilter) where() (string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	in := func(column string, values []string) {
+		conds = append(conds, column+" IN ("+placeholders(len(values))+")")
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+
+	if len(f.Statuses) > 0 {
+		values := make([]string, len(f.Statuses))
+		for i, status := range f.Statuses {
+			values[i] = string(status)
+		}
+		in("t.status", values)
+	}
+	if len(f.TaskTypes) > 0 {
+		values := make([]string, len(f.TaskTypes))
+		for i, taskType := range f.TaskTypes {
+			values[i] = string(taskType)
+		}
+		in("t.task_type", values)
+	}
+	if len(f.Tags) > 0 {
+		tags := uniqueStrings(f.Tags)
+		tagged := "FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id WHERE tt.task_id = t.id AND tg.name IN (" + placeholders(len(tags)) + ")"
+		if f.AllTags {
+			conds = append(conds, "(SELECT COUNT(*) "+tagged+") = ?")
+		} else {
+			conds = append(conds, "EXISTS (SELECT 1 "+tagged+")")
+		}
+		for _, tag := range tags {
+			args = append(args, tag)
+		}
+		if f.AllTags {
+			args = append(args, len(tags))
+		}
+	}
+	if f.MinPriority > 0 {
+		conds = append(conds, "t.priority >= ?")
+		args = append(args, f.MinPriority)
+	}
+	if f.MaxPriority > 0 {
+		conds = append(conds, "t.priority <= ?")
+		args = append(args, f.MaxPriority)
+	}
+	if f.EnergyLevel > 0 {
+		conds = append(conds, "t.energy_level = ?")
+		args = append(args, f.EnergyLevel)
+	}
+	// julianday compares the stored times across time zones
+	if f.DeadlineBefore != nil {
+		conds = append(conds, "julianday(t.deadline) < julianday(?)")
+		args = append(args, *f.DeadlineBefore)
+	}
+	if f.DeadlineAfter != nil {
+		conds = append(conds, "julianday(t.deadline) >= julianday(?)")
+		args = append(args, *f.DeadlineAfter)
+	}
+	if f.ParentID != nil {
+		conds = append(conds, "t.parent_id = ?")
+		args = append(args, *f.ParentID)
+	}
+	if f.TopLevel {
+		conds = append(conds, "t.parent_id IS NULL")
+	}
+	if f.HasPrerequisites != nil {
+		exists := "EXISTS (SELECT 1 FROM task_prerequisites tp WHERE tp.task_id = t.id)"
+		if !*f.HasPrerequisites {
+			exists = "NOT " + exists
+		}
+		conds = append(conds, exists)
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// sortKey is one expression tasks are ordered by
+type sortKey struct {
+	expr string
+	desc bool
+}
+
+// sortKeys returns what the filter orders by, ending with the task ID so
+// the order is total and a cursor points at one place in it
+func (f *TaskFilter) sortKeys() []sortKey {
+	field := f.Sort
+	if field == "" {
+		field = SortPriority
+	}
+	column := sortColumns[field]
+	desc := column.desc
+	if f.Descending != nil {
+		desc = *f.Descending
+	}
+
+	keys := []sortKey{{column.expr, desc}}
+	if field == SortPriority {
+		keys = append(keys, sortKey{sortColumns[SortDeadline].expr, false})
+	}
+	return append(keys, sortKey{"%[1]s.id", false})
+}
+
+// orderBy builds the ORDER BY clause for the tasks aliased t
+func orderBy(keys []sortKey) string {
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		terms[i] = fmt.Sprintf(key.expr, "t")
+		if key.desc {
+			terms[i] += " DESC"
+		}
+	}
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// after builds the condition for tasks ordered after the cursor task,
+// aliased c, comparing the sort keys one by one
+func after(keys []sortKey) string {
+	var alternatives []string
+	for i, key := range keys {
+		var terms []string
+		for _, prev := range keys[:i] {
+			terms = append(terms, fmt.Sprintf(prev.expr, "t")+" = "+fmt.Sprintf(prev.expr, "c"))
+		}
+		op := " > "
+		if key.desc {
+			op = " < "
+		}
+		terms = append(terms, fmt.Sprintf(key.expr, "t")+op+fmt.Sprintf(key.expr, "c"))
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
+
+// encodeCursor makes the opaque cursor for a page ending at a task
+func encodeCursor(taskID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("task:" + strconv.Itoa(taskID)))
+}
+
+// decodeCursor returns the task ID a cursor points at
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if id, ok := strings.CutPrefix(string(raw), "task:"); ok {
+			if n, err := strconv.Atoi(id); err == nil {
+				return n, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: malformed cursor %q", ErrInvalid, cursor)
+}
+
+// uniqueStrings drops repeated values, ignoring case like tag names do
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		if key := strings.ToLower(v); !seen[key] {
+			seen[key] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
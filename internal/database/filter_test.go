@@ -0,0 +1,156 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"oppgaave/internal/models"
+)
+
+func TestAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []sortKey
+		want string
+	}{
+		{
+			"id only",
+			[]sortKey{{"%[1]s.id", false}},
+			"((t.id > c.id))",
+		},
+		{
+			"descending then id",
+			[]sortKey{{"%[1]s.priority", true}, {"%[1]s.id", false}},
+			"((t.priority < c.priority) OR (t.priority = c.priority AND t.id > c.id))",
+		},
+		{
+			"three keys",
+			[]sortKey{{"%[1]s.a", false}, {"%[1]s.b", true}, {"%[1]s.id", false}},
+			"((t.a > c.a) OR (t.a = c.a AND t.b < c.b) OR (t.a = c.a AND t.b = c.b AND t.id > c.id))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := after(tt.keys); got != tt.want {
+				t.Errorf("after() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskFilterValidate(t *testing.T) {
+	id := 1
+	tests := []struct {
+		name   string
+		filter TaskFilter
+		valid  bool
+	}{
+		{"zero value", TaskFilter{}, true},
+		{"known status and type", TaskFilter{Statuses: []models.TaskStatus{models.StatusDone}, TaskTypes: []models.TaskType{models.TypeEvent}}, true},
+		{"unknown status", TaskFilter{Statuses: []models.TaskStatus{"waiting"}}, false},
+		{"unknown type", TaskFilter{TaskTypes: []models.TaskType{"party"}}, false},
+		{"priority range", TaskFilter{MinPriority: 2, MaxPriority: 2}, true},
+		{"priority out of range", TaskFilter{MaxPriority: 4}, false},
+		{"energy out of range", TaskFilter{EnergyLevel: 5}, false},
+		{"min above max", TaskFilter{MinPriority: 3, MaxPriority: 1}, false},
+		{"parent and top level", TaskFilter{ParentID: &id, TopLevel: true}, false},
+		{"unknown sort", TaskFilter{Sort: "colour"}, false},
+		{"negative limit", TaskFilter{Limit: -1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.validate()
+			if tt.valid && err != nil {
+				t.Errorf("validate() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalid) {
+				t.Errorf("validate() = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestCursor(t *testing.T) {
+	for _, id := range []int{1, 42, 1 << 30} {
+		got, err := decodeCursor(encodeCursor(id))
+		if err != nil || got != id {
+			t.Errorf("decodeCursor(encodeCursor(%d)) = %d, %v", id, got, err)
+		}
+	}
+	for _, cursor := range []string{"", "!!", encodeCursor(1)[:3], "dGFzazp4"} {
+		if _, err := decodeCursor(cursor); !errors.Is(err, ErrInvalid) {
+			t.Errorf("decodeCursor(%q) = %v, want ErrInvalid", cursor, err)
+		}
+	}
+}
+
+// TestListTasksPages checks that paging through any order visits every task
+// exactly once, in the order of a single unpaged listing, even when many
+// tasks tie on the sort field
+func TestListTasksPages(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < 11; i++ {
+		_, err := db.CreateTask(&models.CreateTaskRequest{
+			Title:                 fmt.Sprintf("Task %c", 'k'-i),
+			EstimatedDurationMins: 15 * (i % 3),
+			Priority:              1 + i%2,
+		})
+		if err != nil {
+			t.Fatalf("CreateTask: %v", err)
+		}
+	}
+
+	asc := false
+	tests := []struct {
+		name   string
+		filter TaskFilter
+	}{
+		{"priority", TaskFilter{}},
+		{"title", TaskFilter{Sort: SortTitle}},
+		{"duration", TaskFilter{Sort: SortDuration}},
+		{"duration ascending", TaskFilter{Sort: SortDuration, Descending: &asc}},
+		{"deadline", TaskFilter{Sort: SortDeadline}},
+		{"created", TaskFilter{Sort: SortCreated}},
+		{"filtered", TaskFilter{MinPriority: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.filter
+			tasks, _, err := db.ListTasks(filter)
+			if err != nil {
+				t.Fatalf("ListTasks: %v", err)
+			}
+			var all []int
+			for _, task := range tasks {
+				all = append(all, task.ID)
+			}
+
+			var paged []int
+			filter.Limit = 3
+			for pages := 0; ; pages++ {
+				if pages > len(all) {
+					t.Fatal("paging does not end")
+				}
+				tasks, next, err := db.ListTasks(filter)
+				if err != nil {
+					t.Fatalf("ListTasks: %v", err)
+				}
+				for _, task := range tasks {
+					paged = append(paged, task.ID)
+				}
+				if next == "" {
+					break
+				}
+				filter.Cursor = next
+			}
+
+			if len(paged) != len(all) {
+				t.Fatalf("paged through %d tasks, want %d", len(paged), len(all))
+			}
+			if fmt.Sprint(paged) != fmt.Sprint(all) {
+				t.Errorf("paged order %v, want %v", paged, all)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// followUpMarginDays is how long past the wait for a reply threads are
+// still looked at, so turning the rules on does not dig up conversations
+// long settled some other way
+const followUpMarginDays = 30
+
+// followUpTag marks the tasks created by GenerateFollowUps
+const followUpTag = "follow-up"
+
+// followUpSince is the oldest a thread can be and still be followed up or
+// waited on: the days the rules wait for a reply, plus the margin
+func followUpSince(rules models.FollowUpRules, now time.Time) time.Time {
+	return now.AddDate(0, 0, -rules.AfterDays-followUpMarginDays)
+}
+
+// unansweredThreads returns the outbound threads sent or pending since the
+// given time, of the types the rules watch, that nothing came in from the
+// contact after. With unclaimed set, threads that already got a follow-up
+// are left out. Threads are grouped by contact, oldest first.
+func (db *DB) unansweredThreads(rules models.FollowUpRules, since time.Time, unclaimed bool) ([]models.ContactThread, error) {
+	if len(rules.Types) == 0 {
+		return nil, nil
+	}
+
+	query := threadColumns + `
+		WHERE th.direction = ? AND th.status IN (?, ?)
+		AND julianday(th.created_at) >= julianday(?)
+		AND th.thread_type IN (` + placeholders(len(rules.Types)) + `)
+		AND NOT EXISTS (
+			SELECT 1 FROM contact_threads r
+			WHERE r.contact_id = th.contact_id AND r.direction = ? AND r.created_at > th.created_at
+		)`
+	args := []interface{}{models.DirectionOutbound, models.ThreadSent, models.ThreadPending, since}
+	for _, t := range rules.Types {
+		args = append(args, t)
+	}
+	args = append(args, models.DirectionInbound)
+	if unclaimed {
+		query += ` AND NOT EXISTS (SELECT 1 FROM follow_ups f WHERE f.thread_id = th.id)`
+	}
+	query += ` ORDER BY th.contact_id, th.created_at, th.id`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unanswered threads: %w", err)
+	}
+	defer rows.Close()
+
+	var threads []models.ContactThread
+	for rows.Next() {
+		thread, err := scanThread(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan thread: %w", err)
+		}
+		threads = append(threads, *thread)
+	}
+	return threads, rows.Err()
+}
+
+// openFollowUpTask returns the ID of the unfinished follow-up task with a
+// contact, or 0 when there is none
+func (db *DB) openFollowUpTask(contactID int) (int, error) {
+	var taskID int
+	err := db.conn.QueryRow(`
+		SELECT f.task_id FROM follow_ups f
+		JOIN contact_threads th ON th.id = f.thread_id
+		JOIN tasks t ON t.id = f.task_id
+		WHERE th.contact_id = ? AND t.status != ?
+		ORDER BY f.task_id DESC LIMIT 1`, contactID, models.StatusDone).Scan(&taskID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get follow-up task: %w", err)
+	}
+	return taskID, nil
+}
+
+// dueFollowUp is a thread needing a follow-up and why
+type dueFollowUp struct {
+	thread models.ContactThread
+	reason string
+}
+
+// GenerateFollowUps applies the follow-up rules of the settings: outbound
+// threads still pending, or left without a reply for too long, get a
+// "Follow up with" task linked to their contact. A contact with an open
+// follow-up task has new threads added to it rather than a second task.
+// Each thread is followed up once; the tasks created are returned.
+func (db *DB) GenerateFollowUps(now time.Time) ([]models.Task, error) {
+	rules, err := db.GetFollowUpRules()
+	if err != nil {
+		return nil, err
+	}
+	threads, err := db.unansweredThreads(rules, followUpSince(rules, now), true)
+	if err != nil {
+		return nil, err
+	}
+
+	silentSince := now.AddDate(0, 0, -rules.AfterDays)
+	var contactIDs []int
+	due := make(map[int][]dueFollowUp)
+	for _, thread := range threads {
+		var reason string
+		switch {
+		case thread.Status == models.ThreadPending && rules.Pending:
+			reason = models.FollowUpPending
+		case thread.Status == models.ThreadSent && rules.AfterDays > 0 && !thread.CreatedAt.After(silentSince):
+			reason = models.FollowUpNoReply
+		default:
+			continue
+		}
+		if _, ok := due[thread.ContactID]; !ok {
+			contactIDs = append(contactIDs, thread.ContactID)
+		}
+		due[thread.ContactID] = append(due[thread.ContactID], dueFollowUp{thread, reason})
+	}
+
+	created := []models.Task{}
+	err = db.inTransaction(func(tx *DB) error {
+		for _, contactID := range contactIDs {
+			task, err := tx.followUp(contactID, due[contactID], now)
+			if err != nil {
+				return err
+			}
+			if task != nil {
+				created = append(created, *task)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// followUp records the follow-up of threads with a contact, creating the
+// task unless the contact has an open one. It returns the task it created.
+func (db *DB) followUp(contactID int, threads []dueFollowUp, now time.Time) (*models.Task, error) {
+	taskID, err := db.openFollowUpTask(contactID)
+	if err != nil {
+		return nil, err
+	}
+
+	var task *models.Task
+	if taskID == 0 {
+		contact, err := db.GetContact(contactID)
+		if err != nil {
+			return nil, err
+		}
+		lines := make([]string, len(threads))
+		for i, due := range threads {
+			lines[i] = followUpLine(due)
+		}
+		deadline := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 0, 0, now.Location())
+		task, err = db.CreateTask(&models.CreateTaskRequest{
+			Title:                 "Follow up with " + contact.Name,
+			Description:           strings.Join(lines, "\n"),
+			EstimatedDurationMins: 10,
+			Deadline:              &deadline,
+			Priority:              3,
+			Tags:                  []string{followUpTag},
+			EnergyLevel:           1,
+			Difficulty:            1,
+			TaskType:              models.TypeTask,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := db.LinkContact(task.ID, contactID, models.RoleParticipant); err != nil {
+			return nil, err
+		}
+		taskID = task.ID
+	}
+
+	for _, due := range threads {
+		_, err := db.conn.Exec(`INSERT INTO follow_ups (thread_id, task_id, reason, created_at) VALUES (?, ?, ?, ?)`,
+			due.thread.ID, taskID, due.reason, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record follow-up: %w", err)
+		}
+	}
+	return task, nil
+}
+
+// followUpLine describes a thread in the description of its follow-up task
+func followUpLine(due dueFollowUp) string {
+	what := due.thread.Subject
+	if what == "" {
+		what = due.thread.Message
+		if runes := []rune(what); len(runes) > 60 {
+			what = string(runes[:60]) + "…"
+		}
+	}
+	if due.reason == models.FollowUpPending {
+		return fmt.Sprintf("Still to send (%s): %s", due.thread.CreatedAt.Format("Jan 2"), what)
+	}
+	return fmt.Sprintf("No reply since %s: %s", due.thread.CreatedAt.Format("Jan 2"), what)
+}
+
+// GetWaitingOn returns the contacts that have not answered what was sent to
+// them since followUpSince, the longest waiting first
+func (db *DB) GetWaitingOn(now time.Time) ([]models.WaitingOn, error) {
+	rules, err := db.GetFollowUpRules()
+	if err != nil {
+		return nil, err
+	}
+	threads, err := db.unansweredThreads(rules, followUpSince(rules, now), false)
+	if err != nil {
+		return nil, err
+	}
+
+	waiting := []models.WaitingOn{}
+	index := make(map[int]int)
+	for _, thread := range threads {
+		if thread.Status != models.ThreadSent {
+			continue
+		}
+		i, ok := index[thread.ContactID]
+		if !ok {
+			i = len(waiting)
+			index[thread.ContactID] = i
+			waiting = append(waiting, models.WaitingOn{Since: thread.CreatedAt})
+		}
+		waiting[i].Thread = thread
+		waiting[i].Unanswered++
+	}
+
+	for i := range waiting {
+		w := &waiting[i]
+		contact, err := db.GetContact(w.Thread.ContactID)
+		if err != nil {
+			return nil, err
+		}
+		w.Contact = *contact
+		w.DaysWaiting = int(now.Sub(w.Since).Hours() / 24)
+
+		taskID, err := db.openFollowUpTask(contact.ID)
+		if err != nil {
+			return nil, err
+		}
+		if taskID != 0 {
+			if w.FollowUp, err = db.GetTask(taskID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.SliceStable(waiting, func(a, b int) bool {
+		return waiting[a].Since.Before(waiting[b].Since)
+	})
+	return waiting, nil
+}
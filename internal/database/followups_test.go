@@ -0,0 +1,62 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+func TestFollowUpWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		afterDays string
+		age       int // days since the message was sent
+		followUp  bool
+		waiting   bool
+	}{
+		{"answer overdue", "3", 5, true, true},
+		{"still waiting", "3", 1, false, true},
+		{"settled long ago", "3", 40, false, false},
+		{"long wait overdue", "45", 50, true, true},
+		{"long wait still waiting", "45", 40, false, true},
+		{"never on silence", "", 5, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			if err := db.SetSetting(SettingFollowUpDays, tt.afterDays); err != nil {
+				t.Fatalf("SetSetting: %v", err)
+			}
+			name := "Sam"
+			contact, err := db.CreateContact(&models.ContactRequest{Name: &name})
+			if err != nil {
+				t.Fatalf("CreateContact: %v", err)
+			}
+			thread, err := db.CreateThread(contact.ID, &models.ThreadRequest{Message: "Lunch on Friday?"})
+			if err != nil {
+				t.Fatalf("CreateThread: %v", err)
+			}
+			now := time.Now()
+			if _, err := db.conn.Exec(`UPDATE contact_threads SET created_at = ? WHERE id = ?`,
+				now.AddDate(0, 0, -tt.age), thread.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			waiting, err := db.GetWaitingOn(now)
+			if err != nil {
+				t.Fatalf("GetWaitingOn: %v", err)
+			}
+			if got := len(waiting) == 1; got != tt.waiting {
+				t.Errorf("waiting on %d contacts, want waiting = %v", len(waiting), tt.waiting)
+			}
+			created, err := db.GenerateFollowUps(now)
+			if err != nil {
+				t.Fatalf("GenerateFollowUps: %v", err)
+			}
+			if got := len(created) == 1; got != tt.followUp {
+				t.Errorf("created %d tasks, want follow-up = %v", len(created), tt.followUp)
+			}
+		})
+	}
+}
@@ -0,0 +1,661 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// migration is one versioned schema change. Applied migrations must never be
+// edited; their checksum is verified before migrating. Add a new one instead.
+type migration struct {
+	version int
+	name    string
+	up      string
+	// down undoes up; empty if the migration cannot be rolled back
+	down string
+	// legacyColumn ("table.column") marks a migration that older versions
+	// applied without recording it. If the column already exists, the
+	// migration is recorded as applied without running.
+	legacyColumn string
+	// requires names a SQLite compile option the migration depends on, such
+	// as ENABLE_FTS5. Without it the migration stays pending.
+	requires string
+}
+
+// checksum fingerprints the up statements of a migration
+func (m *migration) checksum() string {
+	sum := sha256.Sum256([]byte(m.up))
+	return hex.EncodeToString(sum[:])
+}
+
+// migrations lists every schema change in the order it is applied
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create core tables",
+		up: `
+-- Tasks table with recursive structure
+CREATE TABLE IF NOT EXISTS tasks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    description TEXT,
+    parent_id INTEGER, -- For subtasks
+    estimated_duration_minutes INTEGER DEFAULT 30,
+    deadline DATETIME,
+    priority INTEGER DEFAULT 1, -- 1=low, 2=medium, 3=high
+    status TEXT DEFAULT 'pending', -- pending, in_progress, done, blocked
+    tags TEXT, -- JSON array of tags
+    energy_level INTEGER DEFAULT 2, -- 1=low, 2=medium, 3=high energy needed
+    difficulty INTEGER DEFAULT 2, -- 1=easy, 2=medium, 3=hard
+    money_cost INTEGER DEFAULT 0, -- Time budget cost in "coins"
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME,
+    FOREIGN KEY (parent_id) REFERENCES tasks(id)
+);
+
+-- Task prerequisites (DAG structure)
+CREATE TABLE IF NOT EXISTS task_prerequisites (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    prerequisite_task_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (task_id) REFERENCES tasks(id),
+    FOREIGN KEY (prerequisite_task_id) REFERENCES tasks(id),
+    UNIQUE(task_id, prerequisite_task_id)
+);
+
+-- Daily budgets for time management
+CREATE TABLE IF NOT EXISTS daily_budgets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date DATE NOT NULL UNIQUE,
+    total_budget_coins INTEGER DEFAULT 500, -- Daily budget in "coins"
+    spent_coins INTEGER DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Task assignments to days
+CREATE TABLE IF NOT EXISTS task_schedule (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    scheduled_date DATE NOT NULL,
+    start_time TIME,
+    estimated_end_time TIME,
+    actual_start_time DATETIME,
+    actual_end_time DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (task_id) REFERENCES tasks(id)
+);
+
+-- User settings and preferences
+CREATE TABLE IF NOT EXISTS settings (
+    key TEXT PRIMARY KEY,
+    value TEXT,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Contacts for communication and task management
+CREATE TABLE IF NOT EXISTS contacts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    email TEXT,
+    phone TEXT,
+    type TEXT DEFAULT 'person', -- person, organization, venue
+    notes TEXT,
+    avatar_url TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Contact threads for communication history
+CREATE TABLE IF NOT EXISTS contact_threads (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    contact_id INTEGER NOT NULL,
+    task_id INTEGER, -- Optional link to task
+    subject TEXT,
+    message TEXT NOT NULL,
+    thread_type TEXT DEFAULT 'message', -- message, email, call, meeting
+    direction TEXT DEFAULT 'outbound', -- inbound, outbound
+    status TEXT DEFAULT 'sent', -- sent, received, pending, failed
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (contact_id) REFERENCES contacts(id),
+    FOREIGN KEY (task_id) REFERENCES tasks(id)
+);
+
+-- Attachments for tasks and events
+CREATE TABLE IF NOT EXISTS attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER,
+    contact_id INTEGER,
+    filename TEXT NOT NULL,
+    original_filename TEXT NOT NULL,
+    file_path TEXT NOT NULL,
+    file_size INTEGER,
+    mime_type TEXT,
+    description TEXT,
+    attachment_type TEXT DEFAULT 'document', -- document, image, audio, video, link
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (task_id) REFERENCES tasks(id),
+    FOREIGN KEY (contact_id) REFERENCES contacts(id)
+);
+
+-- Task contacts relationship (many-to-many)
+CREATE TABLE IF NOT EXISTS task_contacts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    contact_id INTEGER NOT NULL,
+    role TEXT DEFAULT 'participant', -- organizer, participant, venue, vendor
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (task_id) REFERENCES tasks(id),
+    FOREIGN KEY (contact_id) REFERENCES contacts(id),
+    UNIQUE(task_id, contact_id)
+);`,
+	},
+	{
+		version: 2,
+		name:    "add radar and event columns to tasks",
+		up: `
+ALTER TABLE tasks ADD COLUMN task_type TEXT DEFAULT 'task';
+ALTER TABLE tasks ADD COLUMN event_location TEXT;
+ALTER TABLE tasks ADD COLUMN event_start DATETIME;
+ALTER TABLE tasks ADD COLUMN event_end DATETIME;
+ALTER TABLE tasks ADD COLUMN radar_position_x REAL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN radar_position_y REAL DEFAULT 0;`,
+		down: `
+ALTER TABLE tasks DROP COLUMN radar_position_y;
+ALTER TABLE tasks DROP COLUMN radar_position_x;
+ALTER TABLE tasks DROP COLUMN event_end;
+ALTER TABLE tasks DROP COLUMN event_start;
+ALTER TABLE tasks DROP COLUMN event_location;
+ALTER TABLE tasks DROP COLUMN task_type;`,
+		legacyColumn: "tasks.task_type",
+	},
+	{
+		version: 3,
+		name:    "create task status history",
+		up: `
+-- Task status history, including automatic blocking/unblocking
+CREATE TABLE IF NOT EXISTS task_status_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    old_status TEXT NOT NULL,
+    new_status TEXT NOT NULL,
+    cause_task_id INTEGER, -- Prerequisite whose change caused this one, if any
+    reason TEXT, -- e.g. "Grocery Shopping was finished"
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (task_id) REFERENCES tasks(id)
+);`,
+		down: `DROP TABLE task_status_history;`,
+	},
+	{
+		version: 4,
+		name:    "add estimate_calibrated to tasks",
+		up: `
+-- Whether the stored estimate already includes the learned correction
+ALTER TABLE tasks ADD COLUMN estimate_calibrated INTEGER DEFAULT 0;`,
+		down:         `ALTER TABLE tasks DROP COLUMN estimate_calibrated;`,
+		legacyColumn: "tasks.estimate_calibrated",
+	},
+	{
+		version: 5,
+		name:    "create budget ledger",
+		up: `
+-- Ledger of coins spent per day
+CREATE TABLE IF NOT EXISTS budget_transactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date DATE NOT NULL,
+    task_id INTEGER, -- Task the coins were spent on, if any
+    amount INTEGER NOT NULL, -- Coins spent, negative for refunds
+    kind TEXT NOT NULL, -- completion, progress, adjustment
+    note TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (task_id) REFERENCES tasks(id)
+);`,
+		down: `DROP TABLE budget_transactions;`,
+	},
+	{
+		version: 6,
+		name:    "add budget_override to daily budgets",
+		up: `
+-- Budget set by hand for a single date, taking precedence over the settings
+ALTER TABLE daily_budgets ADD COLUMN budget_override INTEGER;`,
+		down:         `ALTER TABLE daily_budgets DROP COLUMN budget_override;`,
+		legacyColumn: "daily_budgets.budget_override",
+	},
+	{
+		version: 7,
+		name:    "add ical_uid to tasks",
+		up: `
+-- UID of the calendar entry a task was imported from, to update it on re-import
+ALTER TABLE tasks ADD COLUMN ical_uid TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_ical_uid ON tasks(ical_uid);`,
+		down: `
+DROP INDEX IF EXISTS idx_tasks_ical_uid;
+ALTER TABLE tasks DROP COLUMN ical_uid;`,
+	},
+	{
+		version: 8,
+		name:    "add recurrence to tasks",
+		up: `
+-- Recurring series: the rule lives on the template, occurrences link back to it
+ALTER TABLE tasks ADD COLUMN recurrence TEXT; -- JSON rule, set on templates
+ALTER TABLE tasks ADD COLUMN series_id INTEGER REFERENCES tasks(id);
+ALTER TABLE tasks ADD COLUMN occurrence_date DATE;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_series_occurrence ON tasks(series_id, occurrence_date);`,
+		down: `
+DROP INDEX IF EXISTS idx_tasks_series_occurrence;
+ALTER TABLE tasks DROP COLUMN occurrence_date;
+ALTER TABLE tasks DROP COLUMN series_id;
+ALTER TABLE tasks DROP COLUMN recurrence;`,
+	},
+	{
+		version:  9,
+		name:     "add full-text search index",
+		requires: "ENABLE_FTS5",
+		up: `
+-- Full-text indexes over tasks, contacts and threads, kept in sync by triggers
+CREATE VIRTUAL TABLE IF NOT EXISTS tasks_fts USING fts5(
+    title, description, tags, content='tasks', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS contacts_fts USING fts5(
+    name, notes, content='contacts', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS contact_threads_fts USING fts5(
+    subject, message, content='contact_threads', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS tasks_fts_insert AFTER INSERT ON tasks BEGIN
+    INSERT INTO tasks_fts(rowid, title, description, tags) VALUES (new.id, new.title, new.description, new.tags);
+END;
+CREATE TRIGGER IF NOT EXISTS tasks_fts_delete AFTER DELETE ON tasks BEGIN
+    INSERT INTO tasks_fts(tasks_fts, rowid, title, description, tags) VALUES ('delete', old.id, old.title, old.description, old.tags);
+END;
+CREATE TRIGGER IF NOT EXISTS tasks_fts_update AFTER UPDATE OF title, description, tags ON tasks BEGIN
+    INSERT INTO tasks_fts(tasks_fts, rowid, title, description, tags) VALUES ('delete', old.id, old.title, old.description, old.tags);
+    INSERT INTO tasks_fts(rowid, title, description, tags) VALUES (new.id, new.title, new.description, new.tags);
+END;
+
+CREATE TRIGGER IF NOT EXISTS contacts_fts_insert AFTER INSERT ON contacts BEGIN
+    INSERT INTO contacts_fts(rowid, name, notes) VALUES (new.id, new.name, new.notes);
+END;
+CREATE TRIGGER IF NOT EXISTS contacts_fts_delete AFTER DELETE ON contacts BEGIN
+    INSERT INTO contacts_fts(contacts_fts, rowid, name, notes) VALUES ('delete', old.id, old.name, old.notes);
+END;
+CREATE TRIGGER IF NOT EXISTS contacts_fts_update AFTER UPDATE OF name, notes ON contacts BEGIN
+    INSERT INTO contacts_fts(contacts_fts, rowid, name, notes) VALUES ('delete', old.id, old.name, old.notes);
+    INSERT INTO contacts_fts(rowid, name, notes) VALUES (new.id, new.name, new.notes);
+END;
+
+CREATE TRIGGER IF NOT EXISTS contact_threads_fts_insert AFTER INSERT ON contact_threads BEGIN
+    INSERT INTO contact_threads_fts(rowid, subject, message) VALUES (new.id, new.subject, new.message);
+END;
+CREATE TRIGGER IF NOT EXISTS contact_threads_fts_delete AFTER DELETE ON contact_threads BEGIN
+    INSERT INTO contact_threads_fts(contact_threads_fts, rowid, subject, message) VALUES ('delete', old.id, old.subject, old.message);
+END;
+CREATE TRIGGER IF NOT EXISTS contact_threads_fts_update AFTER UPDATE OF subject, message ON contact_threads BEGIN
+    INSERT INTO contact_threads_fts(contact_threads_fts, rowid, subject, message) VALUES ('delete', old.id, old.subject, old.message);
+    INSERT INTO contact_threads_fts(rowid, subject, message) VALUES (new.id, new.subject, new.message);
+END;
+
+-- Index the rows that already exist
+INSERT INTO tasks_fts(tasks_fts) VALUES ('rebuild');
+INSERT INTO contacts_fts(contacts_fts) VALUES ('rebuild');
+INSERT INTO contact_threads_fts(contact_threads_fts) VALUES ('rebuild');`,
+		down: `
+DROP TRIGGER IF EXISTS contact_threads_fts_update;
+DROP TRIGGER IF EXISTS contact_threads_fts_delete;
+DROP TRIGGER IF EXISTS contact_threads_fts_insert;
+DROP TRIGGER IF EXISTS contacts_fts_update;
+DROP TRIGGER IF EXISTS contacts_fts_delete;
+DROP TRIGGER IF EXISTS contacts_fts_insert;
+DROP TRIGGER IF EXISTS tasks_fts_update;
+DROP TRIGGER IF EXISTS tasks_fts_delete;
+DROP TRIGGER IF EXISTS tasks_fts_insert;
+DROP TABLE IF EXISTS contact_threads_fts;
+DROP TABLE IF EXISTS contacts_fts;
+DROP TABLE IF EXISTS tasks_fts;`,
+	},
+	{
+		version: 10,
+		name:    "add tags tables",
+		up: `
+-- Tags as rows of their own; tasks.tags keeps a JSON copy of each task's tag names
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    color TEXT, -- Hex color such as #4f46e5
+    emoji TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS task_tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT, -- Keeps the order tags were given in
+    task_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (task_id) REFERENCES tasks(id),
+    FOREIGN KEY (tag_id) REFERENCES tags(id),
+    UNIQUE(task_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS idx_task_tags_tag ON task_tags(tag_id);
+
+-- Move the tags of existing tasks over
+INSERT OR IGNORE INTO tags (name)
+SELECT trim(j.value)
+FROM tasks, json_each(CASE WHEN json_valid(tasks.tags) THEN tasks.tags ELSE '[]' END) j
+WHERE trim(j.value) <> ''
+ORDER BY tasks.id, j.key;
+
+INSERT OR IGNORE INTO task_tags (task_id, tag_id)
+SELECT tasks.id, tags.id
+FROM tasks, json_each(CASE WHEN json_valid(tasks.tags) THEN tasks.tags ELSE '[]' END) j
+JOIN tags ON tags.name = trim(j.value)
+ORDER BY tasks.id, j.key;`,
+		down: `
+DROP INDEX IF EXISTS idx_task_tags_tag;
+DROP TABLE IF EXISTS task_tags;
+DROP TABLE IF EXISTS tags;`,
+	},
+	{
+		version: 11,
+		name:    "add follow-ups table",
+		up: `
+-- Outbound threads a follow-up task was created for; a thread gets at most one
+CREATE TABLE IF NOT EXISTS follow_ups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    thread_id INTEGER NOT NULL UNIQUE,
+    task_id INTEGER, -- NULL once the task is deleted, so it is not created again
+    reason TEXT NOT NULL, -- pending, no_reply
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (thread_id) REFERENCES contact_threads(id),
+    FOREIGN KEY (task_id) REFERENCES tasks(id)
+);
+CREATE INDEX IF NOT EXISTS idx_follow_ups_task ON follow_ups(task_id);`,
+		down: `
+DROP INDEX IF EXISTS idx_follow_ups_task;
+DROP TABLE IF EXISTS follow_ups;`,
+	},
+	{
+		version: 12,
+		name:    "add raw_estimate_minutes to tasks",
+		up: `
+-- The estimate as entered, kept while estimated_duration_minutes holds the
+-- calibrated one, so calibration keeps learning from what people estimate
+ALTER TABLE tasks ADD COLUMN raw_estimate_minutes INTEGER;`,
+		down: `ALTER TABLE tasks DROP COLUMN raw_estimate_minutes;`,
+	},
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Modified is set when the migration changed after it was applied
+	Modified bool `json:"modified,omitempty"`
+	// Unknown is set for applied versions this build does not know about
+	Unknown bool `json:"unknown,omitempty"`
+	// Unavailable is set for pending migrations this SQLite build cannot apply
+	Unavailable bool `json:"unavailable,omitempty"`
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// ensureMigrationsTable creates schema_migrations if needed
+func (db *DB) ensureMigrationsTable() error {
+	_, err := db.conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations returns the recorded migrations by version
+func (db *DB) appliedMigrations() (map[int]appliedMigration, error) {
+	rows, err := db.conn.Query(`SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var (
+			version int
+			a       appliedMigration
+		)
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan migration: %w", err)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// verifyMigrations fails if an applied migration was edited afterwards or is
+// unknown to this build, since migrating further could corrupt the schema
+func verifyMigrations(applied map[int]appliedMigration) error {
+	known := make(map[int]bool, len(migrations))
+	for i := range migrations {
+		m := &migrations[i]
+		known[m.version] = true
+		if a, ok := applied[m.version]; ok && a.checksum != m.checksum() {
+			return fmt.Errorf("%w: migration %d (%s) was changed after it was applied", ErrConflict, m.version, m.name)
+		}
+	}
+	for version, a := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: database has migration %d (%s) that this version does not know", ErrConflict, version, a.name)
+		}
+	}
+	return nil
+}
+
+// MigrationStatus lists every known migration and whether it is applied
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for i := range migrations {
+		m := &migrations[i]
+		status := MigrationStatus{Version: m.version, Name: m.name}
+		if a, ok := applied[m.version]; ok {
+			status.Applied = true
+			status.AppliedAt = &a.appliedAt
+			status.Modified = a.checksum != m.checksum()
+			delete(applied, m.version)
+		} else if m.requires != "" {
+			ok, err := db.hasCompileOption(m.requires)
+			if err != nil {
+				return nil, err
+			}
+			status.Unavailable = !ok
+		}
+		statuses = append(statuses, status)
+	}
+	for version, a := range applied {
+		appliedAt := a.appliedAt
+		statuses = append(statuses, MigrationStatus{
+			Version: version, Name: a.name, Applied: true, AppliedAt: &appliedAt, Unknown: true,
+		})
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies all pending migrations in order, each in its own
+// transaction, and returns the ones it applied
+func (db *DB) MigrateUp() ([]MigrationStatus, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyMigrations(applied); err != nil {
+		return nil, err
+	}
+
+	var done []MigrationStatus
+	for i := range migrations {
+		m := &migrations[i]
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+
+		// Optional features wait for a SQLite build that supports them
+		if m.requires != "" {
+			ok, err := db.hasCompileOption(m.requires)
+			if err != nil {
+				return done, err
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		// Databases from before versioning already have some of the changes
+		run := true
+		if m.legacyColumn != "" {
+			parts := strings.SplitN(m.legacyColumn, ".", 2)
+			exists, err := db.hasColumn(parts[0], parts[1])
+			if err != nil {
+				return done, err
+			}
+			run = !exists
+		}
+
+		now := time.Now()
+		if err := db.applyMigration(m, run, now); err != nil {
+			return done, err
+		}
+		done = append(done, MigrationStatus{Version: m.version, Name: m.name, Applied: true, AppliedAt: &now})
+	}
+
+	return done, nil
+}
+
+// applyMigration runs the up statements of a migration, unless run is
+// false, and records it in one transaction
+func (db *DB) applyMigration(m *migration, run bool, now time.Time) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if run {
+		if _, err := tx.Exec(m.up); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+		m.version, m.name, m.checksum(), now)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", m.version, err)
+	}
+	return nil
+}
+
+// MigrateDown rolls back the given number of most recent migrations, each in
+// its own transaction, and returns the ones it rolled back
+func (db *DB) MigrateDown(steps int) ([]MigrationStatus, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("%w: steps must be at least 1", ErrInvalid)
+	}
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyMigrations(applied); err != nil {
+		return nil, err
+	}
+
+	var done []MigrationStatus
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := &migrations[i]
+		if _, ok := applied[m.version]; !ok {
+			continue
+		}
+		if m.down == "" {
+			return done, fmt.Errorf("%w: migration %d (%s) cannot be rolled back", ErrConflict, m.version, m.name)
+		}
+
+		if err := db.revertMigration(m); err != nil {
+			return done, err
+		}
+		done = append(done, MigrationStatus{Version: m.version, Name: m.name})
+	}
+
+	return done, nil
+}
+
+// revertMigration runs the down statements of a migration and forgets it in one transaction
+func (db *DB) revertMigration(m *migration) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.down); err != nil {
+		return fmt.Errorf("failed to roll back migration %d (%s): %w", m.version, m.name, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.version); err != nil {
+		return fmt.Errorf("failed to forget migration %d: %w", m.version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollback of migration %d: %w", m.version, err)
+	}
+	return nil
+}
+
+// hasColumn reports whether a table has a column
+func (db *DB) hasColumn(table, column string) (bool, error) {
+	var n int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up column %s.%s: %w", table, column, err)
+	}
+	return n > 0, nil
+}
+
+// hasCompileOption reports whether SQLite was built with an option such as
+// ENABLE_FTS5
+func (db *DB) hasCompileOption(option string) (bool, error) {
+	var used bool
+	if err := db.conn.QueryRow(`SELECT sqlite_compileoption_used(?)`, option).Scan(&used); err != nil {
+		return false, fmt.Errorf("failed to look up compile option %s: %w", option, err)
+	}
+	return used, nil
+}
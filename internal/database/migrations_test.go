@@ -0,0 +1,120 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"oppgaave/internal/models"
+)
+
+// appliedVersions counts the migrations recorded as applied
+func appliedVersions(t *testing.T, db *DB) int {
+	t.Helper()
+	var n int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	db := newTestDB(t)
+	all := appliedVersions(t, db)
+
+	tests := []struct {
+		name    string
+		steps   int
+		err     error
+		applied int
+		column  bool // tasks.raw_estimate_minutes exists
+	}{
+		{"latest", 1, nil, all - 1, false},
+		{"all that can be", 100, ErrConflict, 1, false},
+		{"no steps", 0, ErrInvalid, 1, false},
+	}
+	for _, tt := range tests {
+		_, err := db.MigrateDown(tt.steps)
+		if tt.err == nil && err != nil || tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: MigrateDown(%d) error = %v, want %v", tt.name, tt.steps, err, tt.err)
+		}
+		if n := appliedVersions(t, db); n != tt.applied {
+			t.Errorf("%s: %d migrations applied, want %d", tt.name, n, tt.applied)
+		}
+		if exists, err := db.hasColumn("tasks", "raw_estimate_minutes"); err != nil || exists != tt.column {
+			t.Errorf("%s: raw_estimate_minutes exists = %v (%v), want %v", tt.name, exists, err, tt.column)
+		}
+	}
+
+	done, err := db.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(done) != all-1 || appliedVersions(t, db) != all {
+		t.Errorf("MigrateUp applied %d migrations, want %d", len(done), all-1)
+	}
+	if _, err := db.CreateTask(&models.CreateTaskRequest{Title: "Still works", Tags: []string{"home"}}); err != nil {
+		t.Errorf("CreateTask after migrating back up: %v", err)
+	}
+}
+
+func TestVerifyMigrations(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper string
+		status func(MigrationStatus) bool
+	}{
+		{
+			"changed after it was applied",
+			`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 2`,
+			func(s MigrationStatus) bool { return s.Version == 2 && s.Modified },
+		},
+		{
+			"unknown to this version",
+			`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (999, 'from the future', 'x', CURRENT_TIMESTAMP)`,
+			func(s MigrationStatus) bool { return s.Version == 999 && s.Unknown },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			if _, err := db.conn.Exec(tt.tamper); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := db.MigrateUp(); !errors.Is(err, ErrConflict) {
+				t.Errorf("MigrateUp() error = %v, want ErrConflict", err)
+			}
+			if _, err := db.MigrateDown(1); !errors.Is(err, ErrConflict) {
+				t.Errorf("MigrateDown() error = %v, want ErrConflict", err)
+			}
+			statuses, err := db.MigrationStatus()
+			if err != nil {
+				t.Fatalf("MigrationStatus: %v", err)
+			}
+			found := false
+			for _, s := range statuses {
+				found = found || tt.status(s)
+			}
+			if !found {
+				t.Errorf("MigrationStatus() = %+v, want the migration flagged", statuses)
+			}
+		})
+	}
+}
+
+// TestLegacyColumnMigration checks that a change older versions made without
+// recording it is recorded rather than run again
+func TestLegacyColumnMigration(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.conn.Exec(`DELETE FROM schema_migrations WHERE version = 4`); err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := db.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(done) != 1 || done[0].Version != 4 {
+		t.Errorf("MigrateUp() = %+v, want migration 4 recorded", done)
+	}
+}
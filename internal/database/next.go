@@ -0,0 +1,83 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// NextTaskOptions narrows down the "what can I do next" suggestions
+type NextTaskOptions struct {
+	Energy      int // Current energy level 1-3, 0 for any
+	FreeMinutes int // Minutes available right now, 0 for unlimited
+	Limit       int // Maximum number of suggestions, 0 for all
+}
+
+// GetNextTasks returns actionable leaf tasks: not done or blocked, with all
+// prerequisites done and no open subtasks, leaving out occurrences of
+// recurring tasks for days still to come. They are ranked by urgency bucket,
+// then priority, then how well their energy level fits the current energy.
+func (db *DB) GetNextTasks(opts NextTaskOptions) ([]models.Task, error) {
+	if opts.Energy < 0 || opts.Energy > 3 {
+		return nil, fmt.Errorf("%w: energy must be between 1 and 3", ErrInvalid)
+	}
+
+	tasks, err := db.GetAllTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	hasOpenSubtasks, err := db.openSubtaskParents()
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now()
+	var next []models.Task
+	for _, task := range tasks {
+		if task.Status != models.StatusPending && task.Status != models.StatusInProgress {
+			continue
+		}
+		if task.OccursAfter(today) {
+			continue
+		}
+		if task.IsBlocked() || hasOpenSubtasks[task.ID] {
+			continue
+		}
+		if opts.Energy > 0 && task.EnergyLevel > opts.Energy {
+			continue
+		}
+		if opts.FreeMinutes > 0 && task.EstimatedDurationMins > opts.FreeMinutes {
+			continue
+		}
+		next = append(next, task)
+	}
+
+	sort.SliceStable(next, func(i, j int) bool {
+		a, b := &next[i], &next[j]
+		if a.UrgencyRank() != b.UrgencyRank() {
+			return a.UrgencyRank() < b.UrgencyRank()
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return energyDistance(a, opts.Energy) < energyDistance(b, opts.Energy)
+	})
+
+	if opts.Limit > 0 && len(next) > opts.Limit {
+		next = next[:opts.Limit]
+	}
+
+	return next, nil
+}
+
+// energyDistance is how far a task's energy level is from the current energy.
+// Without a current energy, lower-energy tasks are easier to start and come first.
+func energyDistance(task *models.Task, energy int) int {
+	if energy == 0 {
+		return task.EnergyLevel
+	}
+	return energy - task.EnergyLevel
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// Default working hours used when a plan is generated without explicit bounds
+const (
+	defaultDayStart = "08:00"
+	defaultDayEnd   = "20:00"
+)
+
+// PlanOptions sets the working hours of a generated plan as "15:04" clock times
+type PlanOptions struct {
+	DayStart string `json:"day_start"`
+	DayEnd   string `json:"day_end"`
+}
+
+// GeneratePlan builds a plan for the given date and stores it in task_schedule,
+// replacing any earlier plan for that day. Schedule rows that already have
+// tracked time are kept. Recurring tasks due that day are created first.
+func (db *DB) GeneratePlan(date time.Time, opts PlanOptions) (*models.DayPlan, error) {
+	dayStart, dayEnd, err := planBounds(date, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Never plan into the past
+	if now := time.Now(); dayStart.Before(now) {
+		dayStart = now.Truncate(5 * time.Minute).Add(5 * time.Minute)
+	}
+	if !dayStart.Before(dayEnd) {
+		return nil, fmt.Errorf("%w: no time left to plan on %s", ErrInvalid, date.Format("2006-01-02"))
+	}
+
+	// Routines that fall on the day get their occurrence before planning
+	if _, err := db.GenerateOccurrences(date); err != nil {
+		return nil, err
+	}
+
+	tasks, err := db.GetAllTasks()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.AttachCalibration(tasks); err != nil {
+		return nil, err
+	}
+	hasOpenSubtasks, err := db.openSubtaskParents()
+	if err != nil {
+		return nil, err
+	}
+	budget, err := db.GetDailyBudget(date)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := models.BuildDayPlan(dayStart, dayEnd, budget.TotalBudgetCoins, tasks, hasOpenSubtasks)
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	dateStr := date.Format("2006-01-02")
+	_, err = tx.Exec(`DELETE FROM task_schedule WHERE scheduled_date = ? AND actual_start_time IS NULL`, dateStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clear previous plan: %w", err)
+	}
+
+	now := time.Now()
+	for i := range plan.Slots {
+		slot := &plan.Slots[i]
+		result, err := tx.Exec(`INSERT INTO task_schedule (task_id, scheduled_date, start_time, estimated_end_time, created_at)
+			VALUES (?, ?, ?, ?, ?)`, slot.TaskID, dateStr, slot.StartTime, slot.EstimatedEndTime, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store plan: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get schedule ID: %w", err)
+		}
+		slot.ID = int(id)
+		slot.CreatedAt = now
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit plan: %w", err)
+	}
+
+	return plan, nil
+}
+
+// GetPlan returns the stored plan for the given date
+func (db *DB) GetPlan(date time.Time) (*models.DayPlan, error) {
+	dayStart, dayEnd, err := planBounds(date, PlanOptions{})
+	if err != nil {
+		return nil, err
+	}
+	budget, err := db.GetDailyBudget(date)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &models.DayPlan{
+		Date:        time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()),
+		DayStart:    dayStart,
+		DayEnd:      dayEnd,
+		BudgetCoins: budget.TotalBudgetCoins,
+		Slots:       []models.TaskSchedule{},
+	}
+
+	query := `
+		SELECT id, task_id, scheduled_date, start_time, estimated_end_time,
+			actual_start_time, actual_end_time, created_at
+		FROM task_schedule
+		WHERE scheduled_date = ? AND start_time IS NOT NULL
+		ORDER BY start_time`
+
+	rows, err := db.conn.Query(query, date.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan: %w", err)
+	}
+	for rows.Next() {
+		slot, err := scanSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan plan slot: %w", err)
+		}
+		plan.Slots = append(plan.Slots, slot)
+	}
+	rows.Close()
+
+	for i := range plan.Slots {
+		task, err := db.GetTask(plan.Slots[i].TaskID)
+		if err != nil {
+			return nil, err
+		}
+		plan.Slots[i].Task = task
+		plan.PlannedCoins += task.MoneyCost
+	}
+
+	return plan, nil
+}
+
+// planBounds resolves the working hours of a plan on the given date
+func planBounds(date time.Time, opts PlanOptions) (time.Time, time.Time, error) {
+	if opts.DayStart == "" {
+		opts.DayStart = defaultDayStart
+	}
+	if opts.DayEnd == "" {
+		opts.DayEnd = defaultDayEnd
+	}
+
+	at := func(clock string) (time.Time, error) {
+		t, err := time.Parse("15:04", clock)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: invalid time %q, expected HH:MM", ErrInvalid, clock)
+		}
+		return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, date.Location()), nil
+	}
+
+	start, err := at(opts.DayStart)
+	if err != nil {
+		return start, start, err
+	}
+	end, err := at(opts.DayEnd)
+	if err != nil {
+		return start, end, err
+	}
+	if !start.Before(end) {
+		return start, end, fmt.Errorf("%w: day must start before it ends", ErrInvalid)
+	}
+	return start, end, nil
+}
+
+// openSubtaskParents returns the IDs of tasks that still have unfinished subtasks
+func (db *DB) openSubtaskParents() (map[int]bool, error) {
+	rows, err := db.conn.Query(`SELECT DISTINCT parent_id FROM tasks WHERE parent_id IS NOT NULL AND status != ?`,
+		models.StatusDone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query open subtasks: %w", err)
+	}
+	defer rows.Close()
+
+	parents := make(map[int]bool)
+	for rows.Next() {
+		var parentID int
+		if err := rows.Scan(&parentID); err != nil {
+			return nil, fmt.Errorf("failed to scan parent: %w", err)
+		}
+		parents[parentID] = true
+	}
+	return parents, nil
+}
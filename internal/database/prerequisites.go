@@ -0,0 +1,182 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// CycleError is returned when a prerequisite edge would make the task graph cyclic
+type CycleError struct {
+	// Path lists the task titles of the cycle, starting and ending at the same task
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "prerequisite would create a cycle: " + strings.Join(e.Path, " → ")
+}
+
+// Unwrap lets callers match cycle errors with errors.Is(err, ErrConflict)
+func (e *CycleError) Unwrap() error {
+	return ErrConflict
+}
+
+// AddPrerequisite records that taskID cannot start before prereqID is done.
+// Self-loops, unknown tasks and edges that would close a cycle are rejected.
+// The graph is checked in the transaction adding the edge, so two edges
+// added at the same time cannot each pass the check and close a cycle together.
+func (db *DB) AddPrerequisite(taskID, prereqID int) error {
+	if taskID == prereqID {
+		return fmt.Errorf("%w: task %d cannot be its own prerequisite", ErrInvalid, taskID)
+	}
+
+	return db.inTransaction(func(tx *DB) error {
+		return tx.addPrerequisite(taskID, prereqID)
+	})
+}
+
+// addPrerequisite checks and adds a prerequisite edge
+func (db *DB) addPrerequisite(taskID, prereqID int) error {
+	titles, err := db.taskTitles(taskID, prereqID)
+	if err != nil {
+		return err
+	}
+
+	// The new edge closes a cycle if taskID is already reachable from prereqID
+	path, err := db.prerequisitePath(prereqID, taskID)
+	if err != nil {
+		return err
+	}
+	if path != nil {
+		titles, err = db.taskTitles(path...)
+		if err != nil {
+			return err
+		}
+		cycle := []string{titles[taskID]}
+		for _, id := range path {
+			cycle = append(cycle, titles[id])
+		}
+		return &CycleError{Path: cycle}
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT OR IGNORE INTO task_prerequisites (task_id, prerequisite_task_id) VALUES (?, ?)`
+	if _, err := tx.Exec(query, taskID, prereqID); err != nil {
+		return fmt.Errorf("failed to add prerequisite: %w", err)
+	}
+
+	if err := refreshBlocked(tx, taskID, &prereqID, "prerequisite "+titles[prereqID]+" was added"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit prerequisite: %w", err)
+	}
+
+	return nil
+}
+
+// RemovePrerequisite deletes the prerequisite edge between two tasks,
+// unblocking the dependent task if that was its last open prerequisite
+func (db *DB) RemovePrerequisite(taskID, prereqID int) error {
+	titles, err := db.taskTitles(prereqID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM task_prerequisites WHERE task_id = ? AND prerequisite_task_id = ?`,
+		taskID, prereqID)
+	if err != nil {
+		return fmt.Errorf("failed to remove prerequisite: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to remove prerequisite: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("prerequisite %d of task %d: %w", prereqID, taskID, ErrNotFound)
+	}
+
+	if err := refreshBlocked(tx, taskID, &prereqID, "prerequisite "+titles[prereqID]+" was removed"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit prerequisite removal: %w", err)
+	}
+
+	return nil
+}
+
+// taskTitles returns the titles of the given tasks, failing if any is missing
+func (db *DB) taskTitles(ids ...int) (map[int]string, error) {
+	titles := make(map[int]string, len(ids))
+	for _, id := range ids {
+		var title string
+		err := db.conn.QueryRow(`SELECT title FROM tasks WHERE id = ?`, id).Scan(&title)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("task %d: %w", id, ErrNotFound)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get task title: %w", err)
+		}
+		titles[id] = title
+	}
+	return titles, nil
+}
+
+// prerequisitePath returns the chain of task IDs from one task to another
+// following prerequisite edges, or nil if to is not reachable from from
+func (db *DB) prerequisitePath(from, to int) ([]int, error) {
+	rows, err := db.conn.Query(`SELECT task_id, prerequisite_task_id FROM task_prerequisites`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prerequisites: %w", err)
+	}
+	defer rows.Close()
+
+	edges := make(map[int][]int)
+	for rows.Next() {
+		var taskID, prereqID int
+		if err := rows.Scan(&taskID, &prereqID); err != nil {
+			return nil, fmt.Errorf("failed to scan prerequisite: %w", err)
+		}
+		edges[taskID] = append(edges[taskID], prereqID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query prerequisites: %w", err)
+	}
+
+	// Breadth-first search, remembering how each task was reached
+	cameFrom := map[int]int{from: from}
+	queue := []int{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == to {
+			var path []int
+			for id := to; id != from; id = cameFrom[id] {
+				path = append([]int{id}, path...)
+			}
+			return append([]int{from}, path...), nil
+		}
+		for _, next := range edges[current] {
+			if _, seen := cameFrom[next]; !seen {
+				cameFrom[next] = current
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"oppgaave/internal/models"
+)
+
+func TestAddPrerequisite(t *testing.T) {
+	db := newTestDB(t)
+	ids := make(map[string]int)
+	for _, title := range []string{"A", "B", "C", "D"} {
+		task, err := db.CreateTask(&models.CreateTaskRequest{Title: title})
+		if err != nil {
+			t.Fatalf("CreateTask: %v", err)
+		}
+		ids[title] = task.ID
+	}
+	ids["missing"] = 999
+
+	// Edges are added in order; each reads "task needs prerequisite"
+	tests := []struct {
+		task, prereq string
+		err          error
+		cycle        string
+	}{
+		{"A", "B", nil, ""},
+		{"B", "C", nil, ""},
+		{"A", "B", nil, ""},
+		{"C", "A", ErrConflict, "C → A → B → C"},
+		{"D", "A", nil, ""},
+		{"C", "D", ErrConflict, "C → D → A → B → C"},
+		{"B", "B", ErrInvalid, ""},
+		{"A", "missing", ErrNotFound, ""},
+		{"A", "C", nil, ""},
+	}
+	for _, tt := range tests {
+		err := db.AddPrerequisite(ids[tt.task], ids[tt.prereq])
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("%s needs %s: %v", tt.task, tt.prereq, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s needs %s: error = %v, want %v", tt.task, tt.prereq, err, tt.err)
+			continue
+		}
+		var cycle *CycleError
+		if errors.As(err, &cycle) != (tt.cycle != "") {
+			t.Errorf("%s needs %s: error = %v, want cycle %q", tt.task, tt.prereq, err, tt.cycle)
+		} else if cycle != nil && strings.Join(cycle.Path, " → ") != tt.cycle {
+			t.Errorf("%s needs %s: cycle = %s, want %s", tt.task, tt.prereq, strings.Join(cycle.Path, " → "), tt.cycle)
+		}
+	}
+
+	var edges int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM task_prerequisites`).Scan(&edges); err != nil {
+		t.Fatal(err)
+	}
+	if edges != 4 {
+		t.Errorf("%d prerequisite edges, want 4", edges)
+	}
+}
@@ -0,0 +1,420 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// seriesStart is the day a new recurring task is the occurrence for: the day
+// of its event or deadline, or else today
+func seriesStart(task *models.Task) time.Time {
+	start := time.Now()
+	switch {
+	case task.EventStart != nil:
+		start = task.EventStart.Local()
+	case task.Deadline != nil:
+		start = task.Deadline.Local()
+	}
+	return time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// dateValue stores an optional date as YYYY-MM-DD
+func dateValue(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Format("2006-01-02")
+}
+
+// setSeries fills in the recurrence columns of a scanned task
+func setSeries(task *models.Task, recurrence sql.NullString, seriesID sql.NullInt64, occurrenceDate sql.NullTime) error {
+	if recurrence.Valid && recurrence.String != "" {
+		var rule models.Recurrence
+		if err := json.Unmarshal([]byte(recurrence.String), &rule); err != nil {
+			return fmt.Errorf("failed to parse recurrence of task %d: %w", task.ID, err)
+		}
+		task.Recurrence = &rule
+	}
+	if seriesID.Valid {
+		id := int(seriesID.Int64)
+		task.SeriesID = &id
+	}
+	if occurrenceDate.Valid {
+		task.OccurrenceDate = &occurrenceDate.Time
+	}
+	return nil
+}
+
+// GetSeries returns the recurring series a task belongs to, with the streak
+// of its occurrences
+func (db *DB) GetSeries(id int) (*models.TaskSeries, error) {
+	task, err := db.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	templateID := task.SeriesTemplateID()
+	if templateID == 0 {
+		return nil, fmt.Errorf("%w: task %d does not recur", ErrInvalid, id)
+	}
+
+	template := task
+	if templateID != id {
+		if template, err = db.GetTask(templateID); err != nil {
+			return nil, err
+		}
+	}
+
+	ids, err := db.seriesOccurrenceIDs(templateID)
+	if err != nil {
+		return nil, err
+	}
+	series := &models.TaskSeries{Template: *template, Occurrences: []models.Task{}}
+	for _, occurrenceID := range ids {
+		occurrence, err := db.GetTask(occurrenceID)
+		if err != nil {
+			return nil, err
+		}
+		series.Occurrences = append(series.Occurrences, *occurrence)
+	}
+
+	// The template is the first occurrence of its series
+	all := append([]models.Task{*template}, series.Occurrences...)
+	series.Streak = models.ComputeStreak(all, time.Now())
+	return series, nil
+}
+
+// seriesOccurrenceIDs returns the occurrences created from a template, oldest first
+func (db *DB) seriesOccurrenceIDs(templateID int) ([]int, error) {
+	rows, err := db.conn.Query(`SELECT id FROM tasks WHERE series_id = ? ORDER BY occurrence_date, id`, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query occurrences: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan occurrence: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetRecurrence sets the rule of the series a task belongs to, making the
+// task the template of a new series if it does not recur yet. A nil rule
+// ends the series; the occurrences created so far are kept.
+func (db *DB) SetRecurrence(id int, rule *models.Recurrence) (*models.Task, error) {
+	task, err := db.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	}
+
+	templateID := task.SeriesTemplateID()
+	switch {
+	case templateID == 0 && rule == nil:
+		return task, nil
+	case templateID == 0:
+		date := task.OccurrenceDate
+		if date == nil {
+			start := seriesStart(task)
+			date = &start
+		}
+		_, err = db.conn.Exec(`UPDATE tasks SET recurrence = ?, occurrence_date = ?, updated_at = ? WHERE id = ?`,
+			rule, dateValue(date), time.Now(), id)
+	default:
+		_, err = db.conn.Exec(`UPDATE tasks SET recurrence = ?, updated_at = ? WHERE id = ?`,
+			rule, time.Now(), templateID)
+		id = templateID
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update recurrence: %w", err)
+	}
+
+	return db.GetTask(id)
+}
+
+// UpdateSeries applies a partial update to a task and the rest of its
+// series: the template, so future occurrences follow, and every occurrence
+// not done yet. Dates and status differ per occurrence and only change on
+// the task itself.
+func (db *DB) UpdateSeries(id int, req *models.UpdateTaskRequest) (*models.Task, error) {
+	task, err := db.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	templateID := task.SeriesTemplateID()
+	if templateID == 0 {
+		return nil, fmt.Errorf("%w: task %d does not recur", ErrInvalid, id)
+	}
+
+	shared := *req
+	shared.Deadline, shared.EventStart, shared.EventEnd, shared.Status = nil, nil, nil, nil
+	shared.Clear = nil
+
+	// Every task of the series changes, or none does
+	err = db.inTransaction(func(tx *DB) error {
+		ids, err := tx.seriesOccurrenceIDs(templateID)
+		if err != nil {
+			return err
+		}
+		for _, other := range append([]int{templateID}, ids...) {
+			if other == id {
+				continue
+			}
+			if other != templateID {
+				var status models.TaskStatus
+				if err := tx.conn.QueryRow(`SELECT status FROM tasks WHERE id = ?`, other).Scan(&status); err != nil {
+					return fmt.Errorf("failed to get task status: %w", err)
+				}
+				if status == models.StatusDone {
+					continue
+				}
+			}
+			update := shared
+			if _, err := tx.UpdateTask(other, &update); err != nil {
+				return err
+			}
+		}
+		task, err = tx.UpdateTask(id, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// GenerateOccurrences creates the occurrences of recurring tasks that fall
+// on date and do not exist yet, as when the day is planned. Series that
+// recur after completion get their next occurrence once it is due and the
+// previous one is done.
+func (db *DB) GenerateOccurrences(date time.Time) ([]models.Task, error) {
+	templates, err := db.seriesTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	var created []models.Task
+	for _, template := range templates {
+		var next *time.Time
+		if template.Recurrence.IsCalendar() {
+			if template.Recurrence.OccursOn(*template.OccurrenceDate, date) {
+				next = &date
+			}
+		} else {
+			if next, err = db.nextAfterCompletion(template); err != nil {
+				return nil, err
+			}
+			if next != nil && next.After(date) {
+				next = nil
+			}
+		}
+		if next == nil {
+			continue
+		}
+
+		task, err := db.createOccurrence(template, *next)
+		if err != nil {
+			return nil, err
+		}
+		if task != nil {
+			created = append(created, *task)
+		}
+	}
+	return created, nil
+}
+
+// generateNextOccurrence creates the next occurrence of the series a task
+// belongs to once it is done, unless one is already waiting
+func (db *DB) generateNextOccurrence(taskID int) error {
+	var (
+		seriesID   sql.NullInt64
+		recurrence sql.NullString
+	)
+	err := db.conn.QueryRow(`SELECT series_id, recurrence FROM tasks WHERE id = ?`, taskID).Scan(&seriesID, &recurrence)
+	if err != nil {
+		return fmt.Errorf("failed to look up series: %w", err)
+	}
+	templateID := taskID
+	switch {
+	case seriesID.Valid:
+		templateID = int(seriesID.Int64)
+	case !recurrence.Valid || recurrence.String == "":
+		return nil
+	}
+
+	template, err := db.GetTask(templateID)
+	if err != nil {
+		return err
+	}
+	if template.Recurrence == nil || template.OccurrenceDate == nil {
+		return nil
+	}
+
+	var next *time.Time
+	if template.Recurrence.IsCalendar() {
+		// Calendar rules continue after the latest occurrence, but never in the past
+		today := time.Now()
+		var open int
+		err := db.conn.QueryRow(`SELECT COUNT(*) FROM tasks WHERE (id = ? OR series_id = ?) AND status != ? AND occurrence_date >= ?`,
+			templateID, templateID, models.StatusDone, today.Format("2006-01-02")).Scan(&open)
+		if err != nil {
+			return fmt.Errorf("failed to count open occurrences: %w", err)
+		}
+		if open > 0 {
+			return nil
+		}
+
+		var latest time.Time
+		err = db.conn.QueryRow(`SELECT occurrence_date FROM tasks WHERE id = ? OR series_id = ?
+			ORDER BY occurrence_date DESC LIMIT 1`, templateID, templateID).Scan(&latest)
+		if err != nil {
+			return fmt.Errorf("failed to find latest occurrence: %w", err)
+		}
+		from := today
+		if after := latest.AddDate(0, 0, 1); after.After(from) {
+			from = after
+		}
+		next = template.Recurrence.NextOn(*template.OccurrenceDate, from)
+	} else if next, err = db.nextAfterCompletion(template); err != nil {
+		return err
+	}
+
+	if next == nil {
+		return nil
+	}
+	_, err = db.createOccurrence(template, *next)
+	return err
+}
+
+// nextAfterCompletion returns when the next occurrence of a series that
+// recurs after completion is due, or nil while an occurrence is still open
+func (db *DB) nextAfterCompletion(template *models.Task) (*time.Time, error) {
+	var open int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM tasks WHERE (id = ? OR series_id = ?) AND status != ?`,
+		template.ID, template.ID, models.StatusDone).Scan(&open)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count open occurrences: %w", err)
+	}
+	if open > 0 {
+		return nil, nil
+	}
+
+	var completedAt time.Time
+	err = db.conn.QueryRow(`SELECT completed_at FROM tasks WHERE (id = ? OR series_id = ?) AND completed_at IS NOT NULL
+		ORDER BY completed_at DESC LIMIT 1`, template.ID, template.ID).Scan(&completedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to find last completion: %w", err)
+	}
+	return template.Recurrence.NextAfterCompletion(completedAt), nil
+}
+
+// seriesTemplates returns the tasks that hold a recurrence rule
+func (db *DB) seriesTemplates() ([]*models.Task, error) {
+	rows, err := db.conn.Query(`SELECT id FROM tasks WHERE recurrence IS NOT NULL AND recurrence != ''`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recurring tasks: %w", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan recurring task: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	var templates []*models.Task
+	for _, id := range ids {
+		task, err := db.GetTask(id)
+		if err != nil {
+			return nil, err
+		}
+		if task.Recurrence != nil && task.OccurrenceDate != nil {
+			templates = append(templates, task)
+		}
+	}
+	return templates, nil
+}
+
+// createOccurrence copies a template into a new task for date, moving its
+// event and deadline along by the same number of days and linking the same
+// contacts. Occurrences without either are due by the end of their day.
+// It returns nil if the series already has an occurrence on that date.
+func (db *DB) createOccurrence(template *models.Task, date time.Time) (*models.Task, error) {
+	dateStr := date.Format("2006-01-02")
+	var exists int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM tasks WHERE (id = ? OR series_id = ?) AND occurrence_date = ?`,
+		template.ID, template.ID, dateStr).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check occurrence: %w", err)
+	}
+	if exists > 0 {
+		return nil, nil
+	}
+
+	from := template.OccurrenceDate
+	days := int(time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).
+		Sub(time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	shift := func(t *time.Time) *time.Time {
+		if t == nil {
+			return nil
+		}
+		moved := t.Local().AddDate(0, 0, days)
+		return &moved
+	}
+
+	req := &models.CreateTaskRequest{
+		Title:                 template.Title,
+		Description:           template.Description,
+		ParentID:              template.ParentID,
+		EstimatedDurationMins: template.EstimatedDurationMins,
+		Deadline:              shift(template.Deadline),
+		Priority:              template.Priority,
+		Tags:                  template.Tags,
+		EnergyLevel:           template.EnergyLevel,
+		Difficulty:            template.Difficulty,
+		TaskType:              template.TaskType,
+		EventLocation:         template.EventLocation,
+		EventStart:            shift(template.EventStart),
+		EventEnd:              shift(template.EventEnd),
+	}
+	if req.Deadline == nil && req.EventStart == nil {
+		due := time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 0, 0, time.Local)
+		req.Deadline = &due
+	}
+
+	var occurrence *models.Task
+	err = db.inTransaction(func(tx *DB) error {
+		task, err := tx.createTask(req, taskOrigin{seriesID: &template.ID, occurrenceDate: &date})
+		if err != nil {
+			return err
+		}
+		_, err = tx.conn.Exec(`INSERT INTO task_contacts (task_id, contact_id, role)
+			SELECT ?, contact_id, role FROM task_contacts WHERE task_id = ?`, task.ID, template.ID)
+		if err != nil {
+			return fmt.Errorf("failed to link occurrence contacts: %w", err)
+		}
+		occurrence, err = tx.GetTask(task.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return occurrence, nil
+}
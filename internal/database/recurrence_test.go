@@ -0,0 +1,86 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// newTestDB opens a fresh database with every migration applied
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCompletedOccurrenceLeavesTheNextForItsDay(t *testing.T) {
+	db := newTestDB(t)
+	task, err := db.CreateTask(&models.CreateTaskRequest{
+		Title:                 "Water the plants",
+		EstimatedDurationMins: 10,
+		Priority:              2,
+		EnergyLevel:           1,
+		Difficulty:            1,
+		TaskType:              models.TypeTask,
+		Recurrence:            &models.Recurrence{Frequency: models.RecurDaily, Interval: 1},
+	})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if err := db.UpdateTaskStatus(task.ID, models.StatusDone); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+
+	series, err := db.GetSeries(task.ID)
+	if err != nil {
+		t.Fatalf("GetSeries: %v", err)
+	}
+	if len(series.Occurrences) != 1 {
+		t.Fatalf("got %d occurrences after finishing the first, want 1", len(series.Occurrences))
+	}
+	nextID := series.Occurrences[0].ID
+
+	tasks, err := db.GetAllTasks()
+	if err != nil {
+		t.Fatalf("GetAllTasks: %v", err)
+	}
+	today := time.Now()
+	tomorrow := today.AddDate(0, 0, 1)
+	tests := []struct {
+		name string
+		day  time.Time
+		want bool
+	}{
+		{"today", today, false},
+		{"tomorrow", tomorrow, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day := time.Date(tt.day.Year(), tt.day.Month(), tt.day.Day(), 0, 0, 0, 0, time.Local)
+			plan := models.BuildDayPlan(day, day.Add(24*time.Hour-time.Minute), 1000, tasks, nil)
+			planned := false
+			for _, slot := range plan.Slots {
+				planned = planned || slot.TaskID == nextID
+			}
+			if planned != tt.want {
+				t.Errorf("next occurrence planned = %v, want %v", planned, tt.want)
+			}
+		})
+	}
+
+	next, err := db.GetNextTasks(NextTaskOptions{})
+	if err != nil {
+		t.Fatalf("GetNextTasks: %v", err)
+	}
+	for _, task := range next {
+		if task.ID == nextID {
+			t.Errorf("GetNextTasks suggests the occurrence for tomorrow")
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"oppgaave/internal/models"
+)
+
+// DefaultSearchLimit is the number of hits returned per type
+const DefaultSearchLimit = 20
+
+// SearchAvailable reports whether SQLite was built with FTS5, which search
+// needs
+func (db *DB) SearchAvailable() (bool, error) {
+	return db.hasCompileOption("ENABLE_FTS5")
+}
+
+// Search finds tasks, contacts and contact threads matching the words of
+// query, using the full-text index. The last word also matches as a prefix,
+// so results show up while typing.
+func (db *DB) Search(query string, limit int) (*models.SearchResults, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, fmt.Errorf("%w: search query is empty", ErrInvalid)
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	var n int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'tasks_fts'`).Scan(&n); err != nil {
+		return nil, fmt.Errorf("failed to look up search index: %w", err)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%w: full-text search needs SQLite with FTS5, build with -tags sqlite_fts5", ErrUnavailable)
+	}
+
+	results := &models.SearchResults{Query: query}
+	var err error
+
+	// Weights rank a match in the title above one in the text
+	results.Tasks, err = db.searchHits("task", `
+		SELECT t.id, t.title, snippet(tasks_fts, -1, char(2), char(3), '…', 12),
+		       bm25(tasks_fts, 10.0, 1.0, 5.0) AS score, NULL
+		FROM tasks_fts JOIN tasks t ON t.id = tasks_fts.rowid
+		WHERE tasks_fts MATCH ?
+		ORDER BY score LIMIT ?`, match, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results.Contacts, err = db.searchHits("contact", `
+		SELECT c.id, c.name, snippet(contacts_fts, -1, char(2), char(3), '…', 12),
+		       bm25(contacts_fts, 10.0, 1.0) AS score, NULL
+		FROM contacts_fts JOIN contacts c ON c.id = contacts_fts.rowid
+		WHERE contacts_fts MATCH ?
+		ORDER BY score LIMIT ?`, match, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results.Threads, err = db.searchHits("thread", `
+		SELECT th.id, COALESCE(NULLIF(th.subject, ''), c.name),
+		       snippet(contact_threads_fts, -1, char(2), char(3), '…', 12),
+		       bm25(contact_threads_fts, 5.0, 1.0) AS score, th.contact_id
+		FROM contact_threads_fts
+		JOIN contact_threads th ON th.id = contact_threads_fts.rowid
+		JOIN contacts c ON c.id = th.contact_id
+		WHERE contact_threads_fts MATCH ?
+		ORDER BY score LIMIT ?`, match, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// searchHits runs one search query returning id, title, snippet, score and
+// contact id
+func (db *DB) searchHits(kind, query string, args ...interface{}) ([]models.SearchHit, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %ss: %w", kind, err)
+	}
+	defer rows.Close()
+
+	hits := []models.SearchHit{}
+	for rows.Next() {
+		hit := models.SearchHit{Type: kind}
+		var snippet string
+		if err := rows.Scan(&hit.ID, &hit.Title, &snippet, &hit.Score, &hit.ContactID); err != nil {
+			return nil, fmt.Errorf("failed to scan %s hit: %w", kind, err)
+		}
+		hit.Snippet = highlight(snippet)
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// highlight escapes a snippet for HTML and turns the markers snippet() puts
+// around matches, control characters nobody types, into <mark> tags
+func highlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	return strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>").Replace(escaped)
+}
+
+// ftsQuery turns what a user typed into an FTS5 query that matches all its
+// words. Each word is quoted so operators and punctuation are taken
+// literally, and the last one matches as a prefix.
+func ftsQuery(query string) string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		word = strings.ReplaceAll(word, `"`, "")
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		terms = append(terms, `"`+word+`"`)
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+	terms[len(terms)-1] += "*"
+	return strings.Join(terms, " ")
+}
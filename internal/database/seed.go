@@ -0,0 +1,84 @@
+package database
+
+import "fmt"
+
+// demoData are sample tasks and contacts for trying the app out. Tasks
+// waiting on a prerequisite start out blocked.
+const demoData = `
+-- Sample tasks for demonstration
+INSERT INTO tasks (id, title, description, estimated_duration_minutes, priority, status, energy_level, difficulty, money_cost, task_type, event_start, event_end) VALUES
+    (1, 'Morning Coffee & Journal', 'Start the day with coffee and journaling to set intentions', 15, 1, 'pending', 1, 1, 15, 'task', NULL, NULL),
+    (2, 'Write Project Draft', 'Complete first draft of the project proposal', 120, 3, 'blocked', 3, 3, 180, 'task', NULL, NULL),
+    (3, 'Yoga Class', 'Attend morning yoga session for physical and mental wellness', 45, 2, 'pending', 2, 1, 45, 'appointment', '2025-08-12 09:00:00', '2025-08-12 09:45:00'),
+    (4, 'Call Landlord', 'Important call about lease renewal - deadline approaching', 30, 3, 'pending', 2, 2, 60, 'task', NULL, NULL),
+    (5, 'Grocery Shopping', 'Buy ingredients for meal prep', 60, 2, 'pending', 2, 2, 60, 'task', NULL, NULL),
+    (6, 'Meal Prep', 'Prepare meals for the week', 90, 2, 'blocked', 3, 2, 90, 'task', NULL, NULL),
+    (7, 'Team Meeting', 'Weekly standup with development team', 60, 2, 'pending', 2, 1, 60, 'meeting', '2025-08-12 14:00:00', '2025-08-12 15:00:00'),
+    (8, 'Concert Planning', 'Plan upcoming jazz concert attendance', 30, 1, 'pending', 1, 1, 30, 'event', '2025-08-15 19:00:00', '2025-08-15 22:00:00');
+
+-- Routines: coffee every morning, the team meeting every Tuesday
+UPDATE tasks SET recurrence = '{"frequency":"daily","interval":1}', occurrence_date = date('now', 'localtime') WHERE id = 1;
+UPDATE tasks SET recurrence = '{"frequency":"weekly","interval":1,"weekdays":[2]}', occurrence_date = '2025-08-12' WHERE id = 7;
+
+-- Sample tags, with tasks.tags holding the same names as JSON
+INSERT INTO tags (id, name, color, emoji) VALUES
+    (1, 'work', '#4f46e5', '💼'),
+    (2, 'health', '#10b981', '🌱'),
+    (3, 'home', '#f59e0b', '🏠');
+INSERT INTO task_tags (task_id, tag_id) VALUES
+    (2, 1), (7, 1), (3, 2), (1, 2), (4, 3), (5, 3), (6, 3), (6, 2);
+UPDATE tasks SET tags = (
+    SELECT json_group_array(name) FROM (
+        SELECT tg.name FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id
+        WHERE tt.task_id = tasks.id ORDER BY tt.id
+    )
+);
+
+-- Sample contacts
+INSERT INTO contacts (id, name, email, phone, type, notes) VALUES
+    (1, 'Dr. Sarah Johnson', 'sarah.johnson@yogastudio.com', '+1-555-0123', 'person', 'Yoga instructor'),
+    (2, 'Development Team', 'team@company.com', NULL, 'organization', 'Work team for standups'),
+    (3, 'Jazz Venue', 'info@jazzclub.com', '+1-555-0456', 'venue', 'Downtown jazz club'),
+    (4, 'Property Manager', 'landlord@property.com', '+1-555-0789', 'person', 'Lease renewal contact');
+
+-- Link contacts to tasks
+INSERT INTO task_contacts (task_id, contact_id, role) VALUES
+    (3, 1, 'organizer'), -- Yoga class with instructor
+    (7, 2, 'participant'), -- Team meeting
+    (8, 3, 'venue'); -- Concert at jazz venue
+
+-- Add some prerequisites
+INSERT INTO task_prerequisites (task_id, prerequisite_task_id) VALUES
+    (6, 5), -- Meal prep requires grocery shopping first
+    (2, 1); -- Writing requires coffee/journal first for focus
+`
+
+// SeedDemoData fills a brand-new database with demo tasks and contacts.
+// Databases that already hold tasks or contacts are left untouched, so it is
+// safe to run more than once; seeded reports whether anything was added.
+func (db *DB) SeedDemoData() (seeded bool, err error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var rows int
+	err = tx.QueryRow(`SELECT (SELECT COUNT(*) FROM tasks) + (SELECT COUNT(*) FROM contacts)`).Scan(&rows)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for existing data: %w", err)
+	}
+	if rows > 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(demoData); err != nil {
+		return false, fmt.Errorf("failed to insert demo data: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit demo data: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,298 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// Setting keys
+const (
+	SettingDailyBudgetCoins     = "daily_budget_coins"
+	SettingEnergyMultiplier     = "energy_multiplier"
+	SettingDifficultyMultiplier = "difficulty_multiplier"
+	SettingFollowUpDays         = "follow_up_after_days"
+	SettingFollowUpPending      = "follow_up_pending"
+	SettingFollowUpTypes        = "follow_up_thread_types"
+)
+
+// WeekdayBudgetKey is the setting holding the budget of a weekday, e.g.
+// "daily_budget_coins_saturday". Left empty, the daily budget applies.
+func WeekdayBudgetKey(day time.Weekday) string {
+	return SettingDailyBudgetCoins + "_" + strings.ToLower(day.String())
+}
+
+// settingDefinition describes a known setting and how to validate it
+type settingDefinition struct {
+	key         string
+	def         string
+	description string
+	validate    func(value string) error
+}
+
+// settingDefinitions lists the settings that can be read and changed, in display order
+var settingDefinitions = func() []settingDefinition {
+	defs := []settingDefinition{
+		{SettingDailyBudgetCoins, "500", "Coins to spend on a normal day", positiveInt},
+	}
+	for day := time.Monday; ; day = (day + 1) % 7 {
+		defs = append(defs, settingDefinition{
+			WeekdayBudgetKey(day), "",
+			"Coins to spend on " + day.String() + "s, empty to use the daily budget",
+			optional(positiveInt),
+		})
+		if day == time.Sunday {
+			break
+		}
+	}
+	return append(defs,
+		settingDefinition{SettingEnergyMultiplier, "1.5", "Cost multiplier for high-energy tasks", multiplier},
+		settingDefinition{SettingDifficultyMultiplier, "1.3", "Cost multiplier for hard tasks", multiplier},
+		settingDefinition{SettingFollowUpDays, "3", "Days to wait for a reply before a follow-up task is created, empty to never", optional(positiveInt)},
+		settingDefinition{SettingFollowUpPending, "yes", "Create follow-up tasks for messages still pending (yes or no)", yesNo},
+		settingDefinition{SettingFollowUpTypes, "message,email", "Kinds of threads that expect a reply, separated by commas", threadTypes},
+	)
+}()
+
+// findSetting looks up the definition of a setting key
+func findSetting(key string) (*settingDefinition, error) {
+	for i := range settingDefinitions {
+		if settingDefinitions[i].key == key {
+			return &settingDefinitions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("setting %q: %w", key, ErrNotFound)
+}
+
+func positiveInt(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("must be a positive whole number")
+	}
+	return nil
+}
+
+func multiplier(value string) error {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0.1 || f > 10 {
+		return fmt.Errorf("must be a number between 0.1 and 10")
+	}
+	return nil
+}
+
+func yesNo(value string) error {
+	if value != "yes" && value != "no" {
+		return fmt.Errorf("must be yes or no")
+	}
+	return nil
+}
+
+func threadTypes(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && !models.ThreadType(name).Valid() {
+			return fmt.Errorf("unknown thread type %q", name)
+		}
+	}
+	return nil
+}
+
+// optional also accepts an empty value
+func optional(validate func(string) error) func(string) error {
+	return func(value string) error {
+		if value == "" {
+			return nil
+		}
+		return validate(value)
+	}
+}
+
+// GetSettings returns every known setting with its current value
+func (db *DB) GetSettings() ([]models.Setting, error) {
+	rows, err := db.conn.Query(`SELECT key, value FROM settings`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]string)
+	for rows.Next() {
+		var (
+			key   string
+			value sql.NullString
+		)
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan setting: %w", err)
+		}
+		stored[key] = value.String
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+
+	settings := make([]models.Setting, 0, len(settingDefinitions))
+	for _, def := range settingDefinitions {
+		value, ok := stored[def.key]
+		if !ok {
+			value = def.def
+		}
+		settings = append(settings, models.Setting{
+			Key:         def.key,
+			Value:       value,
+			Default:     def.def,
+			Description: def.description,
+		})
+	}
+	return settings, nil
+}
+
+// GetSetting returns the value of a setting, or its default when it was never stored
+func (db *DB) GetSetting(key string) (string, error) {
+	def, err := findSetting(key)
+	if err != nil {
+		return "", err
+	}
+
+	var value sql.NullString
+	err = db.conn.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return def.def, nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get setting: %w", err)
+	}
+	return value.String, nil
+}
+
+// GetIntSetting returns a whole-number setting, or 0 when it is empty
+func (db *DB) GetIntSetting(key string) (int, error) {
+	value, err := db.GetSetting(key)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("setting %q is not a whole number: %w", key, err)
+	}
+	return n, nil
+}
+
+// GetFloatSetting returns a numeric setting, or 0 when it is empty
+func (db *DB) GetFloatSetting(key string) (float64, error) {
+	value, err := db.GetSetting(key)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("setting %q is not a number: %w", key, err)
+	}
+	return f, nil
+}
+
+// SetSetting validates and stores a single setting
+func (db *DB) SetSetting(key, value string) error {
+	return db.SetSettings(map[string]string{key: value})
+}
+
+// SetSettings validates and stores several settings at once; nothing is
+// stored if any of them is unknown or invalid. Budgets of today and later
+// that were not set by hand follow the new values.
+func (db *DB) SetSettings(values map[string]string) error {
+	for key, value := range values {
+		def, err := findSetting(key)
+		if err != nil {
+			return fmt.Errorf("%w: unknown setting %q", ErrInvalid, key)
+		}
+		if err := def.validate(strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("%w: %s %v", ErrInvalid, key, err)
+		}
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for key, value := range values {
+		_, err := tx.Exec(`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+			key, strings.TrimSpace(value), now)
+		if err != nil {
+			return fmt.Errorf("failed to save setting: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit settings: %w", err)
+	}
+
+	return db.refreshUpcomingBudgets()
+}
+
+// budgetCoinsFor returns the budget the settings give a date: the budget of
+// its weekday when one is set, else the daily budget
+func (db *DB) budgetCoinsFor(date time.Time) (int, error) {
+	coins, err := db.GetIntSetting(WeekdayBudgetKey(date.Weekday()))
+	if err != nil || coins > 0 {
+		return coins, err
+	}
+	return db.GetIntSetting(SettingDailyBudgetCoins)
+}
+
+// GetCostMultipliers returns the task cost multipliers from the settings
+func (db *DB) GetCostMultipliers() (models.CostMultipliers, error) {
+	multipliers := models.DefaultCostMultipliers
+
+	energy, err := db.GetFloatSetting(SettingEnergyMultiplier)
+	if err != nil {
+		return multipliers, err
+	}
+	if energy > 0 {
+		multipliers.HighEnergy = energy
+	}
+
+	difficulty, err := db.GetFloatSetting(SettingDifficultyMultiplier)
+	if err != nil {
+		return multipliers, err
+	}
+	if difficulty > 0 {
+		multipliers.Hard = difficulty
+	}
+
+	return multipliers, nil
+}
+
+// GetFollowUpRules returns the rules deciding which threads need a follow-up
+func (db *DB) GetFollowUpRules() (models.FollowUpRules, error) {
+	var rules models.FollowUpRules
+
+	days, err := db.GetIntSetting(SettingFollowUpDays)
+	if err != nil {
+		return rules, err
+	}
+	rules.AfterDays = days
+
+	pending, err := db.GetSetting(SettingFollowUpPending)
+	if err != nil {
+		return rules, err
+	}
+	rules.Pending = pending == "yes"
+
+	types, err := db.GetSetting(SettingFollowUpTypes)
+	if err != nil {
+		return rules, err
+	}
+	for _, name := range strings.Split(types, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			rules.Types = append(rules.Types, models.ThreadType(name))
+		}
+	}
+
+	return rules, nil
+}
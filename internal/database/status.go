@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// recordStatusChange appends a row to task_status_history
+func recordStatusChange(q querier, taskID int, oldStatus, newStatus models.TaskStatus, causeTaskID *int, reason string) error {
+	query := `INSERT INTO task_status_history (task_id, old_status, new_status, cause_task_id, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := q.Exec(query, taskID, oldStatus, newStatus, causeTaskID, reason, time.Now()); err != nil {
+		return fmt.Errorf("failed to record status change: %w", err)
+	}
+	return nil
+}
+
+// refreshDependents re-evaluates the blocked state of every task that has prereqID as a prerequisite
+func refreshDependents(q querier, prereqID int, reason string) error {
+	rows, err := q.Query(`SELECT task_id FROM task_prerequisites WHERE prerequisite_task_id = ?`, prereqID)
+	if err != nil {
+		return fmt.Errorf("failed to query dependents: %w", err)
+	}
+
+	var dependents []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan dependent: %w", err)
+		}
+		dependents = append(dependents, id)
+	}
+	rows.Close()
+
+	for _, id := range dependents {
+		if err := refreshBlocked(q, id, &prereqID, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshBlocked moves a pending task with open prerequisites to blocked and a
+// blocked task whose prerequisites are all done back to pending. Tasks that
+// are in progress or done are left alone.
+func refreshBlocked(q querier, taskID int, causeTaskID *int, reason string) error {
+	var status models.TaskStatus
+	if err := q.QueryRow(`SELECT status FROM tasks WHERE id = ?`, taskID).Scan(&status); err != nil {
+		return fmt.Errorf("failed to get task status: %w", err)
+	}
+
+	var open int
+	query := `
+		SELECT COUNT(*) FROM task_prerequisites tp
+		JOIN tasks t ON t.id = tp.prerequisite_task_id
+		WHERE tp.task_id = ? AND t.status != ?`
+	if err := q.QueryRow(query, taskID, models.StatusDone).Scan(&open); err != nil {
+		return fmt.Errorf("failed to count open prerequisites: %w", err)
+	}
+
+	newStatus := status
+	switch {
+	case status == models.StatusBlocked && open == 0:
+		newStatus = models.StatusPending
+	case status == models.StatusPending && open > 0:
+		newStatus = models.StatusBlocked
+	}
+	if newStatus == status {
+		return nil
+	}
+
+	_, err := q.Exec(`UPDATE tasks SET status = ?, updated_at = ? WHERE id = ?`, newStatus, time.Now(), taskID)
+	if err != nil {
+		return fmt.Errorf("failed to update task status: %w", err)
+	}
+
+	return recordStatusChange(q, taskID, status, newStatus, causeTaskID, reason)
+}
+
+// GetStatusHistory returns the status changes of a task, newest first
+func (db *DB) GetStatusHistory(taskID int) ([]models.StatusChange, error) {
+	query := `
+		SELECT h.id, h.task_id, t.title, h.old_status, h.new_status, h.cause_task_id, h.reason, h.created_at
+		FROM task_status_history h
+		JOIN tasks t ON t.id = h.task_id
+		WHERE h.task_id = ?
+		ORDER BY h.created_at DESC, h.id DESC`
+
+	rows, err := db.conn.Query(query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []models.StatusChange
+	for rows.Next() {
+		var (
+			change      models.StatusChange
+			causeTaskID sql.NullInt64
+			reason      sql.NullString
+		)
+		err := rows.Scan(&change.ID, &change.TaskID, &change.TaskTitle,
+			&change.OldStatus, &change.NewStatus, &causeTaskID, &reason, &change.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan status change: %w", err)
+		}
+		if causeTaskID.Valid {
+			change.CauseTaskID = &[]int{int(causeTaskID.Int64)}[0]
+		}
+		change.Reason = reason.String
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"testing"
+
+	"oppgaave/internal/models"
+)
+
+// TestBlockedFollowsPrerequisites walks a task through changes of its
+// prerequisites, checking its status and the recorded cause after each
+func TestBlockedFollowsPrerequisites(t *testing.T) {
+	db := newTestDB(t)
+	ids := make(map[string]int)
+	for _, title := range []string{"Meal prep", "Groceries", "Recipes"} {
+		task, err := db.CreateTask(&models.CreateTaskRequest{Title: title})
+		if err != nil {
+			t.Fatalf("CreateTask: %v", err)
+		}
+		ids[title] = task.ID
+	}
+	meal := ids["Meal prep"]
+	setStatus := func(title string, status models.TaskStatus) func() error {
+		return func() error { return db.UpdateTaskStatus(ids[title], status) }
+	}
+
+	tests := []struct {
+		name   string
+		change func() error
+		status models.TaskStatus
+		reason string // of the change it causes, empty when there is none
+	}{
+		{"first prerequisite added", func() error { return db.AddPrerequisite(meal, ids["Groceries"]) },
+			models.StatusBlocked, "prerequisite Groceries was added"},
+		{"second prerequisite added", func() error { return db.AddPrerequisite(meal, ids["Recipes"]) },
+			models.StatusBlocked, ""},
+		{"one prerequisite done", setStatus("Groceries", models.StatusDone), models.StatusBlocked, ""},
+		{"all prerequisites done", setStatus("Recipes", models.StatusDone), models.StatusPending, "Recipes was finished"},
+		{"prerequisite reopened", setStatus("Recipes", models.StatusPending), models.StatusBlocked, "Recipes was reopened"},
+		{"open prerequisite removed", func() error { return db.RemovePrerequisite(meal, ids["Recipes"]) },
+			models.StatusPending, "prerequisite Recipes was removed"},
+		{"started", setStatus("Meal prep", models.StatusInProgress), models.StatusInProgress, ""},
+		{"prerequisite reopened while in progress", setStatus("Groceries", models.StatusPending), models.StatusInProgress, ""},
+	}
+	seen := 0
+	for _, tt := range tests {
+		if err := tt.change(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		task, err := db.GetTask(meal)
+		if err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		if task.Status != tt.status {
+			t.Errorf("%s: status = %s, want %s", tt.name, task.Status, tt.status)
+		}
+
+		history, err := db.GetStatusHistory(meal)
+		if err != nil {
+			t.Fatalf("GetStatusHistory: %v", err)
+		}
+		if tt.reason != "" {
+			if len(history) != seen+1 || history[0].Reason != tt.reason || history[0].NewStatus != tt.status ||
+				history[0].CauseTaskID == nil {
+				t.Errorf("%s: history = %+v, want a change to %s because %s", tt.name, history, tt.status, tt.reason)
+			}
+		}
+		seen = len(history)
+	}
+}
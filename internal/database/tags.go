@@ -0,0 +1,373 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// tagColumns selects a tag with its task counts from tags tg
+const tagColumns = `
+	SELECT tg.id, tg.name, tg.color, tg.emoji, tg.created_at,
+		COUNT(t.id), COUNT(CASE WHEN t.status != 'done' THEN 1 END)
+	FROM tags tg
+	LEFT JOIN task_tags tt ON tt.tag_id = tg.id
+	LEFT JOIN tasks t ON t.id = tt.task_id`
+
+// scanTag reads a row selected with tagColumns
+func scanTag(row rowScanner) (*models.Tag, error) {
+	tag := &models.Tag{}
+	var color, emoji sql.NullString
+	if err := row.Scan(&tag.ID, &tag.Name, &color, &emoji, &tag.CreatedAt, &tag.TaskCount, &tag.OpenCount); err != nil {
+		return nil, err
+	}
+	tag.Color = color.String
+	tag.Emoji = emoji.String
+	return tag, nil
+}
+
+// ListTags returns every tag with its task counts, by name
+func (db *DB) ListTags() ([]models.Tag, error) {
+	rows, err := db.conn.Query(tagColumns + ` GROUP BY tg.id ORDER BY tg.name COLLATE NOCASE`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, *tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetTag returns a tag with its task counts
+func (db *DB) GetTag(id int) (*models.Tag, error) {
+	tag, err := scanTag(db.conn.QueryRow(tagColumns+` WHERE tg.id = ? GROUP BY tg.id`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("tag %d: %w", id, ErrNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+	return tag, nil
+}
+
+// CreateTag adds a tag that is not on any task yet
+func (db *DB) CreateTag(req *models.TagRequest) (*models.Tag, error) {
+	if req.Name == nil {
+		return nil, fmt.Errorf("%w: tag name is required", ErrInvalid)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := db.checkTagName(db.conn, *req.Name, 0); err != nil {
+		return nil, err
+	}
+
+	var color, emoji string
+	if req.Color != nil {
+		color = *req.Color
+	}
+	if req.Emoji != nil {
+		emoji = *req.Emoji
+	}
+	result, err := db.conn.Exec(`INSERT INTO tags (name, color, emoji, created_at) VALUES (?, ?, ?, ?)`,
+		*req.Name, nullString(color), nullString(emoji), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tag: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag ID: %w", err)
+	}
+	return db.GetTag(int(id))
+}
+
+// UpdateTag changes the fields of a tag that were sent. A new name renames
+// the tag on every task that has it.
+func (db *DB) UpdateTag(id int, req *models.TagRequest) (*models.Tag, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := tagExists(tx, id); err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		if err := db.checkTagName(tx, *req.Name, id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`UPDATE tags SET name = ? WHERE id = ?`, *req.Name, id); err != nil {
+			return nil, fmt.Errorf("failed to rename tag: %w", err)
+		}
+	}
+	if req.Color != nil {
+		if _, err := tx.Exec(`UPDATE tags SET color = ? WHERE id = ?`, nullString(*req.Color), id); err != nil {
+			return nil, fmt.Errorf("failed to update tag: %w", err)
+		}
+	}
+	if req.Emoji != nil {
+		if _, err := tx.Exec(`UPDATE tags SET emoji = ? WHERE id = ?`, nullString(*req.Emoji), id); err != nil {
+			return nil, fmt.Errorf("failed to update tag: %w", err)
+		}
+	}
+
+	if req.Name != nil {
+		if err := syncTaggedTasks(tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tag update: %w", err)
+	}
+	return db.GetTag(id)
+}
+
+// DeleteTag removes a tag from every task and deletes it
+func (db *DB) DeleteTag(id int) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := tagExists(tx, id); err != nil {
+		return err
+	}
+	taskIDs, err := taggedTaskIDs(tx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM task_tags WHERE tag_id = ?`,
+		`DELETE FROM tags WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return fmt.Errorf("failed to delete tag: %w", err)
+		}
+	}
+	for _, taskID := range taskIDs {
+		if _, err := syncTaskTags(tx, taskID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tag deletion: %w", err)
+	}
+	return nil
+}
+
+// MergeTags moves every task of one tag over to another and deletes the
+// first, returning the tag that is kept
+func (db *DB) MergeTags(id, into int) (*models.Tag, error) {
+	if id == into {
+		return nil, fmt.Errorf("%w: cannot merge a tag into itself", ErrInvalid)
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, tagID := range []int{id, into} {
+		if err := tagExists(tx, tagID); err != nil {
+			return nil, err
+		}
+	}
+	taskIDs, err := taggedTaskIDs(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tasks that already have both keep their place for the merged tag
+	for _, stmt := range []string{
+		`INSERT OR IGNORE INTO task_tags (task_id, tag_id, created_at)
+			SELECT task_id, ?, created_at FROM task_tags WHERE tag_id = ? ORDER BY id`,
+		`DELETE FROM task_tags WHERE tag_id = ?`,
+		`DELETE FROM tags WHERE id = ?`,
+	} {
+		args := []interface{}{id}
+		if strings.Count(stmt, "?") == 2 {
+			args = []interface{}{into, id}
+		}
+		if _, err := tx.Exec(stmt, args...); err != nil {
+			return nil, fmt.Errorf("failed to merge tags: %w", err)
+		}
+	}
+	for _, taskID := range taskIDs {
+		if _, err := syncTaskTags(tx, taskID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tag merge: %w", err)
+	}
+	return db.GetTag(into)
+}
+
+// checkTagName fails if another tag than id already has the name, ignoring case
+func (db *DB) checkTagName(q querier, name string, id int) error {
+	var existing int
+	err := q.QueryRow(`SELECT id FROM tags WHERE name = ? AND id != ?`, name, id).Scan(&existing)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to check tag name: %w", err)
+	}
+	return fmt.Errorf("%w: tag %q already exists as tag %d, merge the tags instead", ErrConflict, name, existing)
+}
+
+// tagExists fails with ErrNotFound for an unknown tag
+func tagExists(q querier, id int) error {
+	var exists bool
+	if err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM tags WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check tag: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("tag %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// taggedTaskIDs returns the tasks that have a tag
+func taggedTaskIDs(q querier, tagID int) ([]int, error) {
+	rows, err := q.Query(`SELECT task_id FROM task_tags WHERE tag_id = ?`, tagID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tagged tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan tagged task: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// syncTaggedTasks refreshes the JSON tag names of every task with a tag
+func syncTaggedTasks(q querier, tagID int) error {
+	taskIDs, err := taggedTaskIDs(q, tagID)
+	if err != nil {
+		return err
+	}
+	for _, taskID := range taskIDs {
+		if _, err := syncTaskTags(q, taskID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTaskTags gives a task exactly the named tags, in order, creating the
+// tags that do not exist yet. It returns the names as stored, which keep the
+// spelling of existing tags.
+func setTaskTags(q querier, taskID int, names []string) (models.Tags, error) {
+	tags, err := models.NormalizeTags(names)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	if _, err := q.Exec(`DELETE FROM task_tags WHERE task_id = ?`, taskID); err != nil {
+		return nil, fmt.Errorf("failed to clear task tags: %w", err)
+	}
+	now := time.Now()
+	for _, name := range tags {
+		if _, err := q.Exec(`INSERT INTO tags (name, created_at) VALUES (?, ?) ON CONFLICT(name) DO NOTHING`, name, now); err != nil {
+			return nil, fmt.Errorf("failed to create tag: %w", err)
+		}
+		if _, err := q.Exec(`INSERT INTO task_tags (task_id, tag_id, created_at) SELECT ?, id, ? FROM tags WHERE name = ?`, taskID, now, name); err != nil {
+			return nil, fmt.Errorf("failed to tag task: %w", err)
+		}
+	}
+
+	return syncTaskTags(q, taskID)
+}
+
+// syncTaskTags copies the tag names of a task into tasks.tags, which the
+// search index and estimate calibration read, and returns them
+func syncTaskTags(q querier, taskID int) (models.Tags, error) {
+	rows, err := q.Query(`
+		SELECT tg.name FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id
+		WHERE tt.task_id = ? ORDER BY tt.id`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task tags: %w", err)
+	}
+	names := models.Tags{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan task tag: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query task tags: %w", err)
+	}
+
+	if _, err := q.Exec(`UPDATE tasks SET tags = ? WHERE id = ?`, names, taskID); err != nil {
+		return nil, fmt.Errorf("failed to update task tags: %w", err)
+	}
+	return names, nil
+}
+
+// loadTaskTags loads the tags of a task from task_tags
+func (db *DB) loadTaskTags(task *models.Task) error {
+	rows, err := db.conn.Query(`
+		SELECT tg.id, tg.name, tg.color, tg.emoji, tg.created_at
+		FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id
+		WHERE tt.task_id = ? ORDER BY tt.id`, task.ID)
+	if err != nil {
+		return fmt.Errorf("failed to query task tags: %w", err)
+	}
+	defer rows.Close()
+
+	task.Tags = models.Tags{}
+	task.TagDetails = nil
+	for rows.Next() {
+		var (
+			tag          models.Tag
+			color, emoji sql.NullString
+		)
+		if err := rows.Scan(&tag.ID, &tag.Name, &color, &emoji, &tag.CreatedAt); err != nil {
+			return fmt.Errorf("failed to scan task tag: %w", err)
+		}
+		tag.Color = color.String
+		tag.Emoji = emoji.String
+		task.Tags = append(task.Tags, tag.Name)
+		task.TagDetails = append(task.TagDetails, tag)
+	}
+	return rows.Err()
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// threadColumns selects a thread from contact_threads th with the title of
+// its task
+const threadColumns = `
+	SELECT th.id, th.contact_id, th.task_id, th.subject, th.message, th.thread_type,
+		th.direction, th.status, th.created_at, t.title
+	FROM contact_threads th
+	LEFT JOIN tasks t ON t.id = th.task_id`
+
+// scanThread reads a row selected with threadColumns
+func scanThread(row rowScanner) (*models.ContactThread, error) {
+	thread := &models.ContactThread{}
+	var (
+		taskID                                sql.NullInt64
+		subject, threadType, direction, title sql.NullString
+		status                                sql.NullString
+	)
+	err := row.Scan(&thread.ID, &thread.ContactID, &taskID, &subject, &thread.Message,
+		&threadType, &direction, &status, &thread.CreatedAt, &title)
+	if err != nil {
+		return nil, err
+	}
+	if taskID.Valid {
+		id := int(taskID.Int64)
+		thread.TaskID = &id
+	}
+	thread.Subject = subject.String
+	thread.ThreadType = models.ThreadType(threadType.String)
+	thread.Direction = direction.String
+	thread.Status = status.String
+	thread.TaskTitle = title.String
+	return thread, nil
+}
+
+// GetContactThreads returns the threads with a contact, oldest first, only
+// those of threadType unless it is empty
+func (db *DB) GetContactThreads(contactID int, threadType models.ThreadType) ([]models.ContactThread, error) {
+	if threadType != "" && !threadType.Valid() {
+		return nil, fmt.Errorf("%w: unknown thread type %q", ErrInvalid, threadType)
+	}
+	if err := contactExists(db.conn, contactID); err != nil {
+		return nil, err
+	}
+
+	query := threadColumns + ` WHERE th.contact_id = ?`
+	args := []interface{}{contactID}
+	if threadType != "" {
+		query += ` AND th.thread_type = ?`
+		args = append(args, threadType)
+	}
+	query += ` ORDER BY th.created_at, th.id`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contact threads: %w", err)
+	}
+	defer rows.Close()
+
+	threads := []models.ContactThread{}
+	for rows.Next() {
+		thread, err := scanThread(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan thread: %w", err)
+		}
+		threads = append(threads, *thread)
+	}
+	return threads, rows.Err()
+}
+
+// GetThread returns a single thread
+func (db *DB) GetThread(id int) (*models.ContactThread, error) {
+	thread, err := scanThread(db.conn.QueryRow(threadColumns+` WHERE th.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("thread %d: %w", id, ErrNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get thread: %w", err)
+	}
+	return thread, nil
+}
+
+// CreateThread records a message, email, call or meeting with a contact,
+// optionally about a task
+func (db *DB) CreateThread(contactID int, req *models.ThreadRequest) (*models.ContactThread, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := contactExists(db.conn, contactID); err != nil {
+		return nil, err
+	}
+	if req.TaskID != nil {
+		if _, err := db.taskTitles(*req.TaskID); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := db.conn.Exec(`
+		INSERT INTO contact_threads (contact_id, task_id, subject, message, thread_type, direction, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		contactID, req.TaskID, nullString(req.Subject), req.Message, req.ThreadType,
+		req.Direction, req.Status, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create thread: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get thread ID: %w", err)
+	}
+	return db.GetThread(int(id))
+}
+
+// UpdateThread changes the status of a thread with a contact. A pending
+// thread marked as sent counts as sent from now on, so the wait for a reply
+// starts when it went out.
+func (db *DB) UpdateThread(contactID, id int, req *models.ThreadUpdate) (*models.ContactThread, error) {
+	thread, err := db.GetThread(id)
+	if err != nil {
+		return nil, err
+	}
+	if thread.ContactID != contactID {
+		return nil, fmt.Errorf("thread %d of contact %d: %w", id, contactID, ErrNotFound)
+	}
+	if err := req.Validate(thread.Direction); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if req.Status == nil || *req.Status == thread.Status {
+		return thread, nil
+	}
+
+	createdAt := thread.CreatedAt
+	if thread.Status == models.ThreadPending && *req.Status == models.ThreadSent {
+		createdAt = time.Now()
+	}
+	_, err = db.conn.Exec(`UPDATE contact_threads SET status = ?, created_at = ? WHERE id = ?`,
+		*req.Status, createdAt, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update thread: %w", err)
+	}
+	return db.GetThread(id)
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+func TestUpdateThread(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction string
+		from, to  string
+		err       error
+		renewed   bool // counts as sent now
+	}{
+		{"pending message sent", models.DirectionOutbound, models.ThreadPending, models.ThreadSent, nil, true},
+		{"sent message failed", models.DirectionOutbound, models.ThreadSent, models.ThreadFailed, nil, false},
+		{"failed message sent again", models.DirectionOutbound, models.ThreadFailed, models.ThreadSent, nil, false},
+		{"unchanged", models.DirectionOutbound, models.ThreadPending, models.ThreadPending, nil, false},
+		{"outbound received", models.DirectionOutbound, models.ThreadSent, models.ThreadReceived, ErrInvalid, false},
+		{"inbound sent", models.DirectionInbound, models.ThreadReceived, models.ThreadSent, ErrInvalid, false},
+		{"unknown status", models.DirectionOutbound, models.ThreadSent, "lost", ErrInvalid, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			name := "Sam"
+			contact, err := db.CreateContact(&models.ContactRequest{Name: &name})
+			if err != nil {
+				t.Fatalf("CreateContact: %v", err)
+			}
+			thread, err := db.CreateThread(contact.ID, &models.ThreadRequest{
+				Message: "Lunch on Friday?", Direction: tt.direction, Status: tt.from,
+			})
+			if err != nil {
+				t.Fatalf("CreateThread: %v", err)
+			}
+			written := time.Now().AddDate(0, 0, -5)
+			if _, err := db.conn.Exec(`UPDATE contact_threads SET created_at = ? WHERE id = ?`, written, thread.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := db.UpdateThread(contact.ID, thread.ID, &models.ThreadUpdate{Status: &tt.to})
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("UpdateThread() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateThread: %v", err)
+			}
+			if got.Status != tt.to {
+				t.Errorf("status = %s, want %s", got.Status, tt.to)
+			}
+			if renewed := time.Since(got.CreatedAt) < time.Hour; renewed != tt.renewed {
+				t.Errorf("created at %v, want renewed = %v", got.CreatedAt, tt.renewed)
+			}
+		})
+	}
+}
+
+func TestUpdateThreadOfAnotherContact(t *testing.T) {
+	db := newTestDB(t)
+	var ids []int
+	for _, name := range []string{"Sam", "Alex"} {
+		contact, err := db.CreateContact(&models.ContactRequest{Name: &name})
+		if err != nil {
+			t.Fatalf("CreateContact: %v", err)
+		}
+		ids = append(ids, contact.ID)
+	}
+	thread, err := db.CreateThread(ids[0], &models.ThreadRequest{Message: "Lunch on Friday?"})
+	if err != nil {
+		t.Fatalf("CreateThread: %v", err)
+	}
+	status := models.ThreadFailed
+	if _, err := db.UpdateThread(ids[1], thread.ID, &models.ThreadUpdate{Status: &status}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateThread() error = %v, want ErrNotFound", err)
+	}
+}
+
+// TestSentThreadIsWaitedOn checks that a pending message marked as sent shows
+// up as waiting for a reply
+func TestSentThreadIsWaitedOn(t *testing.T) {
+	db := newTestDB(t)
+	name := "Sam"
+	contact, err := db.CreateContact(&models.ContactRequest{Name: &name})
+	if err != nil {
+		t.Fatalf("CreateContact: %v", err)
+	}
+	thread, err := db.CreateThread(contact.ID, &models.ThreadRequest{Message: "Lunch on Friday?", Status: models.ThreadPending})
+	if err != nil {
+		t.Fatalf("CreateThread: %v", err)
+	}
+
+	tests := []struct {
+		status  string
+		waiting int
+	}{
+		{models.ThreadPending, 0},
+		{models.ThreadSent, 1},
+	}
+	for _, tt := range tests {
+		status := tt.status
+		if _, err := db.UpdateThread(contact.ID, thread.ID, &models.ThreadUpdate{Status: &status}); err != nil {
+			t.Fatalf("UpdateThread: %v", err)
+		}
+		waiting, err := db.GetWaitingOn(time.Now())
+		if err != nil {
+			t.Fatalf("GetWaitingOn: %v", err)
+		}
+		if len(waiting) != tt.waiting {
+			t.Errorf("%s: waiting on %d contacts, want %d", tt.status, len(waiting), tt.waiting)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"oppgaave/internal/models"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// openTimeEntry starts tracking time on a task. A schedule row planned for
+// today that has not been started yet is reused, otherwise a new one is added.
+func openTimeEntry(q querier, taskID int, now time.Time) error {
+	var open int
+	err := q.QueryRow(`SELECT COUNT(*) FROM task_schedule
+		WHERE task_id = ? AND actual_start_time IS NOT NULL AND actual_end_time IS NULL`, taskID).Scan(&open)
+	if err != nil {
+		return fmt.Errorf("failed to check open time entries: %w", err)
+	}
+	if open > 0 {
+		return nil
+	}
+
+	today := now.Format("2006-01-02")
+	var scheduleID int
+	err = q.QueryRow(`SELECT id FROM task_schedule
+		WHERE task_id = ? AND scheduled_date = ? AND actual_start_time IS NULL
+		ORDER BY start_time LIMIT 1`, taskID, today).Scan(&scheduleID)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = q.Exec(`INSERT INTO task_schedule (task_id, scheduled_date, actual_start_time, created_at)
+			VALUES (?, ?, ?, ?)`, taskID, today, now, now)
+	case err == nil:
+		_, err = q.Exec(`UPDATE task_schedule SET actual_start_time = ? WHERE id = ?`, now, scheduleID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open time entry: %w", err)
+	}
+
+	return nil
+}
+
+// closeTimeEntries stops every running time entry of a task and returns the minutes they covered
+func closeTimeEntries(q querier, taskID int, now time.Time) (float64, error) {
+	var minutes float64
+	rows, err := q.Query(`SELECT actual_start_time FROM task_schedule
+		WHERE task_id = ? AND actual_start_time IS NOT NULL AND actual_end_time IS NULL`, taskID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query open time entries: %w", err)
+	}
+	for rows.Next() {
+		var start time.Time
+		if err := rows.Scan(&start); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan time entry: %w", err)
+		}
+		minutes += now.Sub(start).Minutes()
+	}
+	rows.Close()
+
+	_, err = q.Exec(`UPDATE task_schedule SET actual_end_time = ?
+		WHERE task_id = ? AND actual_start_time IS NOT NULL AND actual_end_time IS NULL`, now, taskID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to close time entries: %w", err)
+	}
+	return minutes, nil
+}
+
+// GetTimeEntries returns the tracked time entries of a task together with
+// its estimated and actual minutes
+func (db *DB) GetTimeEntries(taskID int) (*models.TimeReport, error) {
+	report := &models.TimeReport{TaskID: taskID, Entries: []models.TaskSchedule{}}
+	err := db.conn.QueryRow(`SELECT estimated_duration_minutes FROM tasks WHERE id = ?`, taskID).
+		Scan(&report.EstimatedMinutes)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("task %d: %w", taskID, ErrNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	query := `
+		SELECT id, task_id, scheduled_date, start_time, estimated_end_time,
+			actual_start_time, actual_end_time, created_at
+		FROM task_schedule
+		WHERE task_id = ? AND actual_start_time IS NOT NULL
+		ORDER BY actual_start_time`
+
+	rows, err := db.conn.Query(query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan time entry: %w", err)
+		}
+		report.ActualMinutes += entry.ActualMinutes()
+		if entry.ActualEndTime == nil {
+			report.Running = true
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	return report, nil
+}
+
+// scanSchedule scans a task_schedule row. start_time and estimated_end_time
+// are declared as TIME, which the driver returns as text, so they are parsed here.
+func scanSchedule(row rowScanner) (models.TaskSchedule, error) {
+	var (
+		entry                   models.TaskSchedule
+		startTime, estimatedEnd sql.NullString
+		actualStart, actualEnd  sql.NullTime
+	)
+
+	err := row.Scan(&entry.ID, &entry.TaskID, &entry.ScheduledDate, &startTime, &estimatedEnd,
+		&actualStart, &actualEnd, &entry.CreatedAt)
+	if err != nil {
+		return entry, err
+	}
+
+	if entry.StartTime, err = parseTimeColumn(startTime); err != nil {
+		return entry, err
+	}
+	if entry.EstimatedEndTime, err = parseTimeColumn(estimatedEnd); err != nil {
+		return entry, err
+	}
+	if actualStart.Valid {
+		entry.ActualStartTime = &actualStart.Time
+	}
+	if actualEnd.Valid {
+		entry.ActualEndTime = &actualEnd.Time
+	}
+
+	return entry, nil
+}
+
+// parseTimeColumn parses a timestamp stored in a column the driver does not convert
+func parseTimeColumn(value sql.NullString) (*time.Time, error) {
+	if !value.Valid || value.String == "" {
+		return nil, nil
+	}
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(layout, value.String, time.UTC); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot parse time %q", value.String)
+}
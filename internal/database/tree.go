@@ -0,0 +1,117 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"oppgaave/internal/models"
+)
+
+// taskColumns lists the columns of tasks, selected as t, in the order
+// scanTask expects
+const taskColumns = `t.id, t.title, t.description, t.parent_id, t.estimated_duration_minutes,
+	t.deadline, t.priority, t.status, t.tags, t.energy_level, t.difficulty, t.money_cost,
+	t.task_type, t.event_location, t.event_start, t.event_end, t.radar_position_x, t.radar_position_y,
+	t.created_at, t.updated_at, t.completed_at, t.recurrence, t.series_id, t.occurrence_date`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTask scans a row selected with taskColumns
+func scanTask(row rowScanner) (models.Task, error) {
+	var task models.Task
+	var (
+		parentID, seriesID                                          sql.NullInt64
+		deadline, eventStart, eventEnd, completedAt, occurrenceDate sql.NullTime
+		description, eventLocation, recurrence                      sql.NullString
+	)
+
+	err := row.Scan(
+		&task.ID, &task.Title, &description, &parentID,
+		&task.EstimatedDurationMins, &deadline, &task.Priority,
+		&task.Status, &task.Tags, &task.EnergyLevel, &task.Difficulty,
+		&task.MoneyCost, &task.TaskType, &eventLocation, &eventStart,
+		&eventEnd, &task.RadarPositionX, &task.RadarPositionY,
+		&task.CreatedAt, &task.UpdatedAt, &completedAt,
+		&recurrence, &seriesID, &occurrenceDate)
+	if err != nil {
+		return task, err
+	}
+
+	// Handle nullable fields
+	if parentID.Valid {
+		task.ParentID = &[]int{int(parentID.Int64)}[0]
+	}
+	if deadline.Valid {
+		task.Deadline = &deadline.Time
+	}
+	if description.Valid {
+		task.Description = description.String
+	}
+	if eventLocation.Valid {
+		task.EventLocation = eventLocation.String
+	}
+	if eventStart.Valid {
+		task.EventStart = &eventStart.Time
+	}
+	if eventEnd.Valid {
+		task.EventEnd = &eventEnd.Time
+	}
+	if completedAt.Valid {
+		task.CompletedAt = &completedAt.Time
+	}
+	err = setSeries(&task, recurrence, seriesID, occurrenceDate)
+	return task, err
+}
+
+// GetTaskTree retrieves a task with its full recursive subtask hierarchy,
+// loaded with a single recursive query, and rolled-up progress on every node
+func (db *DB) GetTaskTree(id int) (*models.Task, error) {
+	root, err := db.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		WITH RECURSIVE subtree(id) AS (
+			SELECT id FROM tasks WHERE parent_id = ?
+			UNION
+			SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id = s.id
+		)
+		SELECT ` + taskColumns + `
+		FROM tasks t WHERE t.id IN (SELECT id FROM subtree)
+		ORDER BY t.priority DESC, t.id ASC`
+
+	rows, err := db.conn.Query(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task tree: %w", err)
+	}
+	defer rows.Close()
+
+	children := make(map[int][]models.Task)
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subtask: %w", err)
+		}
+		children[*task.ParentID] = append(children[*task.ParentID], task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query task tree: %w", err)
+	}
+
+	root.Subtasks = buildSubtree(children, id)
+	root.ComputeRollup()
+	return root, nil
+}
+
+// buildSubtree assembles the nested subtasks of parentID from a parent→children index
+func buildSubtree(children map[int][]models.Task, parentID int) []models.Task {
+	subtasks := children[parentID]
+	for i := range subtasks {
+		subtasks[i].Subtasks = buildSubtree(children, subtasks[i].ID)
+	}
+	return subtasks
+}
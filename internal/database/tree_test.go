@@ -0,0 +1,80 @@
+package database
+
+import (
+	"testing"
+
+	"oppgaave/internal/models"
+)
+
+// TestTaskReadersAgree checks that every way of reading a task returns the
+// same columns, series fields included
+func TestTaskReadersAgree(t *testing.T) {
+	db := newTestDB(t)
+	parent, err := db.CreateTask(&models.CreateTaskRequest{Title: "Tidy the flat"})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	chore, err := db.CreateTask(&models.CreateTaskRequest{
+		Title:      "Water the plants",
+		ParentID:   &parent.ID,
+		Recurrence: &models.Recurrence{Frequency: models.RecurDaily, Interval: 1},
+	})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	dependent, err := db.CreateTask(&models.CreateTaskRequest{Title: "Go on holiday"})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if err := db.AddPrerequisite(dependent.ID, chore.ID); err != nil {
+		t.Fatalf("AddPrerequisite: %v", err)
+	}
+
+	got, err := db.GetTask(chore.ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	withSubtask, err := db.GetTask(parent.ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	tree, err := db.GetTaskTree(parent.ID)
+	if err != nil {
+		t.Fatalf("GetTaskTree: %v", err)
+	}
+	withPrerequisite, err := db.GetTask(dependent.ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	listed, _, err := db.ListTasks(TaskFilter{ParentID: &parent.ID})
+	if err != nil {
+		t.Fatalf("ListTasks: %v", err)
+	}
+	if len(withSubtask.Subtasks) != 1 || len(tree.Subtasks) != 1 || len(withPrerequisite.Prerequisites) != 1 || len(listed) != 1 {
+		t.Fatal("task missing from a reader")
+	}
+
+	readers := []struct {
+		name string
+		task models.Task
+	}{
+		{"GetTask", *got},
+		{"subtask", withSubtask.Subtasks[0]},
+		{"tree", tree.Subtasks[0]},
+		{"prerequisite", withPrerequisite.Prerequisites[0]},
+		{"ListTasks", listed[0]},
+	}
+	for _, r := range readers {
+		task := r.task
+		switch {
+		case task.ID != chore.ID || task.Title != chore.Title:
+			t.Errorf("%s: read task %d %q, want %d", r.name, task.ID, task.Title, chore.ID)
+		case task.ParentID == nil || *task.ParentID != parent.ID:
+			t.Errorf("%s: parent = %v, want %d", r.name, task.ParentID, parent.ID)
+		case task.Recurrence == nil || task.Recurrence.Frequency != models.RecurDaily:
+			t.Errorf("%s: recurrence = %+v, want daily", r.name, task.Recurrence)
+		case task.OccurrenceDate == nil || !task.OccurrenceDate.Equal(*chore.OccurrenceDate):
+			t.Errorf("%s: occurrence date = %v, want %v", r.name, task.OccurrenceDate, chore.OccurrenceDate)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Package format turns task data into the short human-readable text shown
+// by both the web templates and the command line
+package format
+
+import (
+	"fmt"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// Duration formats minutes as e.g. "45m", "2h" or "1h30m"
+func Duration(minutes int) string {
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	hours := minutes / 60
+	mins := minutes % 60
+	if mins == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, mins)
+}
+
+// Time formats the clock time, or "" for nil
+func Time(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("15:04")
+}
+
+// Date formats a short date like "Jan 2", or "" for nil
+func Date(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("Jan 2")
+}
+
+// Currency formats coins as money
+func Currency(coins int) string {
+	return fmt.Sprintf("$%d", coins)
+}
+
+// StatusIcon returns the symbol shown for a task status
+func StatusIcon(status models.TaskStatus) string {
+	switch status {
+	case models.StatusDone:
+		return "✓"
+	case models.StatusInProgress:
+		return "⏳"
+	case models.StatusBlocked:
+		return "🚫"
+	default:
+		return "○"
+	}
+}
+
+// PriorityText names a priority level
+func PriorityText(priority int) string {
+	switch priority {
+	case 3:
+		return "High"
+	case 2:
+		return "Medium"
+	default:
+		return "Low"
+	}
+}
+
+// EnergyText names an energy level
+func EnergyText(energy int) string {
+	switch energy {
+	case 3:
+		return "High Energy"
+	case 2:
+		return "Medium Energy"
+	default:
+		return "Low Energy"
+	}
+}
+
+// TaskTypeText names a task type
+func TaskTypeText(taskType models.TaskType) string {
+	switch taskType {
+	case models.TypeAppointment:
+		return "Appointment"
+	case models.TypeEvent:
+		return "Event"
+	case models.TypeConcert:
+		return "Concert"
+	case models.TypeMeeting:
+		return "Meeting"
+	default:
+		return "Task"
+	}
+}
+
+// ThreadTypeText names a thread type with its symbol
+func ThreadTypeText(threadType models.ThreadType) string {
+	switch threadType {
+	case models.ThreadEmail:
+		return "📧 Email"
+	case models.ThreadCall:
+		return "📞 Call"
+	case models.ThreadMeeting:
+		return "🤝 Meeting"
+	default:
+		return "💬 Message"
+	}
+}
+
+// FileSize formats a size in bytes as e.g. "820 B", "14 KB" or "2.5 MB"
+func FileSize(bytes int64) string {
+	switch {
+	case bytes < 1<<10:
+		return fmt.Sprintf("%d B", bytes)
+	case bytes < 1<<20:
+		return fmt.Sprintf("%d KB", bytes>>10)
+	}
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+}
+
+// ParseDate parses a YYYY-MM-DD date in local time, also accepting "today" and "tomorrow"
+func ParseDate(value string) (time.Time, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch value {
+	case "", "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return date, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	return date, nil
+}
@@ -2,99 +2,61 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"oppgaave/internal/database"
+	"oppgaave/internal/format"
+	"oppgaave/internal/ical"
 	"oppgaave/internal/models"
+	"oppgaave/internal/storage"
 
 	"github.com/gorilla/mux"
 )
 
+// maxCalendarSize limits the size of uploaded calendar files
+const maxCalendarSize = 10 << 20
+
 type Handlers struct {
 	db        *database.DB
+	files     *storage.Store
 	templates *template.Template
 }
 
 // New creates a new handlers instance
-func New(db *database.DB) *Handlers {
+func New(db *database.DB, files *storage.Store) *Handlers {
 	// Load templates with custom functions
 	funcMap := template.FuncMap{
-		"formatDuration": func(minutes int) string {
-			if minutes < 60 {
-				return fmt.Sprintf("%dm", minutes)
-			}
-			hours := minutes / 60
-			mins := minutes % 60
-			if mins == 0 {
-				return fmt.Sprintf("%dh", hours)
-			}
-			return fmt.Sprintf("%dh%dm", hours, mins)
-		},
-		"formatTime": func(t *time.Time) string {
-			if t == nil {
-				return ""
-			}
-			return t.Format("15:04")
-		},
-		"formatDate": func(t *time.Time) string {
-			if t == nil {
-				return ""
-			}
-			return t.Format("Jan 2")
-		},
-		"formatCurrency": func(coins int) string {
-			return fmt.Sprintf("$%d", coins)
-		},
-		"statusIcon": func(status models.TaskStatus) string {
-			switch status {
-			case models.StatusDone:
-				return "✓"
-			case models.StatusInProgress:
-				return "⏳"
-			case models.StatusBlocked:
-				return "🚫"
-			default:
-				return "○"
-			}
-		},
-		"priorityText": func(priority int) string {
-			switch priority {
-			case 3:
-				return "High"
-			case 2:
-				return "Medium"
-			default:
-				return "Low"
-			}
-		},
-		"energyText": func(energy int) string {
-			switch energy {
-			case 3:
-				return "High Energy"
-			case 2:
-				return "Medium Energy"
-			default:
-				return "Low Energy"
+		"formatDuration": format.Duration,
+		"formatTime":     format.Time,
+		"formatDate":     format.Date,
+		"formatCurrency": format.Currency,
+		"statusIcon":     format.StatusIcon,
+		"priorityText":   format.PriorityText,
+		"energyText":     format.EnergyText,
+		"taskTypeText":   format.TaskTypeText,
+		"threadTypeText": format.ThreadTypeText,
+		"formatSize":     format.FileSize,
+		// First letter of a name, for avatars without a picture
+		"initial": func(name string) string {
+			for _, r := range name {
+				return strings.ToUpper(string(r))
 			}
+			return "?"
 		},
-		"taskTypeText": func(taskType models.TaskType) string {
-			switch taskType {
-			case models.TypeAppointment:
-				return "Appointment"
-			case models.TypeEvent:
-				return "Event"
-			case models.TypeConcert:
-				return "Concert"
-			case models.TypeMeeting:
-				return "Meeting"
-			default:
-				return "Task"
-			}
+		// Search snippets are escaped by the database, apart from <mark>
+		"snippet": func(s string) template.HTML {
+			return template.HTML(s)
 		},
 		"mul": func(a, b int) int {
 			return a * b
@@ -117,6 +79,7 @@ func New(db *database.DB) *Handlers {
 
 	return &Handlers{
 		db:        db,
+		files:     files,
 		templates: templates,
 	}
 }
@@ -138,29 +101,42 @@ func (h *Handlers) Dashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Calculate spent budget from pending/in-progress tasks
-	spentCoins := 0
 	var todayTasks []models.Task
 	for i, task := range tasks {
 		// Calculate radar position for each task
 		tasks[i].CalculateRadarPosition()
 		
 		if task.Status == models.StatusPending || task.Status == models.StatusInProgress {
-			spentCoins += task.MoneyCost
 			todayTasks = append(todayTasks, tasks[i])
 		}
 	}
 
-	budget.SpentCoins = spentCoins
+	nextTasks, err := h.db.GetNextTasks(database.NextTaskOptions{Limit: 3})
+	if err != nil {
+		log.Printf("Error getting next tasks: %v", err)
+		http.Error(w, "Failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+
+	plan, err := h.db.GetPlan(today)
+	if err != nil {
+		log.Printf("Error getting plan: %v", err)
+		http.Error(w, "Failed to load plan", http.StatusInternalServerError)
+		return
+	}
 
 	data := struct {
 		Tasks       []models.Task
 		TodayTasks  []models.Task
+		NextTasks   NextTasksView
+		Plan        *models.DayPlan
 		Budget      *models.DailyBudget
 		CurrentTime string
 	}{
 		Tasks:       tasks,
 		TodayTasks:  todayTasks,
+		NextTasks:   NextTasksView{Tasks: nextTasks},
+		Plan:        plan,
 		Budget:      budget,
 		CurrentTime: today.Format("15:04"),
 	}
@@ -171,26 +147,337 @@ func (h *Handlers) Dashboard(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// GetTaskList returns the task list as HTML fragment for HTMX
+// taskListPageSize is how many tasks the HTML list shows before "Load more"
+const taskListPageSize = 50
+
+// TaskListView is the data for the task_list.html fragment
+type TaskListView struct {
+	Tasks    []models.Task
+	Chips    [][]FilterChip // Groups of filter chips: status, type, energy and tags
+	Filtered bool           // Whether any filter is set
+	NextURL  string         // URL of the next page, empty on the last one
+	Query    string         // The filters of the list, to keep after bulk actions
+	Notice   string         // Outcome of the last bulk action
+}
+
+// FilterChip is a toggle narrowing down the task list
+type FilterChip struct {
+	Label  string
+	URL    string // The list with this chip toggled
+	Active bool
+}
+
+// GetTaskList returns the task list as HTML fragment for HTMX, narrowed down
+// by the same query parameters as /api/tasks. Requests for a next page get
+// only the tasks to append.
 func (h *Handlers) GetTaskList(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.db.GetAllTasks()
+	h.renderTaskList(w, r, "")
+}
+
+// renderTaskList renders the task list for the filters in the URL of r
+func (h *Handlers) renderTaskList(w http.ResponseWriter, r *http.Request, notice string) {
+	filter, err := parseTaskFilter(r)
 	if err != nil {
-		log.Printf("Error getting tasks: %v", err)
-		http.Error(w, "Failed to load tasks", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if filter.Limit == 0 {
+		filter.Limit = taskListPageSize
+	}
+
+	tasks, next, err := h.db.ListTasks(filter)
+	if err != nil {
+		writeError(w, err, "Failed to load tasks")
+		return
+	}
+
+	tags, err := h.db.ListTags()
+	if err != nil {
+		writeError(w, err, "Failed to load tags")
+		return
+	}
+
+	query := r.URL.Query()
+	data := TaskListView{Tasks: tasks, Chips: taskFilterChips(query, tags), Notice: notice}
+	filters := cloneQuery(query)
+	filters.Del("cursor")
+	data.Query = filters.Encode()
+	for _, chips := range data.Chips {
+		for _, chip := range chips {
+			data.Filtered = data.Filtered || chip.Active
+		}
+	}
+	if next != "" {
+		page := cloneQuery(query)
+		page.Set("cursor", next)
+		data.NextURL = "/tasks?" + page.Encode()
+	}
 
-	if err := h.templates.ExecuteTemplate(w, "task_list.html", tasks); err != nil {
+	name := "task_list.html"
+	if filter.Cursor != "" {
+		name = "task_list_items"
+	}
+	if err := h.templates.ExecuteTemplate(w, name, data); err != nil {
 		log.Printf("Error executing template: %v", err)
 		http.Error(w, "Failed to render task list", http.StatusInternalServerError)
 	}
 }
 
+// BulkTaskList applies a bulk action to the tasks selected in the task
+// list, "done" marking them done and "tomorrow" pushing their deadline to
+// tomorrow at the same time, or its end when they had none. Returns the list
+// again with a notice of what happened.
+func (h *Handlers) BulkTaskList(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	var ids []int
+	for _, value := range r.PostForm["ids"] {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid task ID", http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		h.renderTaskList(w, r, "Select some tasks first")
+		return
+	}
+
+	action := r.PostForm.Get("action")
+	var (
+		req  models.BulkRequest
+		verb string
+	)
+	switch action {
+	case "done":
+		verb = "Marked %d done"
+		for _, id := range ids {
+			req.Operations = append(req.Operations, models.BulkOperation{
+				Action: models.BulkSetStatus, TaskID: id, Status: models.StatusDone,
+			})
+		}
+	case "tomorrow":
+		verb = "Pushed %d to tomorrow"
+		tomorrow := time.Now().AddDate(0, 0, 1)
+		for _, id := range ids {
+			task, err := h.db.GetTask(id)
+			if err != nil {
+				writeError(w, err, "Failed to load task")
+				return
+			}
+			hour, min := 23, 59
+			if task.Deadline != nil {
+				hour, min = task.Deadline.Hour(), task.Deadline.Minute()
+			}
+			deadline := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), hour, min, 0, 0, time.Local)
+			req.Operations = append(req.Operations, models.BulkOperation{
+				Action: models.BulkUpdate, TaskID: id, Update: &models.UpdateTaskRequest{Deadline: &deadline},
+			})
+		}
+	default:
+		http.Error(w, "Unknown bulk action", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.RunBulk(&req)
+	if err != nil {
+		writeError(w, err, "Failed to update tasks")
+		return
+	}
+
+	notice := fmt.Sprintf(verb, result.Succeeded)
+	if result.Failed > 0 {
+		for _, item := range result.Results {
+			if !item.OK {
+				notice += fmt.Sprintf(", %d failed: %s", result.Failed, item.Error)
+				break
+			}
+		}
+	}
+	h.renderTaskList(w, r, notice)
+}
+
+// taskFilterChips builds the status, type, energy and tag chips for a task
+// list query, each linking to the list with that value toggled
+func taskFilterChips(query url.Values, tags []models.Tag) [][]FilterChip {
+	toggle := func(param, value, label string, multiple bool) FilterChip {
+		values := splitList(query[param])
+		chip := FilterChip{Label: label}
+		var rest []string
+		for _, v := range values {
+			if strings.EqualFold(v, value) {
+				chip.Active = true
+			} else {
+				rest = append(rest, v)
+			}
+		}
+		switch {
+		case chip.Active:
+		case multiple:
+			rest = append(rest, value)
+		default:
+			rest = []string{value}
+		}
+
+		q := cloneQuery(query)
+		q.Del("cursor")
+		q.Del(param)
+		if len(rest) > 0 {
+			q.Set(param, strings.Join(rest, ","))
+		}
+		chip.URL = "/tasks?" + q.Encode()
+		return chip
+	}
+
+	var statuses, types, energies, tagChips []FilterChip
+	for _, status := range []models.TaskStatus{models.StatusPending, models.StatusInProgress, models.StatusBlocked, models.StatusDone} {
+		label := format.StatusIcon(status) + " " + strings.ReplaceAll(string(status), "_", " ")
+		statuses = append(statuses, toggle("status", string(status), label, true))
+	}
+	for _, taskType := range []models.TaskType{models.TypeTask, models.TypeAppointment, models.TypeEvent, models.TypeConcert, models.TypeMeeting} {
+		types = append(types, toggle("type", string(taskType), format.TaskTypeText(taskType), true))
+	}
+	for energy := 1; energy <= 3; energy++ {
+		energies = append(energies, toggle("energy", strconv.Itoa(energy), format.EnergyText(energy), false))
+	}
+	for _, tag := range tags {
+		if tag.TaskCount == 0 {
+			continue
+		}
+		label := tag.Name
+		if tag.Emoji != "" {
+			label = tag.Emoji + " " + label
+		}
+		tagChips = append(tagChips, toggle("tags", tag.Name, label, true))
+	}
+	return [][]FilterChip{statuses, types, energies, tagChips}
+}
+
+// cloneQuery copies query values so they can be changed
+func cloneQuery(query url.Values) url.Values {
+	clone := make(url.Values, len(query))
+	for key, values := range query {
+		clone[key] = append([]string(nil), values...)
+	}
+	return clone
+}
+
+// parseTaskFilter reads the task filter, sort and page from the query:
+// status, type and tags (comma-separated, tag_match=all to require every
+// tag), min_priority, max_priority, energy, deadline_before and
+// deadline_after (dates, both inclusive), parent (an ID or "none"),
+// has_prerequisites, sort, order (asc or desc), limit and cursor
+func parseTaskFilter(r *http.Request) (database.TaskFilter, error) {
+	var filter database.TaskFilter
+	query := r.URL.Query()
+
+	for _, status := range splitList(query["status"]) {
+		filter.Statuses = append(filter.Statuses, models.TaskStatus(status))
+	}
+	for _, taskType := range splitList(query["type"]) {
+		filter.TaskTypes = append(filter.TaskTypes, models.TaskType(taskType))
+	}
+	filter.Tags = splitList(query["tags"])
+	switch match := query.Get("tag_match"); match {
+	case "", "any":
+	case "all":
+		filter.AllTags = true
+	default:
+		return filter, fmt.Errorf("invalid tag_match: %q", match)
+	}
+
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{
+		{"min_priority", &filter.MinPriority},
+		{"max_priority", &filter.MaxPriority},
+		{"energy", &filter.EnergyLevel},
+		{"limit", &filter.Limit},
+	} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("invalid %s: %q", param.name, raw)
+		}
+		*param.value = n
+	}
+
+	// Deadlines up to and including the before date
+	if raw := query.Get("deadline_before"); raw != "" {
+		date, err := format.ParseDate(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid deadline_before: %w", err)
+		}
+		before := date.AddDate(0, 0, 1)
+		filter.DeadlineBefore = &before
+	}
+	if raw := query.Get("deadline_after"); raw != "" {
+		date, err := format.ParseDate(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid deadline_after: %w", err)
+		}
+		filter.DeadlineAfter = &date
+	}
+
+	switch raw := query.Get("parent"); raw {
+	case "":
+	case "none":
+		filter.TopLevel = true
+	default:
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid parent: %q", raw)
+		}
+		filter.ParentID = &id
+	}
+
+	if raw := query.Get("has_prerequisites"); raw != "" {
+		has, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid has_prerequisites: %q", raw)
+		}
+		filter.HasPrerequisites = &has
+	}
+
+	filter.Sort = database.TaskSort(query.Get("sort"))
+	switch order := query.Get("order"); order {
+	case "":
+	case "asc", "desc":
+		desc := order == "desc"
+		filter.Descending = &desc
+	default:
+		return filter, fmt.Errorf("invalid order: %q", order)
+	}
+	filter.Cursor = query.Get("cursor")
+
+	return filter, nil
+}
+
 // CreateTask handles task creation
 func (h *Handlers) CreateTask(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		// Return the create task form
-		if err := h.templates.ExecuteTemplate(w, "create_task_form.html", nil); err != nil {
+		calibration, err := h.db.GetCalibration()
+		if err != nil {
+			log.Printf("Error getting calibration: %v", err)
+			http.Error(w, "Failed to render form", http.StatusInternalServerError)
+			return
+		}
+
+		// Return the create task form with what we learned about our estimates
+		data := struct {
+			Insights []models.EstimateFactor
+		}{
+			Insights: calibration.Insights(),
+		}
+		if err := h.templates.ExecuteTemplate(w, "create_task_form.html", data); err != nil {
 			log.Printf("Error executing template: %v", err)
 			http.Error(w, "Failed to render form", http.StatusInternalServerError)
 		}
@@ -230,12 +517,21 @@ func (h *Handlers) CreateTask(w http.ResponseWriter, r *http.Request) {
 			Priority:              priority,
 			EnergyLevel:           energy,
 			Difficulty:            difficulty,
+			ApplyCalibration:      r.FormValue("apply_calibration") != "",
+			Tags:                  splitList([]string{r.FormValue("tags")}),
+		}
+		if repeat := r.FormValue("repeat"); repeat != "" {
+			rule, err := models.ParseRecurrence(repeat)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.Recurrence = rule
 		}
 
 		task, err := h.db.CreateTask(req)
 		if err != nil {
-			log.Printf("Error creating task: %v", err)
-			http.Error(w, "Failed to create task", http.StatusInternalServerError)
+			writeError(w, err, "Failed to create task")
 			return
 		}
 
@@ -264,8 +560,7 @@ func (h *Handlers) UpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
 
 	taskStatus := models.TaskStatus(status)
 	if err := h.db.UpdateTaskStatus(taskID, taskStatus); err != nil {
-		log.Printf("Error updating task status: %v", err)
-		http.Error(w, "Failed to update task", http.StatusInternalServerError)
+		writeError(w, err, "Failed to update task")
 		return
 	}
 
@@ -283,6 +578,64 @@ func (h *Handlers) UpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// NextTasksView is the data for the next_tasks.html fragment
+type NextTasksView struct {
+	Tasks   []models.Task
+	Energy  int
+	Minutes int
+}
+
+// GetNextTasks returns the "what can I do next" suggestions as HTML fragment
+func (h *Handlers) GetNextTasks(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseNextTaskOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.Limit == 0 {
+		opts.Limit = 3
+	}
+
+	tasks, err := h.db.GetNextTasks(opts)
+	if err != nil {
+		writeError(w, err, "Failed to load next tasks")
+		return
+	}
+
+	data := NextTasksView{Tasks: tasks, Energy: opts.Energy, Minutes: opts.FreeMinutes}
+	if err := h.templates.ExecuteTemplate(w, "next_tasks.html", data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Failed to render next tasks", http.StatusInternalServerError)
+	}
+}
+
+// parseNextTaskOptions reads the energy, minutes and limit query parameters
+func parseNextTaskOptions(r *http.Request) (database.NextTaskOptions, error) {
+	var opts database.NextTaskOptions
+	query := r.URL.Query()
+
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{
+		{"energy", &opts.Energy},
+		{"minutes", &opts.FreeMinutes},
+		{"limit", &opts.Limit},
+	} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid %s: %q", param.name, raw)
+		}
+		*param.value = n
+	}
+
+	return opts, nil
+}
+
 // GetBudgetWidget returns the budget widget as HTML fragment
 func (h *Handlers) GetBudgetWidget(w http.ResponseWriter, r *http.Request) {
 	today := time.Now()
@@ -293,43 +646,107 @@ func (h *Handlers) GetBudgetWidget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Calculate current spent amount from pending/in-progress tasks
-	tasks, err := h.db.GetAllTasks()
+	if err := h.templates.ExecuteTemplate(w, "budget_widget.html", budget); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Failed to render budget widget", http.StatusInternalServerError)
+	}
+}
+
+// GetPlanView returns the day plan timeline as HTML fragment
+func (h *Handlers) GetPlanView(w http.ResponseWriter, r *http.Request) {
+	date, err := format.ParseDate(mux.Vars(r)["date"])
 	if err != nil {
-		log.Printf("Error getting tasks: %v", err)
-		http.Error(w, "Failed to load tasks", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	spentCoins := 0
-	for _, task := range tasks {
-		if task.Status == models.StatusPending || task.Status == models.StatusInProgress {
-			spentCoins += task.MoneyCost
-		}
+	plan, err := h.db.GetPlan(date)
+	if err != nil {
+		writeError(w, err, "Failed to load plan")
+		return
 	}
-	budget.SpentCoins = spentCoins
 
-	if err := h.templates.ExecuteTemplate(w, "budget_widget.html", budget); err != nil {
+	if err := h.templates.ExecuteTemplate(w, "plan_timeline.html", plan); err != nil {
 		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Failed to render budget widget", http.StatusInternalServerError)
+		http.Error(w, "Failed to render plan", http.StatusInternalServerError)
+	}
+}
+
+// GeneratePlanView plans the day and returns the new timeline as HTML fragment
+func (h *Handlers) GeneratePlanView(w http.ResponseWriter, r *http.Request) {
+	date, err := format.ParseDate(mux.Vars(r)["date"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := database.PlanOptions{
+		DayStart: r.FormValue("day_start"),
+		DayEnd:   r.FormValue("day_end"),
+	}
+	plan, err := h.db.GeneratePlan(date, opts)
+	if err != nil {
+		writeError(w, err, "Failed to generate plan")
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "plan_timeline.html", plan); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Failed to render plan", http.StatusInternalServerError)
 	}
 }
 
 // API endpoints for JSON responses
 
-// GetTasksAPI returns tasks as JSON
+// GetTasksAPI returns tasks as JSON, filtered, sorted and paged by the query
+// parameters read by parseTaskFilter. When there are more tasks than the
+// limit, the X-Next-Cursor header holds the cursor of the next page.
 func (h *Handlers) GetTasksAPI(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.db.GetAllTasks()
+	filter, err := parseTaskFilter(r)
 	if err != nil {
-		log.Printf("Error getting tasks: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tasks, next, err := h.db.ListTasks(filter)
+	if err != nil {
+		writeError(w, err, "Failed to load tasks")
+		return
+	}
+	if tasks == nil {
+		tasks = []models.Task{}
+	}
+
+	if err := h.db.AttachCalibration(tasks); err != nil {
+		log.Printf("Error getting calibration: %v", err)
 		http.Error(w, "Failed to load tasks", http.StatusInternalServerError)
 		return
 	}
 
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(tasks)
 }
 
+// GetNextTasksAPI returns the ranked actionable tasks as JSON
+func (h *Handlers) GetNextTasksAPI(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseNextTaskOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.db.GetNextTasks(opts)
+	if err != nil {
+		writeError(w, err, "Failed to load next tasks")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tasks)
+}
+
 // CreateTaskAPI creates a task via JSON API
 func (h *Handlers) CreateTaskAPI(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateTaskRequest
@@ -340,8 +757,7 @@ func (h *Handlers) CreateTaskAPI(w http.ResponseWriter, r *http.Request) {
 
 	task, err := h.db.CreateTask(&req)
 	if err != nil {
-		log.Printf("Error creating task: %v", err)
-		http.Error(w, "Failed to create task", http.StatusInternalServerError)
+		writeError(w, err, "Failed to create task")
 		return
 	}
 
@@ -350,174 +766,1825 @@ func (h *Handlers) CreateTaskAPI(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(task)
 }
 
-// GetTaskRadar returns the radar visualization for tasks
-func (h *Handlers) GetTaskRadar(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.db.GetAllTasks()
+// GetTaskAPI returns a single task as JSON
+func (h *Handlers) GetTaskAPI(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		log.Printf("Error getting tasks for radar: %v", err)
-		http.Error(w, "Failed to load tasks", http.StatusInternalServerError)
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
 		return
 	}
 
-	// Calculate radar positions for all tasks
-	for i := range tasks {
-		tasks[i].CalculateRadarPosition()
+	task, err := h.db.GetTask(taskID)
+	if err != nil {
+		writeError(w, err, "Failed to get task")
+		return
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "task_radar.html", tasks); err != nil {
-		log.Printf("Error executing radar template: %v", err)
-		http.Error(w, "Failed to render radar", http.StatusInternalServerError)
+	tasks := []models.Task{*task}
+	if err := h.db.AttachCalibration(tasks); err != nil {
+		writeError(w, err, "Failed to get task")
+		return
 	}
+
+	writeJSON(w, http.StatusOK, tasks[0])
 }
 
-// GetTaskDetails returns detailed task information
-func (h *Handlers) GetTaskDetails(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	taskID, err := strconv.Atoi(vars["id"])
+// GetTaskTreeAPI returns a task with its full subtask tree and rollups as JSON
+func (h *Handlers) GetTaskTreeAPI(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		http.Error(w, "Invalid task ID", http.StatusBadRequest)
 		return
 	}
 
-	task, err := h.db.GetTask(taskID)
+	task, err := h.db.GetTaskTree(taskID)
 	if err != nil {
-		log.Printf("Error getting task details: %v", err)
-		http.Error(w, "Failed to get task", http.StatusInternalServerError)
+		writeError(w, err, "Failed to get task tree")
 		return
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "task_details.html", task); err != nil {
-		log.Printf("Error executing task details template: %v", err)
-		http.Error(w, "Failed to render task details", http.StatusInternalServerError)
-	}
+	writeJSON(w, http.StatusOK, task)
 }
 
-// GetContacts returns all contacts
-func (h *Handlers) GetContacts(w http.ResponseWriter, r *http.Request) {
-	contacts, err := h.db.GetAllContacts()
+// BulkTasksAPI applies a list of task operations in one transaction and
+// reports the outcome of each. An atomic request that had a failure is
+// rolled back as a whole and answered with 422.
+func (h *Handlers) BulkTasksAPI(w http.ResponseWriter, r *http.Request) {
+	var req models.BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.RunBulk(&req)
 	if err != nil {
-		log.Printf("Error getting contacts: %v", err)
-		http.Error(w, "Failed to load contacts", http.StatusInternalServerError)
+		writeError(w, err, "Failed to apply bulk operations")
 		return
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "contact_threads.html", contacts); err != nil {
-		log.Printf("Error executing contacts template: %v", err)
-		http.Error(w, "Failed to render contacts", http.StatusInternalServerError)
+	status := http.StatusOK
+	if result.RolledBack {
+		status = http.StatusUnprocessableEntity
 	}
+	writeJSON(w, status, result)
 }
 
-// CreateContact handles contact creation (placeholder)
-func (h *Handlers) CreateContact(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "GET" {
-		// Return contact creation form (to be implemented)
-		w.Write([]byte(`<div class="modal-content">
-			<div class="modal-header">
-				<h2>Add Contact</h2>
-				<button class="modal-close" onclick="document.getElementById('contact-modal').innerHTML = ''">×</button>
-			</div>
-			<form hx-post="/contacts/create" hx-target="#contact-modal" hx-swap="innerHTML">
-				<div class="form-group">
-					<label>Name:</label>
-					<input type="text" name="name" required>
-				</div>
-				<div class="form-group">
-					<label>Email:</label>
-					<input type="email" name="email">
-				</div>
-				<div class="form-group">
-					<label>Phone:</label>
-					<input type="tel" name="phone">
-				</div>
-				<div class="form-group">
-					<label>Type:</label>
-					<select name="type">
-						<option value="person">Person</option>
-						<option value="organization">Organization</option>
-						<option value="venue">Venue</option>
-					</select>
-				</div>
-				<div class="form-group">
-					<label>Notes:</label>
-					<textarea name="notes"></textarea>
-				</div>
-				<div class="form-actions">
-					<button type="submit" class="btn btn-primary">Create Contact</button>
-					<button type="button" class="btn btn-secondary" onclick="document.getElementById('contact-modal').innerHTML = ''">Cancel</button>
-				</div>
-			</form>
-		</div>`))
-		return
-	}
-
-	// Handle POST - placeholder for now
-	w.Write([]byte("Contact created successfully"))
-}
-
-// GetContactThreads returns communication threads for a contact (placeholder)
-func (h *Handlers) GetContactThreads(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	contactID, err := strconv.Atoi(vars["id"])
+// UpdateTaskAPI updates a task via JSON API. PUT replaces all editable
+// fields while PATCH only changes the fields present in the body. A PATCH
+// with ?scope=series also changes the rest of a recurring task's series.
+func (h *Handlers) UpdateTaskAPI(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
 		return
 	}
 
-	threads, err := h.db.GetContactThreads(contactID)
-	if err != nil {
-		log.Printf("Error getting contact threads: %v", err)
-		http.Error(w, "Failed to load threads", http.StatusInternalServerError)
+	scope := r.URL.Query().Get("scope")
+	if scope != "" && scope != "instance" && scope != "series" {
+		http.Error(w, "Invalid scope, expected instance or series", http.StatusBadRequest)
 		return
 	}
 
-	// Simplified thread display for now
-	w.Write([]byte(fmt.Sprintf(`<div class="thread-section">
-		<h3>Communication Threads</h3>
-		<p>Found %d threads for contact ID %d</p>
-		<div class="btn-group">
-			<button class="btn btn-primary">Add Message</button>
-			<button class="btn btn-secondary">Add Call Log</button>
-		</div>
-	</div>`, len(threads), contactID)))
-}
-
-// CreateMessage handles creating new messages (placeholder)
-func (h *Handlers) CreateMessage(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	contactID := vars["id"]
-
-	if r.Method == "GET" {
-		w.Write([]byte(fmt.Sprintf(`<div class="modal-content">
-			<div class="modal-header">
-				<h2>Send Message</h2>
-				<button class="modal-close" onclick="document.getElementById('message-modal').innerHTML = ''">×</button>
-			</div>
-			<form hx-post="/contacts/%s/message" hx-target="#message-modal" hx-swap="innerHTML">
-				<div class="form-group">
-					<label>Subject:</label>
-					<input type="text" name="subject">
-				</div>
-				<div class="form-group">
-					<label>Message:</label>
-					<textarea name="message" rows="4" required></textarea>
-				</div>
-				<div class="form-group">
-					<label>Type:</label>
-					<select name="type">
-						<option value="message">Message</option>
-						<option value="email">Email</option>
-						<option value="call">Call Log</option>
-					</select>
-				</div>
-				<div class="form-actions">
-					<button type="submit" class="btn btn-primary">Send</button>
-					<button type="button" class="btn btn-secondary" onclick="document.getElementById('message-modal').innerHTML = ''">Cancel</button>
-				</div>
-			</form>
-		</div>`, contactID)))
-		return
-	}
-
-	// Handle POST - placeholder for now
-	w.Write([]byte("Message sent successfully"))
-}
\ No newline at end of file
+	var task *models.Task
+	if r.Method == "PUT" {
+		if scope == "series" {
+			http.Error(w, "Series updates must use PATCH", http.StatusBadRequest)
+			return
+		}
+		var req models.CreateTaskRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		task, err = h.db.ReplaceTask(taskID, &req)
+	} else {
+		var req models.UpdateTaskRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if scope == "series" {
+			task, err = h.db.UpdateSeries(taskID, &req)
+		} else {
+			task, err = h.db.UpdateTask(taskID, &req)
+		}
+	}
+	if err != nil {
+		writeError(w, err, "Failed to update task")
+		return
+	}
+
+	tasks := []models.Task{*task}
+	if err := h.db.AttachCalibration(tasks); err != nil {
+		writeError(w, err, "Failed to update task")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tasks[0])
+}
+
+// GetTagsAPI returns every tag with its task counts as JSON
+func (h *Handlers) GetTagsAPI(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.db.ListTags()
+	if err != nil {
+		writeError(w, err, "Failed to load tags")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tags)
+}
+
+// CreateTagAPI adds a tag from a JSON name, color and emoji
+func (h *Handlers) CreateTagAPI(w http.ResponseWriter, r *http.Request) {
+	var req models.TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.db.CreateTag(&req)
+	if err != nil {
+		writeError(w, err, "Failed to create tag")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, tag)
+}
+
+// GetTagAPI returns a single tag as JSON
+func (h *Handlers) GetTagAPI(w http.ResponseWriter, r *http.Request) {
+	tagID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.db.GetTag(tagID)
+	if err != nil {
+		writeError(w, err, "Failed to load tag")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tag)
+}
+
+// UpdateTagAPI changes the fields sent; a new name renames the tag on all tasks
+func (h *Handlers) UpdateTagAPI(w http.ResponseWriter, r *http.Request) {
+	tagID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.db.UpdateTag(tagID, &req)
+	if err != nil {
+		writeError(w, err, "Failed to update tag")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tag)
+}
+
+// DeleteTagAPI removes a tag from every task and deletes it
+func (h *Handlers) DeleteTagAPI(w http.ResponseWriter, r *http.Request) {
+	tagID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteTag(tagID); err != nil {
+		writeError(w, err, "Failed to delete tag")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MergeTagAPI moves the tasks of a tag to the one in the JSON "into" field
+// and deletes it, returning the tag that is kept
+func (h *Handlers) MergeTagAPI(w http.ResponseWriter, r *http.Request) {
+	tagID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.TagMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Into == 0 {
+		http.Error(w, `Invalid JSON, expected {"into": <tag ID>}`, http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.db.MergeTags(tagID, req.Into)
+	if err != nil {
+		writeError(w, err, "Failed to merge tags")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tag)
+}
+
+// SearchView is the data for the search_results.html fragment
+type SearchView struct {
+	Query   string
+	Results *models.SearchResults
+	Error   string
+}
+
+// Search returns the results of the dashboard search box as HTML fragment
+func (h *Handlers) Search(w http.ResponseWriter, r *http.Request) {
+	data := SearchView{Query: strings.TrimSpace(r.URL.Query().Get("q"))}
+	if data.Query != "" {
+		results, err := h.db.Search(data.Query, 0)
+		switch {
+		case errors.Is(err, database.ErrInvalid), errors.Is(err, database.ErrUnavailable):
+			data.Error = err.Error()
+		case err != nil:
+			log.Printf("Error searching: %v", err)
+			http.Error(w, "Failed to search", http.StatusInternalServerError)
+			return
+		default:
+			data.Results = results
+		}
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "search_results.html", data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Failed to render search results", http.StatusInternalServerError)
+	}
+}
+
+// SearchAPI searches tasks, contacts and threads for ?q= and returns the hits
+// grouped by type as JSON
+func (h *Handlers) SearchAPI(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			http.Error(w, fmt.Sprintf("invalid limit: %q", raw), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	results, err := h.db.Search(r.URL.Query().Get("q"), limit)
+	if err != nil {
+		writeError(w, err, "Failed to search")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// GetSeriesAPI returns the recurring series of a task with its streak as JSON
+func (h *Handlers) GetSeriesAPI(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	series, err := h.db.GetSeries(taskID)
+	if err != nil {
+		writeError(w, err, "Failed to load series")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, series)
+}
+
+// SetRecurrenceAPI sets the recurrence rule of a task's series with PUT, or
+// ends the series with DELETE
+func (h *Handlers) SetRecurrenceAPI(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	var rule *models.Recurrence
+	if r.Method == "PUT" {
+		rule = &models.Recurrence{}
+		if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	task, err := h.db.SetRecurrence(taskID, rule)
+	if err != nil {
+		writeError(w, err, "Failed to update recurrence")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, task)
+}
+
+// DeleteTaskAPI deletes a task; ?cascade=true also deletes its subtasks
+func (h *Handlers) DeleteTaskAPI(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	cascade, _ := strconv.ParseBool(r.URL.Query().Get("cascade"))
+	if err := h.db.DeleteTask(taskID, cascade); err != nil {
+		writeError(w, err, "Failed to delete task")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetStatusHistoryAPI returns the status changes of a task as JSON
+func (h *Handlers) GetStatusHistoryAPI(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.db.GetStatusHistory(taskID)
+	if err != nil {
+		writeError(w, err, "Failed to load status history")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+// GetTimeEntriesAPI returns the tracked time of a task as JSON
+func (h *Handlers) GetTimeEntriesAPI(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.db.GetTimeEntries(taskID)
+	if err != nil {
+		writeError(w, err, "Failed to load time entries")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// AddPrerequisiteAPI makes one task a prerequisite of another
+func (h *Handlers) AddPrerequisiteAPI(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+	prereqID, err := strconv.Atoi(vars["prereqId"])
+	if err != nil {
+		http.Error(w, "Invalid prerequisite ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.AddPrerequisite(taskID, prereqID); err != nil {
+		writeError(w, err, "Failed to add prerequisite")
+		return
+	}
+
+	task, err := h.db.GetTask(taskID)
+	if err != nil {
+		writeError(w, err, "Failed to get task")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, task)
+}
+
+// RemovePrerequisiteAPI removes a prerequisite edge between two tasks
+func (h *Handlers) RemovePrerequisiteAPI(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+	prereqID, err := strconv.Atoi(vars["prereqId"])
+	if err != nil {
+		http.Error(w, "Invalid prerequisite ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.RemovePrerequisite(taskID, prereqID); err != nil {
+		writeError(w, err, "Failed to remove prerequisite")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError maps database errors to HTTP status codes, logging unexpected ones
+func writeError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, database.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, database.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, database.ErrUnavailable):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, storage.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, storage.ErrEmpty):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("%s: %v", msg, err)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+// GetContactsAPI returns all contacts as JSON, by name
+func (h *Handlers) GetContactsAPI(w http.ResponseWriter, r *http.Request) {
+	contacts, err := h.db.GetAllContacts()
+	if err != nil {
+		writeError(w, err, "Failed to load contacts")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, contacts)
+}
+
+// CreateContactAPI adds a contact from JSON
+func (h *Handlers) CreateContactAPI(w http.ResponseWriter, r *http.Request) {
+	var req models.ContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	contact, err := h.db.CreateContact(&req)
+	if err != nil {
+		writeError(w, err, "Failed to create contact")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, contact)
+}
+
+// GetContactAPI returns a single contact as JSON
+func (h *Handlers) GetContactAPI(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	contact, err := h.db.GetContact(contactID)
+	if err != nil {
+		writeError(w, err, "Failed to load contact")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, contact)
+}
+
+// UpdateContactAPI changes the fields of a contact that were sent
+func (h *Handlers) UpdateContactAPI(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	contact, err := h.db.UpdateContact(contactID, &req)
+	if err != nil {
+		writeError(w, err, "Failed to update contact")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, contact)
+}
+
+// DeleteContactAPI deletes a contact with its threads and task links
+func (h *Handlers) DeleteContactAPI(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteContact(contactID); err != nil {
+		writeError(w, err, "Failed to delete contact")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetContactThreadsAPI returns the threads with a contact as JSON, oldest
+// first, narrowed down by ?type= and grouped by subject with ?group=subject
+func (h *Handlers) GetContactThreadsAPI(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	threads, err := h.db.GetContactThreads(contactID, models.ThreadType(query.Get("type")))
+	if err != nil {
+		writeError(w, err, "Failed to load threads")
+		return
+	}
+
+	switch query.Get("group") {
+	case "":
+		writeJSON(w, http.StatusOK, threads)
+	case "subject":
+		groups := models.GroupThreads(threads)
+		if groups == nil {
+			groups = []models.ThreadGroup{}
+		}
+		writeJSON(w, http.StatusOK, groups)
+	default:
+		http.Error(w, "Invalid group, expected subject", http.StatusBadRequest)
+	}
+}
+
+// CreateContactThreadAPI records a message, email, call or meeting with a
+// contact from JSON
+func (h *Handlers) CreateContactThreadAPI(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ThreadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	thread, err := h.db.CreateThread(contactID, &req)
+	if err != nil {
+		writeError(w, err, "Failed to save thread")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, thread)
+}
+
+// UpdateContactThreadAPI changes the status of a thread with a contact, such
+// as marking a pending message sent
+func (h *Handlers) UpdateContactThreadAPI(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	contactID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+	threadID, err := strconv.Atoi(vars["threadId"])
+	if err != nil {
+		http.Error(w, "Invalid thread ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ThreadUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	thread, err := h.db.UpdateThread(contactID, threadID, &req)
+	if err != nil {
+		writeError(w, err, "Failed to update thread")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, thread)
+}
+
+// GetTaskContactsAPI returns the contacts of a task with their roles
+func (h *Handlers) GetTaskContactsAPI(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.db.GetTask(taskID)
+	if err != nil {
+		writeError(w, err, "Failed to load task")
+		return
+	}
+
+	contacts := task.Contacts
+	if contacts == nil {
+		contacts = []models.Contact{}
+	}
+	writeJSON(w, http.StatusOK, contacts)
+}
+
+// LinkContactAPI links a contact to a task in the role of the optional JSON
+// body, participant by default, returning the contacts of the task
+func (h *Handlers) LinkContactAPI(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+	contactID, err := strconv.Atoi(vars["contactId"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ContactLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.LinkContact(taskID, contactID, req.Role); err != nil {
+		writeError(w, err, "Failed to link contact")
+		return
+	}
+
+	h.GetTaskContactsAPI(w, r)
+}
+
+// UnlinkContactAPI removes a contact from a task
+func (h *Handlers) UnlinkContactAPI(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+	contactID, err := strconv.Atoi(vars["contactId"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.UnlinkContact(taskID, contactID); err != nil {
+		writeError(w, err, "Failed to unlink contact")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetContactTasksAPI returns the tasks a contact is linked to with their role
+func (h *Handlers) GetContactTasksAPI(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.db.GetContactTasks(contactID)
+	if err != nil {
+		writeError(w, err, "Failed to load tasks")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+// GetWaitingOnAPI returns the contacts that owe a reply as JSON
+func (h *Handlers) GetWaitingOnAPI(w http.ResponseWriter, r *http.Request) {
+	waiting, err := h.db.GetWaitingOn(time.Now())
+	if err != nil {
+		writeError(w, err, "Failed to load waiting list")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, waiting)
+}
+
+// GenerateFollowUpsAPI applies the follow-up rules now and returns the tasks
+// created as JSON
+func (h *Handlers) GenerateFollowUpsAPI(w http.ResponseWriter, r *http.Request) {
+	tasks, err := h.db.GenerateFollowUps(time.Now())
+	if err != nil {
+		writeError(w, err, "Failed to generate follow-ups")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+// GetTaskAttachmentsAPI returns the attachments of a task as JSON
+func (h *Handlers) GetTaskAttachmentsAPI(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	attachments, err := h.db.GetTaskAttachments(taskID)
+	if err != nil {
+		writeError(w, err, "Failed to load attachments")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, attachments)
+}
+
+// UploadTaskAttachmentAPI attaches the file of a multipart upload to a task
+func (h *Handlers) UploadTaskAttachmentAPI(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.db.GetTask(taskID); err != nil {
+		writeError(w, err, "Failed to load task")
+		return
+	}
+
+	attachment, err := h.receiveAttachment(w, r, models.Attachment{TaskID: &taskID})
+	if err != nil {
+		writeError(w, err, "Failed to upload attachment")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, attachment)
+}
+
+// GetContactAttachmentsAPI returns the attachments of a contact as JSON
+func (h *Handlers) GetContactAttachmentsAPI(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	attachments, err := h.db.GetContactAttachments(contactID)
+	if err != nil {
+		writeError(w, err, "Failed to load attachments")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, attachments)
+}
+
+// UploadContactAttachmentAPI attaches the file of a multipart upload to a contact
+func (h *Handlers) UploadContactAttachmentAPI(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.db.GetContact(contactID); err != nil {
+		writeError(w, err, "Failed to load contact")
+		return
+	}
+
+	attachment, err := h.receiveAttachment(w, r, models.Attachment{ContactID: &contactID})
+	if err != nil {
+		writeError(w, err, "Failed to upload attachment")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, attachment)
+}
+
+// DeleteAttachmentAPI deletes an attachment
+func (h *Handlers) DeleteAttachmentAPI(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.deleteAttachment(attachmentID); err != nil {
+		writeError(w, err, "Failed to delete attachment")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxDescriptionBytes bounds the description sent along with an upload
+const maxDescriptionBytes = 4 << 10
+
+// receiveAttachment stores the file of a multipart upload, sent in the field
+// file with an optional description, and records it as an attachment of the
+// task or contact set on owner
+func (h *Handlers) receiveAttachment(w http.ResponseWriter, r *http.Request, owner models.Attachment) (created *models.Attachment, err error) {
+	// Leave room for the rest of the form around the file
+	r.Body = http.MaxBytesReader(w, r.Body, h.files.MaxSize()+1<<20)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: expected a multipart/form-data upload", database.ErrInvalid)
+	}
+
+	var obj *storage.Object
+	defer func() {
+		if obj != nil && created == nil {
+			h.removeUnusedFile(obj.Key)
+		}
+	}()
+
+	attachment := owner
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, h.uploadError(fmt.Errorf("%w: %w", database.ErrInvalid, err))
+		}
+
+		switch part.FormName() {
+		case "file":
+			if obj != nil {
+				return nil, fmt.Errorf("%w: one file per upload", database.ErrInvalid)
+			}
+			if obj, err = h.files.Put(part, part.FileName()); err != nil {
+				return nil, h.uploadError(err)
+			}
+			attachment.OriginalFilename = part.FileName()
+		case "description":
+			text, err := io.ReadAll(io.LimitReader(part, maxDescriptionBytes))
+			if err != nil {
+				return nil, h.uploadError(fmt.Errorf("%w: %w", database.ErrInvalid, err))
+			}
+			attachment.Description = string(text)
+		}
+		part.Close()
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("%w: no file uploaded", database.ErrInvalid)
+	}
+
+	attachment.Filename = obj.Key
+	attachment.FilePath = obj.Path
+	attachment.FileSize = obj.Size
+	attachment.MimeType = obj.MimeType
+	return h.db.CreateAttachment(&attachment)
+}
+
+// uploadError tells an upload over the size limit from one that could not be read
+func (h *Handlers) uploadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("%w: the limit is %d bytes", storage.ErrTooLarge, h.files.MaxSize())
+	}
+	return err
+}
+
+// deleteAttachment deletes an attachment, and its file unless another
+// attachment has the same content
+func (h *Handlers) deleteAttachment(id int) error {
+	attachment, err := h.db.DeleteAttachment(id)
+	if err != nil {
+		return err
+	}
+	h.removeUnusedFile(attachment.Filename)
+	return nil
+}
+
+// removeUnusedFile removes a stored file no attachment has, unless an upload
+// touched it within the last hour. Files left, or failing to be removed,
+// are pruned later.
+func (h *Handlers) removeUnusedFile(key string) {
+	inUse, err := h.db.AttachmentFileInUse(key)
+	if err == nil && !inUse {
+		err = h.files.Remove(key)
+	}
+	if err != nil {
+		log.Printf("Error removing attachment file: %v", err)
+	}
+}
+
+// GetCalibrationAPI returns the learned estimate factors as JSON
+func (h *Handlers) GetCalibrationAPI(w http.ResponseWriter, r *http.Request) {
+	calibration, err := h.db.GetCalibration()
+	if err != nil {
+		writeError(w, err, "Failed to load calibration")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, calibration)
+}
+
+// GetPlanAPI returns the stored plan for a date as JSON
+func (h *Handlers) GetPlanAPI(w http.ResponseWriter, r *http.Request) {
+	date, err := format.ParseDate(mux.Vars(r)["date"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := h.db.GetPlan(date)
+	if err != nil {
+		writeError(w, err, "Failed to load plan")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// GeneratePlanAPI plans a date and returns the plan as JSON. The optional
+// body sets the working hours, e.g. {"day_start": "09:00", "day_end": "17:00"}.
+func (h *Handlers) GeneratePlanAPI(w http.ResponseWriter, r *http.Request) {
+	date, err := format.ParseDate(mux.Vars(r)["date"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var opts database.PlanOptions
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	plan, err := h.db.GeneratePlan(date, opts)
+	if err != nil {
+		writeError(w, err, "Failed to generate plan")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, plan)
+}
+
+// GetBudgetAPI returns the budget of a date, with spent coins from the ledger, as JSON
+func (h *Handlers) GetBudgetAPI(w http.ResponseWriter, r *http.Request) {
+	date, err := format.ParseDate(mux.Vars(r)["date"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	budget, err := h.db.GetDailyBudget(date)
+	if err != nil {
+		writeError(w, err, "Failed to load budget")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, budget)
+}
+
+// GetBudgetTransactionsAPI returns the ledger entries of a date as JSON
+func (h *Handlers) GetBudgetTransactionsAPI(w http.ResponseWriter, r *http.Request) {
+	date, err := format.ParseDate(mux.Vars(r)["date"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transactions, err := h.db.GetBudgetTransactions(date)
+	if err != nil {
+		writeError(w, err, "Failed to load budget transactions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, transactions)
+}
+
+// CreateBudgetAdjustmentAPI books a manual adjustment on the ledger of a date
+func (h *Handlers) CreateBudgetAdjustmentAPI(w http.ResponseWriter, r *http.Request) {
+	date, err := format.ParseDate(mux.Vars(r)["date"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req models.BudgetAdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	transaction, err := h.db.AddBudgetAdjustment(date, req.TaskID, req.Amount, req.Note)
+	if err != nil {
+		writeError(w, err, "Failed to add budget adjustment")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, transaction)
+}
+
+// SetBudgetAPI overrides the budget of a single date; a null total goes back to the settings
+func (h *Handlers) SetBudgetAPI(w http.ResponseWriter, r *http.Request) {
+	date, err := format.ParseDate(mux.Vars(r)["date"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req models.BudgetOverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	budget, err := h.db.SetBudgetOverride(date, req.TotalBudgetCoins)
+	if err != nil {
+		writeError(w, err, "Failed to set budget")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, budget)
+}
+
+// SettingsPage renders the settings page and saves the submitted settings
+func (h *Handlers) SettingsPage(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Settings []models.Setting
+		Saved    bool
+		Error    string
+	}{
+		Saved: r.URL.Query().Get("saved") != "",
+	}
+
+	if r.Method == "POST" {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+
+		values := make(map[string]string, len(r.PostForm))
+		for key := range r.PostForm {
+			values[key] = r.PostForm.Get(key)
+		}
+		err := h.db.SetSettings(values)
+		if err == nil {
+			http.Redirect(w, r, "/settings?saved=1", http.StatusSeeOther)
+			return
+		}
+		if !errors.Is(err, database.ErrInvalid) {
+			writeError(w, err, "Failed to save settings")
+			return
+		}
+		data.Error = err.Error()
+	}
+
+	settings, err := h.db.GetSettings()
+	if err != nil {
+		writeError(w, err, "Failed to load settings")
+		return
+	}
+	if r.Method == "POST" {
+		// Show what was typed so it can be corrected
+		for i := range settings {
+			if value, ok := r.PostForm[settings[i].Key]; ok {
+				settings[i].Value = value[0]
+			}
+		}
+	}
+	data.Settings = settings
+
+	if err := h.templates.ExecuteTemplate(w, "settings.html", data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Failed to render settings", http.StatusInternalServerError)
+	}
+}
+
+// GetSettingsAPI returns every setting with its current value as JSON
+func (h *Handlers) GetSettingsAPI(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.db.GetSettings()
+	if err != nil {
+		writeError(w, err, "Failed to load settings")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, settings)
+}
+
+// UpdateSettingsAPI stores the settings of a JSON object of keys to values.
+// Numbers are accepted as well as strings, null clears a setting.
+func (h *Handlers) UpdateSettingsAPI(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	values := make(map[string]string, len(req))
+	for key, value := range req {
+		switch v := value.(type) {
+		case nil:
+			values[key] = ""
+		case string:
+			values[key] = v
+		case float64:
+			values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			http.Error(w, fmt.Sprintf("Setting %q must be a string or number", key), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.db.SetSettings(values); err != nil {
+		writeError(w, err, "Failed to save settings")
+		return
+	}
+
+	h.GetSettingsAPI(w, r)
+}
+
+// CalendarFeed serves events and deadlines as an iCalendar feed for calendar
+// apps to subscribe to. ?type= and ?tags= take comma-separated lists.
+func (h *Handlers) CalendarFeed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var filter database.CalendarFilter
+	for _, taskType := range splitList(query["type"]) {
+		filter.TaskTypes = append(filter.TaskTypes, models.TaskType(taskType))
+	}
+	filter.Tags = splitList(query["tags"])
+
+	tasks, err := h.db.GetCalendarTasks(filter)
+	if err != nil {
+		writeError(w, err, "Failed to load calendar")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="oppgaave.ics"`)
+	if err := ical.Encode(w, ical.FromTasks("ADHD Task Manager", tasks)); err != nil {
+		log.Printf("Error writing calendar: %v", err)
+	}
+}
+
+// ImportCalendarAPI creates and updates tasks from an iCalendar file sent as
+// the request body or as the "file" field of a multipart form. Recurring
+// series are expanded ?days= ahead, 90 by default.
+func (h *Handlers) ImportCalendarAPI(w http.ResponseWriter, r *http.Request) {
+	days := ical.DefaultImportDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCalendarSize)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Missing calendar file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	cal, err := ical.Decode(body)
+	if err != nil {
+		http.Error(w, "Invalid calendar: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, until := ical.ImportWindow(days)
+	entries, err := ical.ToEntries(cal, from, until)
+	if err != nil {
+		http.Error(w, "Invalid calendar: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.ImportCalendar(entries)
+	if err != nil {
+		writeError(w, err, "Failed to import calendar")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// splitList flattens repeated and comma-separated query values, dropping empty ones
+func splitList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// GetTaskRadar returns the radar visualization for tasks
+func (h *Handlers) GetTaskRadar(w http.ResponseWriter, r *http.Request) {
+	tasks, err := h.db.GetAllTasks()
+	if err != nil {
+		log.Printf("Error getting tasks for radar: %v", err)
+		http.Error(w, "Failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+
+	// Calculate radar positions for all tasks
+	for i := range tasks {
+		tasks[i].CalculateRadarPosition()
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "task_radar.html", tasks); err != nil {
+		log.Printf("Error executing radar template: %v", err)
+		http.Error(w, "Failed to render radar", http.StatusInternalServerError)
+	}
+}
+
+// GetTaskDetails returns detailed task information
+func (h *Handlers) GetTaskDetails(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.db.GetTaskTree(taskID)
+	if err != nil {
+		log.Printf("Error getting task details: %v", err)
+		http.Error(w, "Failed to get task", http.StatusInternalServerError)
+		return
+	}
+
+	task.StatusHistory, err = h.db.GetStatusHistory(taskID)
+	if err != nil {
+		log.Printf("Error getting status history: %v", err)
+		http.Error(w, "Failed to get task", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "task_details.html", task); err != nil {
+		log.Printf("Error executing task details template: %v", err)
+		http.Error(w, "Failed to render task details", http.StatusInternalServerError)
+	}
+}
+
+// GetTaskContacts returns the contacts section of the task details, with a
+// picker for linking more
+func (h *Handlers) GetTaskContacts(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	h.renderTaskContacts(w, taskID)
+}
+
+// LinkTaskContact links the contact picked in the task details in the role
+// picked, returning the updated contacts section
+func (h *Handlers) LinkTaskContact(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+	contactID, err := strconv.Atoi(r.FormValue("contact_id"))
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.LinkContact(taskID, contactID, models.ContactRole(r.FormValue("role"))); err != nil {
+		writeError(w, err, "Failed to link contact")
+		return
+	}
+
+	h.renderTaskContacts(w, taskID)
+}
+
+// UnlinkTaskContact removes a contact from a task, returning the updated
+// contacts section
+func (h *Handlers) UnlinkTaskContact(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+	contactID, err := strconv.Atoi(vars["contactId"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.UnlinkContact(taskID, contactID); err != nil {
+		writeError(w, err, "Failed to unlink contact")
+		return
+	}
+
+	h.renderTaskContacts(w, taskID)
+}
+
+// renderTaskContacts renders the contacts of a task with the contact picker
+func (h *Handlers) renderTaskContacts(w http.ResponseWriter, taskID int) {
+	task, err := h.db.GetTask(taskID)
+	if err != nil {
+		writeError(w, err, "Failed to load task")
+		return
+	}
+	contacts, err := h.db.GetAllContacts()
+	if err != nil {
+		writeError(w, err, "Failed to load contacts")
+		return
+	}
+
+	data := struct {
+		Task     *models.Task
+		Contacts []models.Contact // Every contact, for the picker
+		Roles    []models.ContactRole
+	}{
+		Task:     task,
+		Contacts: contacts,
+		Roles:    models.ContactRoles,
+	}
+	if err := h.templates.ExecuteTemplate(w, "task_contacts.html", data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Failed to render contacts", http.StatusInternalServerError)
+	}
+}
+
+// GetTaskAttachments returns the attachments section of the task details,
+// with a form for uploading more
+func (h *Handlers) GetTaskAttachments(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	h.renderTaskAttachments(w, taskID)
+}
+
+// UploadTaskAttachment attaches the file uploaded in the task details,
+// returning the updated attachments section
+func (h *Handlers) UploadTaskAttachment(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.receiveAttachment(w, r, models.Attachment{TaskID: &taskID}); err != nil {
+		writeError(w, err, "Failed to upload attachment")
+		return
+	}
+
+	h.renderTaskAttachments(w, taskID)
+}
+
+// DeleteTaskAttachment deletes an attachment of a task, returning the
+// updated attachments section
+func (h *Handlers) DeleteTaskAttachment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+	attachmentID, err := strconv.Atoi(vars["attachmentId"])
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := h.db.GetAttachment(attachmentID)
+	if err != nil {
+		writeError(w, err, "Failed to load attachment")
+		return
+	}
+	if attachment.TaskID == nil || *attachment.TaskID != taskID {
+		http.Error(w, fmt.Sprintf("attachment %d is not on task %d", attachmentID, taskID), http.StatusNotFound)
+		return
+	}
+	if err := h.deleteAttachment(attachmentID); err != nil {
+		writeError(w, err, "Failed to delete attachment")
+		return
+	}
+
+	h.renderTaskAttachments(w, taskID)
+}
+
+// renderTaskAttachments renders the attachments of a task with the upload form
+func (h *Handlers) renderTaskAttachments(w http.ResponseWriter, taskID int) {
+	attachments, err := h.db.GetTaskAttachments(taskID)
+	if err != nil {
+		writeError(w, err, "Failed to load attachments")
+		return
+	}
+
+	data := struct {
+		TaskID      int
+		Attachments []models.Attachment
+		MaxSize     int64
+	}{
+		TaskID:      taskID,
+		Attachments: attachments,
+		MaxSize:     h.files.MaxSize(),
+	}
+	if err := h.templates.ExecuteTemplate(w, "task_attachments.html", data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Failed to render attachments", http.StatusInternalServerError)
+	}
+}
+
+// DownloadAttachment streams the file of an attachment. Images, audio,
+// video, PDFs and plain text open in the browser; other files, or any file
+// asked for with ?download=1, are saved under their original name.
+func (h *Handlers) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := h.db.GetAttachment(attachmentID)
+	if err != nil {
+		writeError(w, err, "Failed to load attachment")
+		return
+	}
+	file, err := h.files.Open(attachment.Filename)
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, fmt.Sprintf("the file of attachment %d is missing", attachmentID), http.StatusNotFound)
+		return
+	} else if err != nil {
+		writeError(w, err, "Failed to open attachment")
+		return
+	}
+	defer file.Close()
+
+	mimeType := attachment.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	disposition := "attachment"
+	if r.URL.Query().Get("download") == "" && opensInBrowser(mimeType) {
+		disposition = "inline"
+	}
+	if header := mime.FormatMediaType(disposition, map[string]string{"filename": attachment.OriginalFilename}); header != "" {
+		disposition = header
+	}
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Disposition", disposition)
+	// Uploads are never trusted to run anything in the page of the app
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	// The content of a file never changes, so its key is a perfect ETag
+	w.Header().Set("ETag", `"`+attachment.Filename+`"`)
+	http.ServeContent(w, r, "", attachment.CreatedAt, file)
+}
+
+// opensInBrowser reports whether files of a MIME type are shown rather than
+// saved. SVG images are saved, as they can hold scripts.
+func opensInBrowser(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "image/svg"):
+		return false
+	case strings.HasPrefix(mimeType, "image/"), strings.HasPrefix(mimeType, "audio/"),
+		strings.HasPrefix(mimeType, "video/"), strings.HasPrefix(mimeType, "text/plain"):
+		return true
+	}
+	return mimeType == "application/pdf"
+}
+
+// GetContactTasks returns the tasks a contact is linked to, for the thread viewer
+func (h *Handlers) GetContactTasks(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	contact, err := h.db.GetContact(contactID)
+	if err != nil {
+		writeError(w, err, "Failed to load contact")
+		return
+	}
+	tasks, err := h.db.GetContactTasks(contactID)
+	if err != nil {
+		writeError(w, err, "Failed to load tasks")
+		return
+	}
+
+	data := struct {
+		Contact *models.Contact
+		Tasks   []models.ContactTask
+	}{
+		Contact: contact,
+		Tasks:   tasks,
+	}
+	if err := h.templates.ExecuteTemplate(w, "contact_tasks.html", data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Failed to render tasks", http.StatusInternalServerError)
+	}
+}
+
+// GetWaitingOn returns the "waiting on others" list of the dashboard
+func (h *Handlers) GetWaitingOn(w http.ResponseWriter, r *http.Request) {
+	waiting, err := h.db.GetWaitingOn(time.Now())
+	if err != nil {
+		writeError(w, err, "Failed to load waiting list")
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "waiting_on.html", waiting); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Failed to render waiting list", http.StatusInternalServerError)
+	}
+}
+
+// GetContacts returns all contacts
+func (h *Handlers) GetContacts(w http.ResponseWriter, r *http.Request) {
+	contacts, err := h.db.GetAllContacts()
+	if err != nil {
+		log.Printf("Error getting contacts: %v", err)
+		http.Error(w, "Failed to load contacts", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "contact_threads.html", contacts); err != nil {
+		log.Printf("Error executing contacts template: %v", err)
+		http.Error(w, "Failed to render contacts", http.StatusInternalServerError)
+	}
+}
+
+// CreateContact returns the form for a new contact, and on POST saves it
+// and returns its card to append to the contact list
+func (h *Handlers) CreateContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		contact := &models.Contact{Type: string(models.ContactPerson)}
+		if err := h.templates.ExecuteTemplate(w, "contact_form.html", contact); err != nil {
+			log.Printf("Error executing template: %v", err)
+			http.Error(w, "Failed to render form", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	contact, err := h.db.CreateContact(contactFormRequest(r))
+	if err != nil {
+		writeError(w, err, "Failed to create contact")
+		return
+	}
+
+	w.Header().Set("HX-Retarget", ".contacts-list")
+	w.Header().Set("HX-Reswap", "beforeend")
+	h.renderContactCard(w, contact)
+}
+
+// EditContact returns the form for changing a contact, and on POST saves it
+// and returns its card to replace the old one
+func (h *Handlers) EditContact(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "GET" {
+		contact, err := h.db.GetContact(contactID)
+		if err != nil {
+			writeError(w, err, "Failed to load contact")
+			return
+		}
+		if err := h.templates.ExecuteTemplate(w, "contact_form.html", contact); err != nil {
+			log.Printf("Error executing template: %v", err)
+			http.Error(w, "Failed to render form", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	contact, err := h.db.UpdateContact(contactID, contactFormRequest(r))
+	if err != nil {
+		writeError(w, err, "Failed to update contact")
+		return
+	}
+
+	w.Header().Set("HX-Retarget", fmt.Sprintf("#contact-%d", contact.ID))
+	w.Header().Set("HX-Reswap", "outerHTML")
+	h.renderContactCard(w, contact)
+}
+
+// DeleteContact deletes a contact, leaving nothing in place of its card
+func (h *Handlers) DeleteContact(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteContact(contactID); err != nil {
+		writeError(w, err, "Failed to delete contact")
+		return
+	}
+}
+
+// contactFormRequest reads the fields of the contact form
+func contactFormRequest(r *http.Request) *models.ContactRequest {
+	name := r.FormValue("name")
+	email := r.FormValue("email")
+	phone := r.FormValue("phone")
+	contactType := models.ContactType(r.FormValue("type"))
+	notes := r.FormValue("notes")
+	return &models.ContactRequest{Name: &name, Email: &email, Phone: &phone, Type: &contactType, Notes: &notes}
+}
+
+// renderContactCard renders the card of one contact
+func (h *Handlers) renderContactCard(w http.ResponseWriter, contact *models.Contact) {
+	if err := h.templates.ExecuteTemplate(w, "contact_card", contact); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Failed to render contact", http.StatusInternalServerError)
+	}
+}
+
+// ThreadsView is the data for the thread_history.html fragment
+type ThreadsView struct {
+	Contact *models.Contact
+	Groups  []models.ThreadGroup
+	Type     models.ThreadType // Thread type shown, empty for all
+	Types    []models.ThreadType
+	Statuses []string // Statuses an outbound thread can be given
+}
+
+// GetContactThreads returns the history with a contact grouped by subject,
+// only threads of the given type when there is one
+func (h *Handlers) GetContactThreads(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	h.renderThreads(w, contactID, models.ThreadType(r.URL.Query().Get("type")))
+}
+
+// renderThreads renders the thread history of a contact
+func (h *Handlers) renderThreads(w http.ResponseWriter, contactID int, threadType models.ThreadType) {
+	contact, err := h.db.GetContact(contactID)
+	if err != nil {
+		writeError(w, err, "Failed to load contact")
+		return
+	}
+	threads, err := h.db.GetContactThreads(contactID, threadType)
+	if err != nil {
+		writeError(w, err, "Failed to load threads")
+		return
+	}
+
+	data := ThreadsView{
+		Contact: contact,
+		Groups:  models.GroupThreads(threads),
+		Type:     threadType,
+		Types:    models.ThreadTypes,
+		Statuses: models.OutboundThreadStatuses,
+	}
+	if err := h.templates.ExecuteTemplate(w, "thread_history.html", data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Failed to render threads", http.StatusInternalServerError)
+	}
+}
+
+// UpdateThreadStatus changes the status of a thread from the history, such
+// as marking a pending message sent, and returns the updated history
+func (h *Handlers) UpdateThreadStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	contactID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+	threadID, err := strconv.Atoi(vars["threadId"])
+	if err != nil {
+		http.Error(w, "Invalid thread ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	status := r.FormValue("status")
+	if _, err := h.db.UpdateThread(contactID, threadID, &models.ThreadUpdate{Status: &status}); err != nil {
+		writeError(w, err, "Failed to update thread")
+		return
+	}
+
+	h.renderThreads(w, contactID, models.ThreadType(r.FormValue("type")))
+}
+
+// CreateMessage returns the form for recording a message, email or call,
+// with the subject and type given in the query filled in, and on POST saves
+// it and returns the updated history for the thread viewer
+func (h *Handlers) CreateMessage(w http.ResponseWriter, r *http.Request) {
+	contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "GET" {
+		contact, err := h.db.GetContact(contactID)
+		if err != nil {
+			writeError(w, err, "Failed to load contact")
+			return
+		}
+		tasks, _, err := h.db.ListTasks(database.TaskFilter{
+			Statuses: []models.TaskStatus{models.StatusPending, models.StatusInProgress, models.StatusBlocked},
+		})
+		if err != nil {
+			writeError(w, err, "Failed to load tasks")
+			return
+		}
+
+		query := r.URL.Query()
+		data := struct {
+			Contact   *models.Contact
+			Subject   string
+			Type      models.ThreadType
+			Types     []models.ThreadType
+			Direction string
+			Tasks     []models.Task
+		}{
+			Contact:   contact,
+			Type:      models.ThreadType(query.Get("type")),
+			Types:     models.ThreadTypes,
+			Direction: query.Get("direction"),
+			Tasks:     tasks,
+		}
+		if subject := query.Get("subject"); subject != "" {
+			data.Subject = "Re: " + models.ThreadSubject(subject)
+		}
+		if err := h.templates.ExecuteTemplate(w, "message_form.html", data); err != nil {
+			log.Printf("Error executing template: %v", err)
+			http.Error(w, "Failed to render form", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	req := &models.ThreadRequest{
+		Subject:    r.FormValue("subject"),
+		Message:    r.FormValue("message"),
+		ThreadType: models.ThreadType(r.FormValue("type")),
+		Direction:  r.FormValue("direction"),
+		Status:     r.FormValue("status"),
+	}
+	if value := r.FormValue("task_id"); value != "" {
+		taskID, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid task ID", http.StatusBadRequest)
+			return
+		}
+		req.TaskID = &taskID
+	}
+
+	if _, err := h.db.CreateThread(contactID, req); err != nil {
+		writeError(w, err, "Failed to save message")
+		return
+	}
+
+	w.Header().Set("HX-Retarget", "#thread-viewer")
+	w.Header().Set("HX-Reswap", "innerHTML")
+	h.renderThreads(w, contactID, "")
+}
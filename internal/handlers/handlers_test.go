@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"oppgaave/internal/database"
+	"oppgaave/internal/models"
+)
+
+func TestParseTaskFilter(t *testing.T) {
+	day := func(s string) *time.Time {
+		d, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			t.Fatalf("bad date %q: %v", s, err)
+		}
+		return &d
+	}
+	id := 7
+	yes, no := true, false
+
+	tests := []struct {
+		query string
+		want  database.TaskFilter
+	}{
+		{"", database.TaskFilter{}},
+		{
+			"status=pending,in_progress&status=blocked&type=meeting",
+			database.TaskFilter{
+				Statuses:  []models.TaskStatus{models.StatusPending, models.StatusInProgress, models.StatusBlocked},
+				TaskTypes: []models.TaskType{models.TypeMeeting},
+			},
+		},
+		{"tags=work,+home+,,&tag_match=all", database.TaskFilter{Tags: []string{"work", "home"}, AllTags: true}},
+		{"tags=work&tag_match=any", database.TaskFilter{Tags: []string{"work"}}},
+		{"min_priority=2&max_priority=3&energy=1&limit=20", database.TaskFilter{MinPriority: 2, MaxPriority: 3, EnergyLevel: 1, Limit: 20}},
+		{
+			"deadline_before=2026-10-20&deadline_after=2026-10-01",
+			database.TaskFilter{DeadlineBefore: day("2026-10-21"), DeadlineAfter: day("2026-10-01")},
+		},
+		{"parent=none", database.TaskFilter{TopLevel: true}},
+		{"parent=7", database.TaskFilter{ParentID: &id}},
+		{"has_prerequisites=true", database.TaskFilter{HasPrerequisites: &yes}},
+		{"has_prerequisites=0", database.TaskFilter{HasPrerequisites: &no}},
+		{"sort=deadline&order=desc&cursor=abc", database.TaskFilter{Sort: database.SortDeadline, Descending: &yes, Cursor: "abc"}},
+		{"order=asc", database.TaskFilter{Descending: &no}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parseTaskFilter(httptest.NewRequest("GET", "/api/tasks?"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("parseTaskFilter: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTaskFilter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTaskFilterErrors(t *testing.T) {
+	tests := []struct {
+		query, message string
+	}{
+		{"tag_match=some", "invalid tag_match"},
+		{"min_priority=high", "invalid min_priority"},
+		{"limit=-1", "invalid limit"},
+		{"deadline_before=20.10.2026", "invalid deadline_before"},
+		{"deadline_after=soon", "invalid deadline_after"},
+		{"parent=root", "invalid parent"},
+		{"has_prerequisites=maybe", "invalid has_prerequisites"},
+		{"order=up", "invalid order"},
+	}
+	for _, tt := range tests {
+		_, err := parseTaskFilter(httptest.NewRequest("GET", "/api/tasks?"+tt.query, nil))
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("parseTaskFilter(%q) error = %v, want %q", tt.query, err, tt.message)
+		}
+	}
+}
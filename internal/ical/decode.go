@@ -0,0 +1,268 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotCalendar is returned when the input has no VCALENDAR object
+var ErrNotCalendar = errors.New("not an iCalendar file")
+
+// Decode parses an iCalendar stream. Only the components directly inside
+// VCALENDAR are kept; nested ones such as VALARM are skipped.
+func Decode(r io.Reader) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cal     *Calendar
+		current *Component
+		depth   int // 1 inside VCALENDAR, 2 inside a component, more inside nested ones
+	)
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		switch strings.ToUpper(p.Name) {
+		case "BEGIN":
+			depth++
+			switch {
+			case depth == 1 && strings.EqualFold(p.Value, "VCALENDAR"):
+				if cal != nil {
+					return nil, fmt.Errorf("line %d: only one VCALENDAR is supported", i+1)
+				}
+				cal = &Calendar{}
+			case depth == 1:
+				return nil, ErrNotCalendar
+			case depth == 2:
+				current = &Component{Name: strings.ToUpper(p.Value)}
+			}
+			continue
+		case "END":
+			if depth == 2 && current != nil {
+				cal.Components = append(cal.Components, *current)
+				current = nil
+			}
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("line %d: END without BEGIN", i+1)
+			}
+			continue
+		}
+
+		switch depth {
+		case 1:
+			cal.Props = append(cal.Props, p)
+		case 2:
+			current.Props = append(current.Props, p)
+		}
+	}
+
+	if cal == nil {
+		return nil, ErrNotCalendar
+	}
+	if depth != 0 {
+		return nil, errors.New("unexpected end of calendar")
+	}
+	return cal, nil
+}
+
+// unfold splits the input into logical lines, joining folded continuations
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+	return lines, nil
+}
+
+// parseLine splits a content line into name, parameters and value
+func parseLine(line string) (Property, error) {
+	var p Property
+
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return p, fmt.Errorf("malformed content line %q", line)
+	}
+	p.Name = strings.ToUpper(line[:end])
+	rest := line[end:]
+
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return p, fmt.Errorf("malformed parameter in %q", line)
+		}
+		param := Param{Name: strings.ToUpper(rest[:eq])}
+		rest = rest[eq+1:]
+
+		// The value runs to the next ; or : outside double quotes
+		var value strings.Builder
+		quoted := false
+		i := 0
+		for ; i < len(rest); i++ {
+			c := rest[i]
+			if c == '"' {
+				quoted = !quoted
+				continue
+			}
+			if !quoted && (c == ';' || c == ':') {
+				break
+			}
+			value.WriteByte(c)
+		}
+		param.Value = value.String()
+		p.Params = append(p.Params, param)
+		rest = rest[i:]
+	}
+
+	if !strings.HasPrefix(rest, ":") {
+		return p, fmt.Errorf("missing value in %q", line)
+	}
+	p.Value = rest[1:]
+	return p, nil
+}
+
+// Get returns the first property with the given name, or nil
+func (c *Component) Get(name string) *Property {
+	for i := range c.Props {
+		if c.Props[i].Name == name {
+			return &c.Props[i]
+		}
+	}
+	return nil
+}
+
+// GetAll returns every property with the given name
+func (c *Component) GetAll(name string) []Property {
+	var props []Property
+	for _, p := range c.Props {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// Text returns the unescaped text of the first property with the given name
+func (c *Component) Text(name string) string {
+	if p := c.Get(name); p != nil {
+		return UnescapeText(p.Value)
+	}
+	return ""
+}
+
+// Param returns the value of a parameter, or "" if it is not set
+func (p *Property) Param(name string) string {
+	for _, param := range p.Params {
+		if param.Name == name {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// UnescapeText reverses EscapeText
+func UnescapeText(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			sb.WriteByte('\n')
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// ParseTime parses a DATE or DATE-TIME property. UTC times end in Z, times
+// with a TZID are read in that zone and floating times in local time, as
+// are dates, which are reported as all-day.
+func ParseTime(p *Property) (t time.Time, allDay bool, err error) {
+	return parseTimeValue(p.Value, p.Param("TZID"), strings.EqualFold(p.Param("VALUE"), "DATE"))
+}
+
+func parseTimeValue(value, tzid string, isDate bool) (time.Time, bool, error) {
+	if isDate || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		if err != nil {
+			return t, true, fmt.Errorf("invalid date %q", value)
+		}
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return t, false, fmt.Errorf("invalid date-time %q", value)
+		}
+		return t, false, nil
+	}
+
+	loc := time.Local
+	if tzid != "" {
+		// Zones we do not know, such as Windows names, fall back to local time
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return t, false, fmt.Errorf("invalid date-time %q", value)
+	}
+	return t, false, nil
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ParseDuration parses a DURATION value such as PT1H30M or P1D
+func ParseDuration(value string) (time.Duration, error) {
+	m := durationPattern.FindStringSubmatch(value)
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(m[i+2])
+		d += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
@@ -0,0 +1,192 @@
+package ical
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:1@example.com",
+		"SUMMARY:A summary folded",
+		"  over two lines",
+		"DESCRIPTION:Tab\tfolded",
+		"\t too",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"UID:2@example.com",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n") + "\r\n"
+
+	cal, err := Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(cal.Props) != 1 || cal.Props[0].Name != "VERSION" {
+		t.Errorf("calendar props = %+v, want VERSION only", cal.Props)
+	}
+	if len(cal.Components) != 2 || cal.Components[0].Name != "VEVENT" || cal.Components[1].Name != "VTODO" {
+		t.Fatalf("components = %+v, want a VEVENT and a VTODO", cal.Components)
+	}
+	event := cal.Components[0]
+	if got := event.Text("SUMMARY"); got != "A summary folded over two lines" {
+		t.Errorf("SUMMARY = %q", got)
+	}
+	if got := event.Text("DESCRIPTION"); got != "Tab\tfolded too" {
+		t.Errorf("DESCRIPTION = %q", got)
+	}
+	if event.Get("ACTION") != nil {
+		t.Errorf("properties of the nested VALARM were kept")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		notCal  bool
+		message string
+	}{
+		{"empty", "", true, ""},
+		{"other object", "BEGIN:VCARD\r\nEND:VCARD\r\n", true, ""},
+		{"two calendars", "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\nBEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", false, "only one VCALENDAR"},
+		{"unterminated", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\n", false, "unexpected end"},
+		{"line without value", "BEGIN:VCALENDAR\r\nSUMMARY\r\nEND:VCALENDAR\r\n", false, "malformed content line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.input))
+			switch {
+			case err == nil:
+				t.Fatal("Decode succeeded, want an error")
+			case tt.notCal && !errors.Is(err, ErrNotCalendar):
+				t.Errorf("error %v, want ErrNotCalendar", err)
+			case !tt.notCal && !strings.Contains(err.Error(), tt.message):
+				t.Errorf("error %v, want it to mention %q", err, tt.message)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want Property
+	}{
+		{"summary:Dentist", Property{Name: "SUMMARY", Value: "Dentist"}},
+		{"DTSTART;TZID=Europe/Oslo:20261020T100000", Property{
+			Name: "DTSTART", Params: []Param{{"TZID", "Europe/Oslo"}}, Value: "20261020T100000",
+		}},
+		{`ORGANIZER;CN="Doe, Jane: MD";ROLE=CHAIR:mailto:jane@example.com`, Property{
+			Name:   "ORGANIZER",
+			Params: []Param{{"CN", "Doe, Jane: MD"}, {"ROLE", "CHAIR"}},
+			Value:  "mailto:jane@example.com",
+		}},
+		{"DESCRIPTION:", Property{Name: "DESCRIPTION"}},
+	}
+	for _, tt := range tests {
+		got, err := parseLine(tt.line)
+		if err != nil {
+			t.Errorf("parseLine(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{":value", "NAME", "NAME;PARAM:value", "NAME;PARAM=x"} {
+		if _, err := parseLine(line); err == nil {
+			t.Errorf("parseLine(%q) succeeded, want an error", line)
+		}
+	}
+}
+
+func TestUnescapeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`a\;b\,c`, "a;b,c"},
+		{`two\nlines\NA`, "two\nlines\nA"},
+		{`back\\slash`, `back\slash`},
+		{`trailing\`, `trailing\`},
+	}
+	for _, tt := range tests {
+		if got := UnescapeText(tt.in); got != tt.want {
+			t.Errorf("UnescapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if got := UnescapeText(EscapeText(tt.want)); got != tt.want {
+			t.Errorf("UnescapeText(EscapeText(%q)) = %q", tt.want, got)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+	tests := []struct {
+		prop   Property
+		want   time.Time
+		allDay bool
+	}{
+		{Property{Value: "20261020T100000Z"}, time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC), false},
+		{Property{Value: "20261020T100000", Params: []Param{{"TZID", "Europe/Oslo"}}}, time.Date(2026, 10, 20, 10, 0, 0, 0, oslo), false},
+		{Property{Value: "20261020T100000", Params: []Param{{"TZID", "W. Europe Standard Time"}}}, time.Date(2026, 10, 20, 10, 0, 0, 0, time.Local), false},
+		{Property{Value: "20261020T100000"}, time.Date(2026, 10, 20, 10, 0, 0, 0, time.Local), false},
+		{Property{Value: "20261020", Params: []Param{{"VALUE", "DATE"}}}, time.Date(2026, 10, 20, 0, 0, 0, 0, time.Local), true},
+		{Property{Value: "20261020"}, time.Date(2026, 10, 20, 0, 0, 0, 0, time.Local), true},
+	}
+	for _, tt := range tests {
+		got, allDay, err := ParseTime(&tt.prop)
+		if err != nil {
+			t.Errorf("ParseTime(%+v): %v", tt.prop, err)
+			continue
+		}
+		if !got.Equal(tt.want) || allDay != tt.allDay {
+			t.Errorf("ParseTime(%+v) = %v, %v, want %v, %v", tt.prop, got, allDay, tt.want, tt.allDay)
+		}
+	}
+
+	if _, _, err := ParseTime(&Property{Value: "2026-10-20T10:00"}); err == nil {
+		t.Error("ParseTime accepted an ISO 8601 time")
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"PT1H30M", 90 * time.Minute},
+		{"P1D", 24 * time.Hour},
+		{"P1W", 7 * 24 * time.Hour},
+		{"P1DT2H", 26 * time.Hour},
+		{"PT45S", 45 * time.Second},
+		{"-PT15M", -15 * time.Minute},
+		{"+PT5M", 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "P", "PT", "P1DT", "1H", "PT1.5H"} {
+		if _, err := ParseDuration(value); err == nil {
+			t.Errorf("ParseDuration(%q) succeeded, want an error", value)
+		}
+	}
+}
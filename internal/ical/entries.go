@@ -0,0 +1,405 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"oppgaave/internal/models"
+)
+
+// DefaultImportDays is how far ahead recurring series are expanded by default
+const DefaultImportDays = 90
+
+// maxInstances caps the instances made from a single recurring series
+const maxInstances = 500
+
+// ImportWindow returns the window recurring series are expanded over, from
+// the start of today to the given number of days ahead
+func ImportWindow(days int) (from, until time.Time) {
+	now := time.Now()
+	from = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return from, from.AddDate(0, 0, days)
+}
+
+// ToEntries maps the VEVENT and VTODO components of a calendar onto task
+// requests. A recurring series becomes one entry per instance starting
+// between from and until, each with its own UID; EXDATE leaves instances out
+// and a component with a RECURRENCE-ID replaces the instance it names.
+func ToEntries(cal *Calendar, from, until time.Time) ([]models.CalendarEntry, error) {
+	// Modified instances of recurring series, by instance UID
+	overrides := make(map[string]*Component)
+	for i := range cal.Components {
+		c := &cal.Components[i]
+		p := c.Get("RECURRENCE-ID")
+		if !isTaskComponent(c) || p == nil {
+			continue
+		}
+		t, _, err := ParseTime(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: RECURRENCE-ID: %w", c.Text("UID"), err)
+		}
+		overrides[instanceUID(c.Text("UID"), t)] = c
+	}
+	used := make(map[string]bool)
+
+	var entries []models.CalendarEntry
+	for i := range cal.Components {
+		c := &cal.Components[i]
+		if !isTaskComponent(c) || c.Get("RECURRENCE-ID") != nil {
+			continue
+		}
+
+		uid := c.Text("UID")
+		if uid == "" {
+			entries = append(entries, models.CalendarEntry{
+				Task:       models.CreateTaskRequest{Title: c.Text("SUMMARY")},
+				SkipReason: "no UID",
+			})
+			continue
+		}
+
+		p := c.Get("RRULE")
+		if p == nil {
+			entry, err := toEntry(c, uid, nil)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", uid, err)
+			}
+			entries = append(entries, entry)
+			continue
+		}
+
+		instances, err := expand(c, p.Value, from, until)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", uid, err)
+		}
+		for _, t := range instances {
+			key := instanceUID(uid, t)
+			var entry models.CalendarEntry
+			if o, ok := overrides[key]; ok {
+				used[key] = true
+				entry, err = toEntry(o, key, nil)
+			} else {
+				entry, err = toEntry(c, key, &t)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	// Modified instances in the window whose series is not in the file still
+	// stand on their own
+	for i := range cal.Components {
+		c := &cal.Components[i]
+		p := c.Get("RECURRENCE-ID")
+		if !isTaskComponent(c) || p == nil {
+			continue
+		}
+		t, _, _ := ParseTime(p)
+		key := instanceUID(c.Text("UID"), t)
+		if used[key] || t.Before(from) || !t.Before(until) {
+			continue
+		}
+		entry, err := toEntry(c, key, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// isTaskComponent reports whether a component can become a task
+func isTaskComponent(c *Component) bool {
+	return c.Name == "VEVENT" || c.Name == "VTODO"
+}
+
+// instanceUID identifies one instance of a recurring series
+func instanceUID(uid string, start time.Time) string {
+	return uid + "/" + FormatDateTime(start)
+}
+
+// expand returns the instance start times of a recurring component that
+// fall between from and until, leaving out its EXDATEs
+func expand(c *Component, value string, from, until time.Time) ([]time.Time, error) {
+	rule, err := ParseRRule(value)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor := c.Get("DTSTART")
+	if anchor == nil {
+		anchor = c.Get("DUE")
+	}
+	if anchor == nil {
+		return nil, fmt.Errorf("recurring %s without DTSTART", c.Name)
+	}
+	start, _, err := ParseTime(anchor)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", anchor.Name, err)
+	}
+
+	var excluded []time.Time
+	for _, p := range c.GetAll("EXDATE") {
+		isDate := strings.EqualFold(p.Param("VALUE"), "DATE")
+		for _, value := range strings.Split(p.Value, ",") {
+			t, _, err := parseTimeValue(value, p.Param("TZID"), isDate)
+			if err != nil {
+				return nil, fmt.Errorf("EXDATE: %w", err)
+			}
+			excluded = append(excluded, t)
+		}
+	}
+
+	var instances []time.Time
+	for _, t := range rule.Occurrences(start, until, maxPeriods) {
+		if t.Before(from) || containsTime(excluded, t) {
+			continue
+		}
+		if len(instances) == maxInstances {
+			break
+		}
+		instances = append(instances, t)
+	}
+	return instances, nil
+}
+
+// containsTime reports whether times has an instant equal to t
+func containsTime(times []time.Time, t time.Time) bool {
+	for _, other := range times {
+		if other.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// toEntry maps a VEVENT or VTODO onto a task request. For an instance of a
+// recurring series, at is its start and the other times move along with it.
+func toEntry(c *Component, uid string, at *time.Time) (models.CalendarEntry, error) {
+	entry := models.CalendarEntry{UID: uid, TaskID: TaskID(uid)}
+	req := &entry.Task
+	req.Title = strings.TrimSpace(c.Text("SUMMARY"))
+	if req.Title == "" {
+		req.Title = "Untitled " + strings.ToLower(strings.TrimPrefix(c.Name, "V"))
+	}
+	req.Description = c.Text("DESCRIPTION")
+	req.EventLocation = c.Text("LOCATION")
+	req.EstimatedDurationMins = 30
+	req.Priority = taskPriority(c.Get("PRIORITY"))
+	req.EnergyLevel = 2
+	req.Difficulty = 2
+
+	start, end, due, err := componentTimes(c)
+	if err != nil {
+		return entry, err
+	}
+	if at != nil {
+		anchor := start
+		if anchor == nil {
+			anchor = due
+		}
+		shift := func(t *time.Time) *time.Time {
+			if t == nil {
+				return nil
+			}
+			moved := at.Add(t.Sub(*anchor))
+			return &moved
+		}
+		start, end, due = shift(start), shift(end), shift(due)
+	}
+
+	entry.Contacts = componentContacts(c)
+
+	var taskType models.TaskType
+	for _, category := range categories(c) {
+		switch t := models.TaskType(strings.ToLower(category)); t {
+		case models.TypeAppointment, models.TypeEvent, models.TypeConcert, models.TypeMeeting:
+			taskType = t
+		default:
+			req.Tags = append(req.Tags, category)
+		}
+	}
+
+	switch c.Name {
+	case "VEVENT":
+		// Events without a type of their own are meetings when others attend
+		if taskType == "" {
+			taskType = models.TypeEvent
+			if c.Get("ATTENDEE") != nil {
+				taskType = models.TypeMeeting
+			}
+		}
+		req.TaskType = taskType
+		req.EventStart = start
+		req.EventEnd = end
+		if start != nil && end != nil {
+			if mins := int(end.Sub(*start).Minutes()); mins > 0 {
+				req.EstimatedDurationMins = mins
+			}
+		}
+	case "VTODO":
+		req.TaskType = models.TypeTask
+		req.Deadline = due
+		if p := c.Get("DURATION"); p != nil {
+			d, err := ParseDuration(p.Value)
+			if err != nil {
+				return entry, err
+			}
+			if mins := int(d.Minutes()); mins > 0 {
+				req.EstimatedDurationMins = mins
+			}
+		}
+	}
+
+	switch strings.ToUpper(c.Text("STATUS")) {
+	case "CANCELLED":
+		entry.SkipReason = "cancelled"
+	case "COMPLETED":
+		entry.SkipReason = "already completed"
+	}
+	return entry, nil
+}
+
+// componentTimes reads the start, end and due times of a component. An event
+// without an end lasts for its DURATION, or the whole day when it is all-day.
+// A to-do due on a date is due by the end of that day.
+func componentTimes(c *Component) (start, end, due *time.Time, err error) {
+	var allDay bool
+	if p := c.Get("DTSTART"); p != nil {
+		var t time.Time
+		if t, allDay, err = ParseTime(p); err != nil {
+			return nil, nil, nil, fmt.Errorf("DTSTART: %w", err)
+		}
+		start = &t
+	}
+
+	switch {
+	case c.Get("DTEND") != nil:
+		t, _, err := ParseTime(c.Get("DTEND"))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("DTEND: %w", err)
+		}
+		end = &t
+	case start != nil && c.Name == "VEVENT" && c.Get("DURATION") != nil:
+		d, err := ParseDuration(c.Get("DURATION").Value)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		t := start.Add(d)
+		end = &t
+	case start != nil && allDay:
+		t := start.AddDate(0, 0, 1)
+		end = &t
+	}
+
+	if p := c.Get("DUE"); p != nil {
+		t, dateOnly, err := ParseTime(p)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("DUE: %w", err)
+		}
+		if dateOnly {
+			t = t.Add(24*time.Hour - time.Minute)
+		}
+		due = &t
+	}
+
+	return start, end, due, nil
+}
+
+// categories returns the unescaped values of every CATEGORIES property
+func categories(c *Component) []string {
+	var list []string
+	for _, p := range c.GetAll("CATEGORIES") {
+		for _, value := range splitText(p.Value) {
+			if value = strings.TrimSpace(UnescapeText(value)); value != "" {
+				list = append(list, value)
+			}
+		}
+	}
+	return list
+}
+
+// splitText splits a multi-valued TEXT value on commas that are not escaped
+func splitText(value string) []string {
+	var (
+		parts []string
+		start int
+	)
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// componentContacts maps ORGANIZER and ATTENDEE properties with an email
+// address onto contacts, the reverse of addContacts
+func componentContacts(c *Component) []models.CalendarContact {
+	var contacts []models.CalendarContact
+	seen := make(map[string]bool)
+	add := func(p *Property, role, contactType string) {
+		email := p.Value
+		if len(email) > len("mailto:") && strings.EqualFold(email[:len("mailto:")], "mailto:") {
+			email = email[len("mailto:"):]
+		}
+		email = strings.TrimSpace(email)
+		if email == "" || !strings.Contains(email, "@") || seen[strings.ToLower(email)] {
+			return
+		}
+		seen[strings.ToLower(email)] = true
+
+		name := strings.TrimSpace(p.Param("CN"))
+		if name == "" {
+			name = email
+		}
+		contacts = append(contacts, models.CalendarContact{Name: name, Email: email, Type: contactType, Role: role})
+	}
+
+	if p := c.Get("ORGANIZER"); p != nil {
+		add(p, "organizer", "person")
+	}
+	for _, p := range c.GetAll("ATTENDEE") {
+		cutype := strings.ToUpper(p.Param("CUTYPE"))
+		role := strings.ToUpper(p.Param("ROLE"))
+		switch {
+		case cutype == "ROOM":
+			add(&p, "venue", "venue")
+		case role == "CHAIR":
+			add(&p, "organizer", "person")
+		case cutype == "RESOURCE" || role == "NON-PARTICIPANT":
+			add(&p, "vendor", "organization")
+		case cutype == "GROUP":
+			add(&p, "participant", "organization")
+		default:
+			add(&p, "participant", "person")
+		}
+	}
+	return contacts
+}
+
+// taskPriority maps the iCalendar 1 (high) to 9 (low) scale onto our 1-3
+// priority, the reverse of todoPriority. Undefined (0) is medium.
+func taskPriority(p *Property) int {
+	if p == nil {
+		return 2
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(p.Value))
+	switch {
+	case err != nil || n <= 0 || n == 5:
+		return 2
+	case n < 5:
+		return 3
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,125 @@
+// Package ical reads and writes iCalendar (RFC 5545) data
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Calendar is a VCALENDAR object
+type Calendar struct {
+	Props      []Property
+	Components []Component
+}
+
+// Component is a calendar component such as VEVENT or VTODO
+type Component struct {
+	Name  string
+	Props []Property
+}
+
+// Property is a content line: a name, optional parameters and a value.
+// The value is written as is, so text values must be escaped with EscapeText.
+type Property struct {
+	Name   string
+	Params []Param
+	Value  string
+}
+
+// Param is a property parameter such as CN=Jane
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Add appends a property to the component
+func (c *Component) Add(name, value string, params ...Param) {
+	c.Props = append(c.Props, Property{Name: name, Params: params, Value: value})
+}
+
+// AddText appends a property with an escaped text value, skipping empty ones
+func (c *Component) AddText(name, text string, params ...Param) {
+	if text != "" {
+		c.Add(name, EscapeText(text), params...)
+	}
+}
+
+// Encode writes the calendar with CRLF line endings and long lines folded
+func Encode(w io.Writer, cal *Calendar) error {
+	bw := bufio.NewWriter(w)
+	writeLine(bw, "BEGIN:VCALENDAR")
+	for _, p := range cal.Props {
+		writeProperty(bw, p)
+	}
+	for _, c := range cal.Components {
+		writeLine(bw, "BEGIN:"+c.Name)
+		for _, p := range c.Props {
+			writeProperty(bw, p)
+		}
+		writeLine(bw, "END:"+c.Name)
+	}
+	writeLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// writeProperty writes one content line
+func writeProperty(w *bufio.Writer, p Property) {
+	var sb strings.Builder
+	sb.WriteString(p.Name)
+	for _, param := range p.Params {
+		sb.WriteString(";")
+		sb.WriteString(param.Name)
+		sb.WriteString("=")
+		sb.WriteString(quoteParam(param.Value))
+	}
+	sb.WriteString(":")
+	sb.WriteString(p.Value)
+	writeLine(w, sb.String())
+}
+
+// writeLine folds a line into chunks of at most 75 octets, never splitting
+// a UTF-8 character, continuing each chunk on a line starting with a space
+func writeLine(w *bufio.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // the leading space counts too
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+// EscapeText escapes a TEXT value
+func EscapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// quoteParam quotes a parameter value when it contains separators. Double
+// quotes cannot appear in parameter values at all and are dropped.
+func quoteParam(s string) string {
+	s = strings.ReplaceAll(s, `"`, "")
+	if strings.ContainsAny(s, ":;,") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// FormatDateTime formats a time as a UTC DATE-TIME value
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
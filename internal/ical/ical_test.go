@@ -0,0 +1,71 @@
+package ical
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWriteLineFolds(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		lines int
+	}{
+		{"short", "SUMMARY:Dentist", 1},
+		{"exactly 75 octets", "X:" + strings.Repeat("a", 73), 1},
+		{"76 octets", "X:" + strings.Repeat("a", 74), 2},
+		{"continuation holds 74 octets", "X:" + strings.Repeat("a", 73+74), 2},
+		{"spills onto a third line", "X:" + strings.Repeat("a", 73+75), 3},
+		{"never splits a character", "X:" + strings.Repeat("a", 72) + strings.Repeat("ø", 40), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			w := bufio.NewWriter(&sb)
+			writeLine(w, tt.line)
+			w.Flush()
+			out := sb.String()
+
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("output %q does not end in CRLF", out)
+			}
+			physical := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			if len(physical) != tt.lines {
+				t.Errorf("got %d lines, want %d", len(physical), tt.lines)
+			}
+			for i, line := range physical {
+				if len(line) > 75 {
+					t.Errorf("line %d is %d octets long", i, len(line))
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a character: %q", i, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d does not start with a space", i)
+				}
+			}
+			if unfolded := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", ""); unfolded != tt.line {
+				t.Errorf("unfolded to %q, want %q", unfolded, tt.line)
+			}
+		})
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"a;b,c", `a\;b\,c`},
+		{`back\slash`, `back\\slash`},
+		{"two\nlines", `two\nlines`},
+		{"crlf\r\nlines", `crlf\nlines`},
+	}
+	for _, tt := range tests {
+		if got := EscapeText(tt.in); got != tt.want {
+			t.Errorf("EscapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
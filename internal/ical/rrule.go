@@ -0,0 +1,233 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPeriods stops runaway expansion of rules that never match
+const maxPeriods = 10000
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// byDay is a BYDAY entry such as MO or, in monthly rules, 2TU or -1FR
+type byDay struct {
+	ordinal int // 0 for every such weekday
+	weekday time.Weekday
+}
+
+// RRule is a recurrence rule. FREQ, INTERVAL, COUNT, UNTIL, BYDAY and
+// BYMONTHDAY are supported; other parts are ignored.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []byDay
+	ByMonthDay []int
+}
+
+// ParseRRule parses the value of an RRULE property
+func ParseRRule(value string) (*RRule, error) {
+	rule := &RRule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, val := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+
+		switch key {
+		case "FREQ":
+			rule.Freq = val
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", val)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT %q", val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, _, err := parseTimeValue(val, "", false)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL: %w", err)
+			}
+			if len(val) == len("20060102") {
+				// A date includes the whole day
+				until = until.Add(24*time.Hour - time.Second)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				if len(day) < 2 {
+					return nil, fmt.Errorf("invalid BYDAY %q", day)
+				}
+				weekday, ok := weekdays[day[len(day)-2:]]
+				if !ok {
+					return nil, fmt.Errorf("invalid BYDAY %q", day)
+				}
+				var ordinal int
+				if prefix := day[:len(day)-2]; prefix != "" {
+					n, err := strconv.Atoi(prefix)
+					if err != nil {
+						return nil, fmt.Errorf("invalid BYDAY %q", day)
+					}
+					ordinal = n
+				}
+				rule.ByDay = append(rule.ByDay, byDay{ordinal, weekday})
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY %q", day)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		}
+	}
+
+	switch rule.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	case "":
+		return nil, fmt.Errorf("RRULE without FREQ")
+	default:
+		return nil, fmt.Errorf("unsupported FREQ %q", rule.Freq)
+	}
+	return rule, nil
+}
+
+// Occurrences returns the start times of the instances of a series starting
+// at start that begin before until, at most limit of them. start is always
+// the first instance.
+func (r *RRule) Occurrences(start, until time.Time, limit int) []time.Time {
+	var (
+		occurrences []time.Time
+		count       int
+	)
+	for period := 0; period < maxPeriods; period++ {
+		for _, t := range r.candidates(start, period) {
+			if t.Before(start) {
+				continue
+			}
+			if !t.Before(until) || (r.Until != nil && t.After(*r.Until)) ||
+				(r.Count > 0 && count >= r.Count) || len(occurrences) >= limit {
+				return occurrences
+			}
+			count++
+			occurrences = append(occurrences, t)
+		}
+	}
+	return occurrences
+}
+
+// candidates returns the instances a period of the rule could have, in order
+func (r *RRule) candidates(start time.Time, period int) []time.Time {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+	step := period * r.Interval
+
+	var days []time.Time
+	switch r.Freq {
+	case "DAILY":
+		day := start.AddDate(0, 0, step)
+		if len(r.ByDay) == 0 || r.hasWeekday(day.Weekday()) {
+			days = append(days, day)
+		}
+
+	case "WEEKLY":
+		offset := (int(start.Weekday()) + 6) % 7 // days since Monday
+		monday := at(start.Year(), start.Month(), start.Day()-offset+7*step)
+		if len(r.ByDay) == 0 {
+			days = append(days, monday.AddDate(0, 0, offset))
+			break
+		}
+		for i := 0; i < 7; i++ {
+			day := monday.AddDate(0, 0, i)
+			if r.hasWeekday(day.Weekday()) {
+				days = append(days, day)
+			}
+		}
+
+	case "MONTHLY":
+		first := at(start.Year(), start.Month()+time.Month(step), 1)
+		days = r.monthDays(first, start.Day())
+
+	case "YEARLY":
+		day := at(start.Year()+step, start.Month(), start.Day())
+		if day.Day() == start.Day() { // skip Feb 29 in other years
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// monthDays returns the matching days of the month beginning at first
+func (r *RRule) monthDays(first time.Time, defaultDay int) []time.Time {
+	daysInMonth := first.AddDate(0, 1, -1).Day()
+	inMonth := func(day int) (time.Time, bool) {
+		if day < 0 {
+			day = daysInMonth + 1 + day
+		}
+		if day < 1 || day > daysInMonth {
+			return time.Time{}, false
+		}
+		return first.AddDate(0, 0, day-1), true
+	}
+
+	var days []time.Time
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if day, ok := inMonth(defaultDay); ok {
+			days = append(days, day)
+		}
+		return days
+	}
+
+	for _, n := range r.ByMonthDay {
+		if day, ok := inMonth(n); ok {
+			days = append(days, day)
+		}
+	}
+	for _, bd := range r.ByDay {
+		// Every matching weekday of the month, then pick by ordinal
+		var matches []time.Time
+		for d := 1; d <= daysInMonth; d++ {
+			if day, _ := inMonth(d); day.Weekday() == bd.weekday {
+				matches = append(matches, day)
+			}
+		}
+		switch {
+		case bd.ordinal == 0:
+			days = append(days, matches...)
+		case bd.ordinal > 0 && bd.ordinal <= len(matches):
+			days = append(days, matches[bd.ordinal-1])
+		case bd.ordinal < 0 && -bd.ordinal <= len(matches):
+			days = append(days, matches[len(matches)+bd.ordinal])
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// hasWeekday reports whether BYDAY includes the weekday
+func (r *RRule) hasWeekday(weekday time.Weekday) bool {
+	for _, bd := range r.ByDay {
+		if bd.weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRRuleOccurrences(t *testing.T) {
+	at := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatalf("bad date %q: %v", s, err)
+		}
+		return d.Add(10 * time.Hour)
+	}
+	far := at("2030-01-01")
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		until time.Time
+		limit int
+		want  []string
+	}{
+		{"daily count", "FREQ=DAILY;COUNT=3", "2026-10-05", far, 10, []string{"2026-10-05", "2026-10-06", "2026-10-07"}},
+		{"daily interval up to the window", "FREQ=DAILY;INTERVAL=2", "2026-10-05", at("2026-10-10"), 10, []string{"2026-10-05", "2026-10-07", "2026-10-09"}},
+		{"until date includes its day", "FREQ=DAILY;UNTIL=20261007", "2026-10-05", far, 10, []string{"2026-10-05", "2026-10-06", "2026-10-07"}},
+		{"until time", "FREQ=DAILY;UNTIL=20261007T090000Z", "2026-10-05", far, 10, []string{"2026-10-05", "2026-10-06"}},
+		{"daily on weekdays", "FREQ=DAILY;BYDAY=SA,SU", "2026-10-10", far, 3, []string{"2026-10-10", "2026-10-11", "2026-10-17"}},
+		{"weekly by day from midweek", "FREQ=WEEKLY;BYDAY=MO,TH", "2026-10-08", far, 3, []string{"2026-10-08", "2026-10-12", "2026-10-15"}},
+		{"fortnightly", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", "2026-10-05", far, 3, []string{"2026-10-05", "2026-10-19", "2026-11-02"}},
+		{"weekly on the start day", "FREQ=WEEKLY", "2026-10-08", far, 2, []string{"2026-10-08", "2026-10-15"}},
+		{"monthly skips short months", "FREQ=MONTHLY", "2026-01-31", far, 3, []string{"2026-01-31", "2026-03-31", "2026-05-31"}},
+		{"monthly last day", "FREQ=MONTHLY;BYMONTHDAY=-1", "2026-01-31", far, 3, []string{"2026-01-31", "2026-02-28", "2026-03-31"}},
+		{"monthly last Friday", "FREQ=MONTHLY;BYDAY=-1FR", "2026-10-30", far, 3, []string{"2026-10-30", "2026-11-27", "2026-12-25"}},
+		{"monthly second Tuesday", "FREQ=MONTHLY;BYDAY=2TU", "2026-10-13", far, 2, []string{"2026-10-13", "2026-11-10"}},
+		{"yearly leap day", "FREQ=YEARLY", "2024-02-29", far, 2, []string{"2024-02-29", "2028-02-29"}},
+		{"limit", "FREQ=DAILY", "2026-10-05", far, 2, []string{"2026-10-05", "2026-10-06"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+			var got []string
+			for _, o := range rule.Occurrences(at(tt.start), tt.until, tt.limit) {
+				if o.Hour() != 10 {
+					t.Errorf("occurrence %v lost the time of day of the start", o)
+				}
+				got = append(got, o.Format("2006-01-02"))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Occurrences() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=x",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=aMO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+	} {
+		if _, err := ParseRRule(value); err == nil {
+			t.Errorf("ParseRRule(%q) succeeded, want an error", value)
+		}
+	}
+}
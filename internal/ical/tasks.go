@@ -0,0 +1,156 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"oppgaave/internal/models"
+)
+
+// ProdID identifies this application as the producer of exported calendars
+const ProdID = "-//oppgaave//ADHD Task Manager//EN"
+
+// UID returns the stable calendar UID of a task
+func UID(taskID int) string {
+	return fmt.Sprintf("task-%d@oppgaave", taskID)
+}
+
+// TaskID returns the ID of the task a UID made by UID stands for, or 0 for
+// UIDs from other calendars
+func TaskID(uid string) int {
+	rest, ok := strings.CutPrefix(uid, "task-")
+	if !ok {
+		return 0
+	}
+	rest, ok = strings.CutSuffix(rest, "@oppgaave")
+	if !ok {
+		return 0
+	}
+	id, err := strconv.Atoi(rest)
+	if err != nil || id <= 0 || UID(id) != uid {
+		return 0
+	}
+	return id
+}
+
+// FromTasks builds a calendar with a VEVENT for every task that is an event
+// with a start time and a VTODO for every other task with a deadline. Tasks
+// with neither are left out.
+func FromTasks(name string, tasks []models.Task) *Calendar {
+	cal := &Calendar{Props: []Property{
+		{Name: "VERSION", Value: "2.0"},
+		{Name: "PRODID", Value: ProdID},
+		{Name: "CALSCALE", Value: "GREGORIAN"},
+		{Name: "X-WR-CALNAME", Value: EscapeText(name)},
+		{Name: "X-PUBLISHED-TTL", Value: "PT15M"},
+	}}
+
+	for i := range tasks {
+		task := &tasks[i]
+		switch {
+		case task.IsEvent() && task.EventStart != nil:
+			cal.Components = append(cal.Components, eventComponent(task))
+		case task.Deadline != nil:
+			cal.Components = append(cal.Components, todoComponent(task))
+		}
+	}
+	return cal
+}
+
+// eventComponent maps an event task to a VEVENT
+func eventComponent(task *models.Task) Component {
+	c := Component{Name: "VEVENT"}
+	addCommon(&c, task)
+	c.Add("DTSTART", FormatDateTime(*task.EventStart))
+	c.Add("DTEND", FormatDateTime(task.EventStart.Add(task.GetEventDuration())))
+	c.AddText("LOCATION", task.EventLocation)
+	addContacts(&c, task.Contacts)
+	return c
+}
+
+// todoComponent maps a task with a deadline to a VTODO
+func todoComponent(task *models.Task) Component {
+	c := Component{Name: "VTODO"}
+	addCommon(&c, task)
+	c.Add("DUE", FormatDateTime(*task.Deadline))
+	c.Add("PRIORITY", todoPriority(task.Priority))
+
+	switch task.Status {
+	case models.StatusDone:
+		c.Add("STATUS", "COMPLETED")
+		if task.CompletedAt != nil {
+			c.Add("COMPLETED", FormatDateTime(*task.CompletedAt))
+		}
+	case models.StatusInProgress:
+		c.Add("STATUS", "IN-PROCESS")
+	default:
+		c.Add("STATUS", "NEEDS-ACTION")
+	}
+
+	addContacts(&c, task.Contacts)
+	return c
+}
+
+// addCommon adds the properties shared by events and to-dos
+func addCommon(c *Component, task *models.Task) {
+	c.Add("UID", UID(task.ID))
+	c.Add("DTSTAMP", FormatDateTime(task.UpdatedAt))
+	c.Add("LAST-MODIFIED", FormatDateTime(task.UpdatedAt))
+	c.AddText("SUMMARY", task.Title)
+	c.AddText("DESCRIPTION", task.Description)
+
+	categories := make([]string, 0, len(task.Tags)+1)
+	if task.TaskType != "" && task.TaskType != models.TypeTask {
+		categories = append(categories, EscapeText(string(task.TaskType)))
+	}
+	for _, tag := range task.Tags {
+		categories = append(categories, EscapeText(tag))
+	}
+	if len(categories) > 0 {
+		c.Add("CATEGORIES", strings.Join(categories, ","))
+	}
+}
+
+// addContacts adds the linked contacts that have an email address: the first
+// organizer as ORGANIZER and everyone else as an ATTENDEE with a role
+// matching their part in the task
+func addContacts(c *Component, contacts []models.Contact) {
+	hasOrganizer := false
+	for _, contact := range contacts {
+		if contact.Email == "" {
+			continue
+		}
+		address := "mailto:" + contact.Email
+		name := Param{Name: "CN", Value: contact.Name}
+
+		switch contact.Role {
+		case "organizer":
+			if !hasOrganizer {
+				c.Add("ORGANIZER", address, name)
+				hasOrganizer = true
+				continue
+			}
+			c.Add("ATTENDEE", address, name, Param{Name: "ROLE", Value: "CHAIR"})
+		case "venue":
+			c.Add("ATTENDEE", address, name, Param{Name: "CUTYPE", Value: "ROOM"},
+				Param{Name: "ROLE", Value: "NON-PARTICIPANT"})
+		case "vendor":
+			c.Add("ATTENDEE", address, name, Param{Name: "ROLE", Value: "NON-PARTICIPANT"})
+		default:
+			c.Add("ATTENDEE", address, name, Param{Name: "ROLE", Value: "REQ-PARTICIPANT"})
+		}
+	}
+}
+
+// todoPriority maps our 1-3 priority onto the iCalendar 1 (high) to 9 (low) scale
+func todoPriority(priority int) string {
+	switch priority {
+	case 3:
+		return "1"
+	case 2:
+		return "5"
+	default:
+		return "9"
+	}
+}
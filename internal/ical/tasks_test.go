@@ -0,0 +1,25 @@
+package ical
+
+import "testing"
+
+func TestTaskID(t *testing.T) {
+	tests := []struct {
+		uid  string
+		want int
+	}{
+		{UID(1), 1},
+		{UID(42), 42},
+		{"task-42@example.com", 0},
+		{"task-x@oppgaave", 0},
+		{"task-042@oppgaave", 0},
+		{"task--1@oppgaave", 0},
+		{"task-0@oppgaave", 0},
+		{"event-42@oppgaave", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := TaskID(tt.uid); got != tt.want {
+			t.Errorf("TaskID(%q) = %d, want %d", tt.uid, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package models
+
+import "fmt"
+
+// BulkAction is what one operation of a bulk request does
+type BulkAction string
+
+const (
+	BulkCreate          BulkAction = "create"
+	BulkUpdate          BulkAction = "update"
+	BulkSetStatus       BulkAction = "set_status"
+	BulkDelete          BulkAction = "delete"
+	BulkAddTag          BulkAction = "add_tag"
+	BulkReparent        BulkAction = "reparent"
+	BulkAddPrerequisite BulkAction = "add_prerequisite"
+)
+
+// BulkOperation is one change of a bulk request. Which fields are read
+// depends on the action.
+type BulkOperation struct {
+	Action         BulkAction         `json:"action"`
+	TaskID         int                `json:"task_id,omitempty"`         // Task changed, for all actions but create
+	Task           *CreateTaskRequest `json:"task,omitempty"`            // create
+	Update         *UpdateTaskRequest `json:"update,omitempty"`          // update
+	Status         TaskStatus         `json:"status,omitempty"`          // set_status
+	Cascade        bool               `json:"cascade,omitempty"`         // delete, also deleting subtasks
+	Tag            string             `json:"tag,omitempty"`             // add_tag
+	ParentID       *int               `json:"parent_id,omitempty"`       // reparent, null or 0 for the top level
+	PrerequisiteID int                `json:"prerequisite_id,omitempty"` // add_prerequisite
+}
+
+// Validate checks that the operation has the fields its action needs
+func (op *BulkOperation) Validate() error {
+	switch op.Action {
+	case BulkCreate:
+		if op.Task == nil {
+			return fmt.Errorf("task is required for create")
+		}
+	case BulkUpdate:
+		if op.Update == nil {
+			return fmt.Errorf("update is required for update")
+		}
+	case BulkSetStatus:
+		if !op.Status.Valid() {
+			return fmt.Errorf("unknown status %q", op.Status)
+		}
+	case BulkAddTag:
+		name, err := NormalizeTagName(op.Tag)
+		if err != nil {
+			return err
+		}
+		op.Tag = name
+	case BulkAddPrerequisite:
+		if op.PrerequisiteID <= 0 {
+			return fmt.Errorf("prerequisite_id is required for add_prerequisite")
+		}
+	case BulkDelete, BulkReparent:
+	default:
+		return fmt.Errorf("unknown action %q", op.Action)
+	}
+	if op.Action != BulkCreate && op.TaskID <= 0 {
+		return fmt.Errorf("task_id is required for %s", op.Action)
+	}
+	return nil
+}
+
+// BulkRequest applies many task changes in one transaction
+type BulkRequest struct {
+	Operations []BulkOperation `json:"operations"`
+	Atomic     bool            `json:"atomic"` // Undo every operation if any of them fails
+}
+
+// BulkResult reports how each operation of a bulk request went
+type BulkResult struct {
+	Results    []BulkItemResult `json:"results"`
+	Succeeded  int              `json:"succeeded"`
+	Failed     int              `json:"failed"`
+	RolledBack bool             `json:"rolled_back"` // An atomic request failed and nothing was kept
+}
+
+// BulkItemResult is the outcome of one operation, in request order
+type BulkItemResult struct {
+	Index  int        `json:"index"`
+	Action BulkAction `json:"action"`
+	TaskID int        `json:"task_id,omitempty"`
+	OK     bool       `json:"ok"`
+	Error  string     `json:"error,omitempty"`
+	Task   *Task      `json:"task,omitempty"` // The task after the change, left out on delete
+}
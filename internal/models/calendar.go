@@ -0,0 +1,58 @@
+package models
+
+// CalendarEntry is an event or to-do read from an iCalendar file, ready to be
+// created as a task or to update the task imported from it before
+type CalendarEntry struct {
+	UID      string            `json:"uid"`               // Unique per instance of a recurring event
+	TaskID   int               `json:"task_id,omitempty"` // Task the entry was exported from, by this app
+	Task     CreateTaskRequest `json:"task"`
+	Contacts []CalendarContact `json:"contacts,omitempty"`
+
+	// SkipReason is set for entries that should not become tasks, such as
+	// cancelled events, so the import can still report them
+	SkipReason string `json:"skip_reason,omitempty"`
+}
+
+// CalendarContact is an organizer or attendee of a calendar entry
+type CalendarContact struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Type  string `json:"type"` // person, organization, venue
+	Role  string `json:"role"` // organizer, participant, venue, vendor
+}
+
+// CalendarImportResult reports what an import did with each entry
+type CalendarImportResult struct {
+	Created   int                   `json:"created"`
+	Updated   int                   `json:"updated"`
+	Unchanged int                   `json:"unchanged"`
+	Skipped   int                   `json:"skipped"`
+	Failed    int                   `json:"failed"`
+	Entries   []CalendarImportEntry `json:"entries"`
+}
+
+// CalendarImportEntry is the outcome for one calendar entry
+type CalendarImportEntry struct {
+	UID    string `json:"uid"`
+	TaskID int    `json:"task_id,omitempty"`
+	Title  string `json:"title"`
+	Action string `json:"action"` // created, updated, unchanged, skipped, failed
+	Reason string `json:"reason,omitempty"`
+}
+
+// Count records an entry and tallies its action
+func (r *CalendarImportResult) Count(entry CalendarImportEntry) {
+	switch entry.Action {
+	case "created":
+		r.Created++
+	case "updated":
+		r.Updated++
+	case "unchanged":
+		r.Unchanged++
+	case "skipped":
+		r.Skipped++
+	case "failed":
+		r.Failed++
+	}
+	r.Entries = append(r.Entries, entry)
+}
@@ -0,0 +1,234 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// MinCalibrationSamples is how many finished tasks a group needs before its factor is trusted
+const MinCalibrationSamples = 3
+
+// Calibration dimensions a factor can be grouped by
+const (
+	DimensionOverall    = "overall"
+	DimensionTag        = "tag"
+	DimensionTaskType   = "task_type"
+	DimensionDifficulty = "difficulty"
+	DimensionEnergy     = "energy_level"
+)
+
+// EstimateSample is a finished task with tracked time, used to learn estimate accuracy
+type EstimateSample struct {
+	TaskID           int
+	EstimatedMinutes int
+	ActualMinutes    float64
+	TaskType         TaskType
+	Difficulty       int
+	EnergyLevel      int
+	Tags             Tags
+}
+
+// EstimateFactor is the historical ratio of actual to estimated time for one group of tasks
+type EstimateFactor struct {
+	Dimension        string  `json:"dimension"`
+	Value            string  `json:"value"`
+	Ratio            float64 `json:"ratio"`
+	Samples          int     `json:"samples"`
+	EstimatedMinutes int     `json:"estimated_minutes"`
+	ActualMinutes    int     `json:"actual_minutes"`
+}
+
+// Describe phrases the factor for people, e.g. "you usually take 1.7× longer on hard tasks"
+func (f *EstimateFactor) Describe() string {
+	var group string
+	switch f.Dimension {
+	case DimensionTag:
+		group = fmt.Sprintf("tasks tagged %q", f.Value)
+	case DimensionTaskType:
+		group = "regular tasks"
+		if f.Value != string(TypeTask) {
+			group = f.Value + "s"
+		}
+	case DimensionDifficulty:
+		n, _ := strconv.Atoi(f.Value)
+		group = []string{"", "easy", "medium", "hard"}[clampLevel(n)] + " tasks"
+	case DimensionEnergy:
+		n, _ := strconv.Atoi(f.Value)
+		group = []string{"", "low-energy", "medium-energy", "high-energy"}[clampLevel(n)] + " tasks"
+	default:
+		group = "tasks"
+	}
+
+	if f.Ratio >= 1 {
+		return fmt.Sprintf("you usually take %.1f× longer on %s", f.Ratio, group)
+	}
+	return fmt.Sprintf("you usually finish %s in %.0f%% of the estimate", group, f.Ratio*100)
+}
+
+// Calibration holds the learned estimate factors
+type Calibration struct {
+	Overall *EstimateFactor  `json:"overall"`
+	Factors []EstimateFactor `json:"factors"`
+}
+
+// EstimateCalibration is the correction suggested for a single task
+type EstimateCalibration struct {
+	Factor                float64 `json:"factor"`
+	SuggestedDurationMins int     `json:"suggested_duration_minutes"`
+	Applied               bool    `json:"applied"`
+	Reason                string  `json:"reason"`
+}
+
+// BuildCalibration groups samples by tag, task type, difficulty and energy
+// level and computes the ratio of total actual to total estimated minutes
+func BuildCalibration(samples []EstimateSample) *Calibration {
+	type key struct{ dimension, value string }
+	type totals struct {
+		samples   int
+		estimated int
+		actual    float64
+	}
+
+	groups := make(map[key]*totals)
+	add := func(k key, s EstimateSample) {
+		g, ok := groups[k]
+		if !ok {
+			g = &totals{}
+			groups[k] = g
+		}
+		g.samples++
+		g.estimated += s.EstimatedMinutes
+		g.actual += s.ActualMinutes
+	}
+
+	for _, s := range samples {
+		if s.EstimatedMinutes <= 0 {
+			continue
+		}
+		add(key{DimensionOverall, ""}, s)
+		add(key{DimensionTaskType, string(normalizeTaskType(s.TaskType))}, s)
+		add(key{DimensionDifficulty, strconv.Itoa(s.Difficulty)}, s)
+		add(key{DimensionEnergy, strconv.Itoa(s.EnergyLevel)}, s)
+		for _, tag := range s.Tags {
+			add(key{DimensionTag, tag}, s)
+		}
+	}
+
+	calibration := &Calibration{Factors: []EstimateFactor{}}
+	for k, g := range groups {
+		factor := EstimateFactor{
+			Dimension:        k.dimension,
+			Value:            k.value,
+			Ratio:            math.Round(g.actual/float64(g.estimated)*100) / 100,
+			Samples:          g.samples,
+			EstimatedMinutes: g.estimated,
+			ActualMinutes:    int(g.actual),
+		}
+		if k.dimension == DimensionOverall {
+			calibration.Overall = &factor
+			continue
+		}
+		calibration.Factors = append(calibration.Factors, factor)
+	}
+
+	sort.Slice(calibration.Factors, func(i, j int) bool {
+		a, b := calibration.Factors[i], calibration.Factors[j]
+		if a.Dimension != b.Dimension {
+			return a.Dimension < b.Dimension
+		}
+		return a.Value < b.Value
+	})
+
+	return calibration
+}
+
+// Insights returns the trusted factors that differ noticeably from a perfect
+// estimate, most significant first
+func (c *Calibration) Insights() []EstimateFactor {
+	var insights []EstimateFactor
+	for _, f := range c.Factors {
+		if f.Samples >= MinCalibrationSamples && math.Abs(math.Log(f.Ratio)) >= math.Log(1.2) {
+			insights = append(insights, f)
+		}
+	}
+	sort.SliceStable(insights, func(i, j int) bool {
+		return math.Abs(math.Log(insights[i].Ratio)) > math.Abs(math.Log(insights[j].Ratio))
+	})
+	return insights
+}
+
+// For suggests a corrected estimate for a task. The factor is the
+// sample-weighted mean of every trusted group the task belongs to, falling
+// back to the overall ratio. It returns nil when there is not enough history.
+func (c *Calibration) For(t *Task) *EstimateCalibration {
+	matches := func(f *EstimateFactor) bool {
+		switch f.Dimension {
+		case DimensionTaskType:
+			return f.Value == string(normalizeTaskType(t.TaskType))
+		case DimensionDifficulty:
+			return f.Value == strconv.Itoa(t.Difficulty)
+		case DimensionEnergy:
+			return f.Value == strconv.Itoa(t.EnergyLevel)
+		case DimensionTag:
+			for _, tag := range t.Tags {
+				if tag == f.Value {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var (
+		weighted  float64
+		samples   int
+		strongest *EstimateFactor
+	)
+	for i := range c.Factors {
+		f := &c.Factors[i]
+		if f.Samples < MinCalibrationSamples || !matches(f) {
+			continue
+		}
+		weighted += f.Ratio * float64(f.Samples)
+		samples += f.Samples
+		if strongest == nil || math.Abs(math.Log(f.Ratio)) > math.Abs(math.Log(strongest.Ratio)) {
+			strongest = f
+		}
+	}
+
+	if samples == 0 {
+		if c.Overall == nil || c.Overall.Samples < MinCalibrationSamples {
+			return nil
+		}
+		weighted, samples, strongest = c.Overall.Ratio, 1, c.Overall
+	}
+
+	factor := math.Max(0.25, math.Min(4, weighted/float64(samples)))
+	factor = math.Round(factor*100) / 100
+	return &EstimateCalibration{
+		Factor:                factor,
+		SuggestedDurationMins: int(math.Round(float64(t.EstimatedDurationMins) * factor)),
+		Reason:                strongest.Describe(),
+	}
+}
+
+// normalizeTaskType treats tasks created without a type as regular tasks
+func normalizeTaskType(taskType TaskType) TaskType {
+	if taskType == "" {
+		return TypeTask
+	}
+	return taskType
+}
+
+// clampLevel keeps a 1-3 level within bounds for lookups
+func clampLevel(n int) int {
+	if n < 1 {
+		return 1
+	}
+	if n > 3 {
+		return 3
+	}
+	return n
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBuildCalibration(t *testing.T) {
+	samples := []EstimateSample{
+		{TaskID: 1, EstimatedMinutes: 30, ActualMinutes: 60, Difficulty: 3, EnergyLevel: 2, Tags: Tags{"work"}},
+		{TaskID: 2, EstimatedMinutes: 60, ActualMinutes: 90, TaskType: TypeTask, Difficulty: 3, EnergyLevel: 1, Tags: Tags{"work", "home"}},
+		{TaskID: 3, EstimatedMinutes: 0, ActualMinutes: 100, TaskType: TypeTask, Difficulty: 2, EnergyLevel: 3},
+		{TaskID: 4, EstimatedMinutes: 10, ActualMinutes: 5, TaskType: TypeMeeting, Difficulty: 1, EnergyLevel: 2},
+	}
+	c := BuildCalibration(samples)
+
+	if c.Overall == nil {
+		t.Fatal("no overall factor")
+	}
+	if c.Overall.Ratio != 1.55 || c.Overall.Samples != 3 || c.Overall.EstimatedMinutes != 100 || c.Overall.ActualMinutes != 155 {
+		t.Errorf("overall = %+v, want 1.55 over 3 samples of 100 estimated and 155 actual minutes", *c.Overall)
+	}
+
+	var got []string
+	for _, f := range c.Factors {
+		got = append(got, fmt.Sprintf("%s/%s=%.2fx%d", f.Dimension, f.Value, f.Ratio, f.Samples))
+	}
+	want := []string{
+		"difficulty/1=0.50x1",
+		"difficulty/3=1.67x2",
+		"energy_level/1=1.50x1",
+		"energy_level/2=1.63x2",
+		"tag/home=1.50x1",
+		"tag/work=1.67x2",
+		"task_type/meeting=0.50x1",
+		"task_type/task=1.67x2",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("factors =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCalibrationInsights(t *testing.T) {
+	c := &Calibration{Factors: []EstimateFactor{
+		{Dimension: DimensionTag, Value: "close", Ratio: 1.1, Samples: 10},
+		{Dimension: DimensionTag, Value: "few", Ratio: 3, Samples: 2},
+		{Dimension: DimensionTag, Value: "slow", Ratio: 1.5, Samples: 3},
+		{Dimension: DimensionTag, Value: "fast", Ratio: 0.4, Samples: 4},
+		{Dimension: DimensionTag, Value: "slower", Ratio: 2, Samples: 5},
+	}}
+	var got []string
+	for _, f := range c.Insights() {
+		got = append(got, f.Value)
+	}
+	if want := "fast slower slow"; strings.Join(got, " ") != want {
+		t.Errorf("Insights() = %v, want %s", got, want)
+	}
+}
+
+func TestCalibrationFor(t *testing.T) {
+	task := &Task{EstimatedDurationMins: 30, TaskType: TypeTask, Difficulty: 3, EnergyLevel: 2, Tags: Tags{"work"}}
+	tests := []struct {
+		name        string
+		calibration Calibration
+		factor      float64 // 0 when no suggestion is expected
+		suggested   int
+		reason      string
+	}{
+		{
+			name:        "not enough history",
+			calibration: Calibration{Overall: &EstimateFactor{Dimension: DimensionOverall, Ratio: 2, Samples: 2}},
+		},
+		{
+			name: "overall when no group is trusted",
+			calibration: Calibration{
+				Overall: &EstimateFactor{Dimension: DimensionOverall, Ratio: 1.5, Samples: 5},
+				Factors: []EstimateFactor{{Dimension: DimensionDifficulty, Value: "3", Ratio: 2, Samples: 2}},
+			},
+			factor: 1.5, suggested: 45, reason: "you usually take 1.5× longer on tasks",
+		},
+		{
+			name: "weighted mean of the groups the task is in",
+			calibration: Calibration{
+				Overall: &EstimateFactor{Dimension: DimensionOverall, Ratio: 3, Samples: 50},
+				Factors: []EstimateFactor{
+					{Dimension: DimensionDifficulty, Value: "3", Ratio: 2, Samples: 3},
+					{Dimension: DimensionTag, Value: "work", Ratio: 1, Samples: 6},
+					{Dimension: DimensionTaskType, Value: "meeting", Ratio: 0.5, Samples: 10},
+					{Dimension: DimensionEnergy, Value: "1", Ratio: 0.5, Samples: 10},
+				},
+			},
+			factor: 1.33, suggested: 40, reason: "you usually take 2.0× longer on hard tasks",
+		},
+		{
+			name:        "at most four times the estimate",
+			calibration: Calibration{Factors: []EstimateFactor{{Dimension: DimensionTag, Value: "work", Ratio: 10, Samples: 3}}},
+			factor:      4, suggested: 120, reason: `you usually take 10.0× longer on tasks tagged "work"`,
+		},
+		{
+			name:        "at least a quarter of the estimate",
+			calibration: Calibration{Factors: []EstimateFactor{{Dimension: DimensionTaskType, Value: "task", Ratio: 0.1, Samples: 3}}},
+			factor:      0.25, suggested: 8, reason: "you usually finish regular tasks in 10% of the estimate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.calibration.For(task)
+			if tt.factor == 0 {
+				if got != nil {
+					t.Errorf("For() = %+v, want nil", *got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("For() = nil")
+			}
+			if got.Factor != tt.factor || got.SuggestedDurationMins != tt.suggested || got.Reason != tt.reason {
+				t.Errorf("For() = %+v, want factor %v, %d minutes, %q", *got, tt.factor, tt.suggested, tt.reason)
+			}
+		})
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	c := &Calibration{Overall: &EstimateFactor{Dimension: DimensionOverall, Ratio: 2, Samples: 5}}
+	tests := []struct {
+		name           string
+		alreadyApplied bool
+		suggested      int
+		applies        bool
+		estimate       int
+	}{
+		{"fresh estimate", false, 60, true, 60},
+		{"corrected before", true, 30, false, 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &Task{EstimatedDurationMins: 30}
+			task.Calibrate(c, tt.alreadyApplied)
+			if task.CorrectedDurationMins() != tt.suggested {
+				t.Errorf("corrected duration = %d, want %d", task.CorrectedDurationMins(), tt.suggested)
+			}
+			if applied := task.ApplyCalibration(); applied != tt.applies {
+				t.Errorf("ApplyCalibration() = %v, want %v", applied, tt.applies)
+			}
+			if task.EstimatedDurationMins != tt.estimate {
+				t.Errorf("estimate = %d, want %d", task.EstimatedDurationMins, tt.estimate)
+			}
+			if task.ApplyCalibration() {
+				t.Error("calibration applied twice")
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ContactType is the kind of contact
+type ContactType string
+
+const (
+	ContactPerson       ContactType = "person"
+	ContactOrganization ContactType = "organization"
+	ContactVenue        ContactType = "venue"
+)
+
+// Valid reports whether the contact type is known
+func (t ContactType) Valid() bool {
+	switch t {
+	case ContactPerson, ContactOrganization, ContactVenue:
+		return true
+	}
+	return false
+}
+
+// phonePattern matches phone numbers written with digits, spaces, dots,
+// dashes and parentheses, optionally starting with +
+var phonePattern = regexp.MustCompile(`^\+?[0-9 ().-]+$`)
+
+// ContactRequest creates a contact or changes one. On update only the
+// fields sent change; an empty email, phone or notes clears them.
+type ContactRequest struct {
+	Name      *string      `json:"name"`
+	Email     *string      `json:"email"`
+	Phone     *string      `json:"phone"`
+	Type      *ContactType `json:"type"`
+	Notes     *string      `json:"notes"`
+	AvatarURL *string      `json:"avatar_url"`
+}
+
+// Validate normalizes the fields that were sent and checks them
+func (r *ContactRequest) Validate() error {
+	if r.Name != nil {
+		name := strings.TrimSpace(*r.Name)
+		if name == "" {
+			return fmt.Errorf("contact name is required")
+		}
+		r.Name = &name
+	}
+	if r.Email != nil {
+		email := strings.TrimSpace(*r.Email)
+		if email != "" {
+			addr, err := mail.ParseAddress(email)
+			if err != nil || addr.Address != email {
+				return fmt.Errorf("invalid email %q", *r.Email)
+			}
+		}
+		r.Email = &email
+	}
+	if r.Phone != nil {
+		phone := strings.Join(strings.Fields(*r.Phone), " ")
+		if phone != "" {
+			digits := 0
+			for _, c := range phone {
+				if c >= '0' && c <= '9' {
+					digits++
+				}
+			}
+			if !phonePattern.MatchString(phone) || digits < 3 || digits > 15 {
+				return fmt.Errorf("invalid phone number %q", *r.Phone)
+			}
+		}
+		r.Phone = &phone
+	}
+	if r.Type != nil && !r.Type.Valid() {
+		return fmt.Errorf("unknown contact type %q, expected person, organization or venue", *r.Type)
+	}
+	if r.Notes != nil {
+		notes := strings.TrimSpace(*r.Notes)
+		r.Notes = &notes
+	}
+	if r.AvatarURL != nil {
+		avatarURL := strings.TrimSpace(*r.AvatarURL)
+		r.AvatarURL = &avatarURL
+	}
+	return nil
+}
+
+// Apply copies the set fields of the request onto the contact
+func (r *ContactRequest) Apply(c *Contact) {
+	if r.Name != nil {
+		c.Name = *r.Name
+	}
+	if r.Email != nil {
+		c.Email = *r.Email
+	}
+	if r.Phone != nil {
+		c.Phone = *r.Phone
+	}
+	if r.Type != nil {
+		c.Type = string(*r.Type)
+	}
+	if r.Notes != nil {
+		c.Notes = *r.Notes
+	}
+	if r.AvatarURL != nil {
+		c.AvatarURL = *r.AvatarURL
+	}
+}
+
+// ContactRole is what a contact does for a task
+type ContactRole string
+
+const (
+	RoleOrganizer   ContactRole = "organizer"
+	RoleParticipant ContactRole = "participant"
+	RoleVenue       ContactRole = "venue"
+	RoleVendor      ContactRole = "vendor"
+)
+
+// ContactRoles lists the roles in the order they are offered
+var ContactRoles = []ContactRole{RoleOrganizer, RoleParticipant, RoleVenue, RoleVendor}
+
+// Valid reports whether the role is known
+func (r ContactRole) Valid() bool {
+	for _, known := range ContactRoles {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ContactLinkRequest links a contact to a task
+type ContactLinkRequest struct {
+	Role ContactRole `json:"role"` // participant when empty
+}
+
+// ContactTask is a task a contact is linked to, with their role on it
+type ContactTask struct {
+	Task
+	Role ContactRole `json:"role"`
+}
+
+// ThreadType is the channel of a communication
+type ThreadType string
+
+const (
+	ThreadMessage ThreadType = "message"
+	ThreadEmail   ThreadType = "email"
+	ThreadCall    ThreadType = "call"
+	ThreadMeeting ThreadType = "meeting"
+)
+
+// ThreadTypes lists the thread types in the order they are offered
+var ThreadTypes = []ThreadType{ThreadMessage, ThreadEmail, ThreadCall, ThreadMeeting}
+
+// Valid reports whether the thread type is known
+func (t ThreadType) Valid() bool {
+	for _, known := range ThreadTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Thread directions
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+)
+
+// Thread statuses
+const (
+	ThreadSent     = "sent"
+	ThreadReceived = "received"
+	ThreadPending  = "pending" // Written down but not sent yet
+	ThreadFailed   = "failed"
+)
+
+// OutboundThreadStatuses lists the statuses an outbound thread can have
+var OutboundThreadStatuses = []string{ThreadSent, ThreadPending, ThreadFailed}
+
+// ThreadRequest records a message, email, call or meeting with a contact
+type ThreadRequest struct {
+	Subject    string     `json:"subject"`
+	Message    string     `json:"message"`
+	ThreadType ThreadType `json:"thread_type"` // message when empty
+	Direction  string     `json:"direction"`   // outbound when empty
+	Status     string     `json:"status"`      // sent, or received for inbound, when empty
+	TaskID     *int       `json:"task_id"`
+}
+
+// Validate fills in the defaults and checks the fields
+func (r *ThreadRequest) Validate() error {
+	r.Subject = strings.TrimSpace(r.Subject)
+	r.Message = strings.TrimSpace(r.Message)
+	if r.Message == "" {
+		return fmt.Errorf("message is required")
+	}
+
+	if r.ThreadType == "" {
+		r.ThreadType = ThreadMessage
+	}
+	if !r.ThreadType.Valid() {
+		return fmt.Errorf("unknown thread type %q", r.ThreadType)
+	}
+
+	switch r.Direction {
+	case "":
+		r.Direction = DirectionOutbound
+	case DirectionInbound, DirectionOutbound:
+	default:
+		return fmt.Errorf("unknown direction %q, expected inbound or outbound", r.Direction)
+	}
+
+	if r.Status == "" {
+		r.Status = ThreadSent
+		if r.Direction == DirectionInbound {
+			r.Status = ThreadReceived
+		}
+	}
+	if err := checkThreadStatus(r.Direction, r.Status); err != nil {
+		return err
+	}
+
+	if r.TaskID != nil && *r.TaskID == 0 {
+		r.TaskID = nil
+	}
+	return nil
+}
+
+// ThreadUpdate changes a recorded thread. Only the status can change, such
+// as that of a pending message once it is sent.
+type ThreadUpdate struct {
+	Status *string `json:"status"`
+}
+
+// Validate checks the changes against the direction of the thread
+func (r *ThreadUpdate) Validate(direction string) error {
+	if r.Status == nil {
+		return nil
+	}
+	return checkThreadStatus(direction, *r.Status)
+}
+
+// checkThreadStatus checks that a status fits the direction of a thread
+func checkThreadStatus(direction, status string) error {
+	switch status {
+	case ThreadReceived:
+		if direction != DirectionInbound {
+			return fmt.Errorf("only inbound threads can be received")
+		}
+	case ThreadSent, ThreadPending, ThreadFailed:
+		if direction != DirectionOutbound {
+			return fmt.Errorf("inbound threads can only be received")
+		}
+	default:
+		return fmt.Errorf("unknown status %q", status)
+	}
+	return nil
+}
+
+// ThreadGroup is a conversation: the threads with a contact sharing a
+// subject once reply and forward prefixes are taken off
+type ThreadGroup struct {
+	Subject string          `json:"subject"`
+	Threads []ContactThread `json:"threads"` // Oldest first
+	LastAt  time.Time       `json:"last_at"`
+}
+
+// replyPrefix matches the Re: and Fwd: prefixes of replies and forwards
+var replyPrefix = regexp.MustCompile(`(?i)^\s*((re|fwd?|aw|sv|vs)\s*:\s*)+`)
+
+// ThreadSubject is the subject a thread is grouped under
+func ThreadSubject(subject string) string {
+	return strings.TrimSpace(replyPrefix.ReplaceAllString(subject, ""))
+}
+
+// GroupThreads groups threads by subject, keeping the order of the threads
+// within a group and putting the most recently active group first
+func GroupThreads(threads []ContactThread) []ThreadGroup {
+	var groups []ThreadGroup
+	index := make(map[string]int)
+	for _, thread := range threads {
+		subject := ThreadSubject(thread.Subject)
+		key := strings.ToLower(subject)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, ThreadGroup{Subject: subject})
+		}
+		groups[i].Threads = append(groups[i].Threads, thread)
+		if thread.CreatedAt.After(groups[i].LastAt) {
+			groups[i].LastAt = thread.CreatedAt
+		}
+	}
+	sort.SliceStable(groups, func(a, b int) bool {
+		return groups[a].LastAt.After(groups[b].LastAt)
+	})
+	return groups
+}
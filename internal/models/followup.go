@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Reasons a thread gets a follow-up task
+const (
+	FollowUpPending = "pending"  // The message was written down but never sent
+	FollowUpNoReply = "no_reply" // No reply came in time
+)
+
+// FollowUpRules decide which outbound threads need a follow-up task
+type FollowUpRules struct {
+	AfterDays int          // Days to wait for a reply, 0 to never follow up on silence
+	Pending   bool         // Whether pending messages need a follow-up
+	Types     []ThreadType // Kinds of threads that expect a reply
+}
+
+// Watches reports whether threads of type t expect a reply
+func (r FollowUpRules) Watches(t ThreadType) bool {
+	for _, watched := range r.Types {
+		if t == watched {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitingOn is a contact that has not answered what was sent to them
+type WaitingOn struct {
+	Contact     Contact       `json:"contact"`
+	Thread      ContactThread `json:"thread"`     // The latest unanswered thread
+	Unanswered  int           `json:"unanswered"` // How many threads are unanswered
+	Since       time.Time     `json:"since"`      // When the oldest of them was sent
+	DaysWaiting int           `json:"days_waiting"`
+	FollowUp    *Task         `json:"follow_up,omitempty"` // The open follow-up task, if any
+}
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// DayPlan is the schedule of tasks for one day within its coin budget
+type DayPlan struct {
+	Date         time.Time         `json:"date"`
+	DayStart     time.Time         `json:"day_start"`
+	DayEnd       time.Time         `json:"day_end"`
+	BudgetCoins  int               `json:"budget_coins"`
+	PlannedCoins int               `json:"planned_coins"`
+	Slots        []TaskSchedule    `json:"slots"`
+	Unscheduled  []UnscheduledTask `json:"unscheduled,omitempty"`
+}
+
+// UnscheduledTask is a candidate task the planner could not fit into the day
+type UnscheduledTask struct {
+	Task   Task   `json:"task"`
+	Reason string `json:"reason"`
+}
+
+// RemainingCoins is the budget left after the planned tasks
+func (p *DayPlan) RemainingCoins() int {
+	return p.BudgetCoins - p.PlannedCoins
+}
+
+// IsFixed reports whether the slot is a fixed-time event rather than a placed task
+func (s *TaskSchedule) IsFixed() bool {
+	return s.Task != nil && s.Task.IsEvent() && s.Task.EventStart != nil
+}
+
+// interval is a busy stretch of the day
+type interval struct {
+	start, end time.Time
+}
+
+// BuildDayPlan schedules tasks between dayStart and dayEnd. Fixed-time events
+// on that day are placed first. Flexible tasks then fill the gaps ordered by
+// priority, deadline and urgency, never before their prerequisites are done
+// or planned to end, and only while the plan stays within budgetCoins.
+// Tasks listed in hasOpenSubtasks are skipped since their children get planned instead,
+// as are occurrences of recurring tasks for a later day.
+func BuildDayPlan(dayStart, dayEnd time.Time, budgetCoins int, tasks []Task, hasOpenSubtasks map[int]bool) *DayPlan {
+	plan := &DayPlan{
+		Date:        time.Date(dayStart.Year(), dayStart.Month(), dayStart.Day(), 0, 0, 0, 0, dayStart.Location()),
+		DayStart:    dayStart,
+		DayEnd:      dayEnd,
+		BudgetCoins: budgetCoins,
+		Slots:       []TaskSchedule{},
+	}
+
+	var (
+		busy       []interval
+		candidates []*Task
+	)
+	planned := make(map[int]time.Time) // task ID → planned end
+
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Status == StatusDone || task.OccursAfter(plan.Date) {
+			continue
+		}
+
+		if task.IsEvent() && task.EventStart != nil {
+			start := task.EventStart.In(dayStart.Location())
+			if !sameDay(start, plan.Date) {
+				continue
+			}
+			end := start.Add(task.GetEventDuration())
+			busy = append(busy, interval{start, end})
+			planned[task.ID] = end
+			plan.PlannedCoins += task.MoneyCost
+			plan.Slots = append(plan.Slots, newSlot(plan.Date, task, start, end))
+			continue
+		}
+
+		if hasOpenSubtasks[task.ID] {
+			continue
+		}
+		candidates = append(candidates, task)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if (a.Deadline == nil) != (b.Deadline == nil) {
+			return a.Deadline != nil
+		}
+		if a.Deadline != nil && !a.Deadline.Equal(*b.Deadline) {
+			return a.Deadline.Before(*b.Deadline)
+		}
+		return a.UrgencyRank() < b.UrgencyRank()
+	})
+
+	// Place the best candidate whose prerequisites are satisfied, then start
+	// over so that tasks unlocked by it get their turn in priority order
+	for placed := true; placed; {
+		placed = false
+		for i, task := range candidates {
+			earliest, ready := prerequisitesEnd(task, planned, dayStart)
+			if !ready {
+				continue
+			}
+
+			candidates = append(candidates[:i:i], candidates[i+1:]...)
+			if plan.PlannedCoins+task.MoneyCost > budgetCoins {
+				plan.Unscheduled = append(plan.Unscheduled, UnscheduledTask{Task: *task, Reason: "over the daily budget"})
+				placed = true
+				break
+			}
+
+			duration := time.Duration(task.CorrectedDurationMins()) * time.Minute
+			if duration < 5*time.Minute {
+				duration = 5 * time.Minute
+			}
+			start, ok := findGap(busy, earliest, dayEnd, duration)
+			if !ok {
+				plan.Unscheduled = append(plan.Unscheduled, UnscheduledTask{Task: *task, Reason: "no free time slot left"})
+				placed = true
+				break
+			}
+
+			end := start.Add(duration)
+			busy = append(busy, interval{start, end})
+			planned[task.ID] = end
+			plan.PlannedCoins += task.MoneyCost
+			plan.Slots = append(plan.Slots, newSlot(plan.Date, task, start, end))
+			placed = true
+			break
+		}
+	}
+
+	for _, task := range candidates {
+		plan.Unscheduled = append(plan.Unscheduled, UnscheduledTask{Task: *task, Reason: "waiting on prerequisites"})
+	}
+
+	sort.SliceStable(plan.Slots, func(i, j int) bool {
+		return plan.Slots[i].StartTime.Before(*plan.Slots[j].StartTime)
+	})
+
+	return plan
+}
+
+// prerequisitesEnd returns when all prerequisites of a task are finished:
+// done ones count as finished already, planned ones at their planned end.
+// ready is false if some prerequisite is neither done nor planned.
+func prerequisitesEnd(task *Task, planned map[int]time.Time, dayStart time.Time) (earliest time.Time, ready bool) {
+	earliest = dayStart
+	for _, prereq := range task.Prerequisites {
+		if prereq.Status == StatusDone {
+			continue
+		}
+		end, ok := planned[prereq.ID]
+		if !ok {
+			return earliest, false
+		}
+		if end.After(earliest) {
+			earliest = end
+		}
+	}
+	return earliest, true
+}
+
+// findGap returns the earliest start at or after from where duration fits
+// before until without overlapping any busy interval
+func findGap(busy []interval, from, until time.Time, duration time.Duration) (time.Time, bool) {
+	sorted := append([]interval(nil), busy...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start.Before(sorted[j].start) })
+
+	start := from
+	for _, b := range sorted {
+		if !start.Add(duration).After(b.start) {
+			break
+		}
+		if b.end.After(start) {
+			start = b.end
+		}
+	}
+	if start.Add(duration).After(until) {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+// newSlot builds a schedule entry for a task on the plan's date
+func newSlot(date time.Time, task *Task, start, end time.Time) TaskSchedule {
+	return TaskSchedule{
+		TaskID:           task.ID,
+		ScheduledDate:    date,
+		StartTime:        &start,
+		EstimatedEndTime: &end,
+		Task:             task,
+	}
+}
+
+// sameDay reports whether t falls on the given date
+func sameDay(t, date time.Time) bool {
+	y1, m1, d1 := t.Date()
+	y2, m2, d2 := date.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildDayPlan(t *testing.T) {
+	day := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	clock := func(hour, min int) *time.Time {
+		at := day.Add(time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute)
+		return &at
+	}
+	task := func(id, priority, mins, cost int) Task {
+		return Task{ID: id, Title: fmt.Sprint("task ", id), Status: StatusPending, TaskType: TypeTask,
+			Priority: priority, EstimatedDurationMins: mins, MoneyCost: cost}
+	}
+	event := func(id, hour, mins int) Task {
+		t := task(id, 1, mins, mins)
+		t.TaskType = TypeMeeting
+		t.EventStart, t.EventEnd = clock(hour, 0), clock(hour, mins)
+		return t
+	}
+	with := func(t Task, change func(*Task)) Task {
+		change(&t)
+		return t
+	}
+	tomorrow := day.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name            string
+		budget          int
+		tasks           []Task
+		hasOpenSubtasks map[int]bool
+		slots           string // id@start of each slot, in order
+		unscheduled     string // id: reason of each task left out
+		coins           int
+	}{
+		{
+			name:   "events first, then tasks by priority around them",
+			budget: 1000,
+			tasks:  []Task{event(1, 9, 60), task(2, 1, 60, 10), task(3, 3, 60, 10)},
+			slots:  "3@08:00 1@09:00 2@10:00",
+			coins:  80,
+		},
+		{
+			name:   "earlier deadline first within a priority",
+			budget: 1000,
+			tasks: []Task{
+				task(1, 2, 30, 10),
+				with(task(2, 2, 30, 10), func(t *Task) { t.Deadline = clock(48, 0) }),
+				with(task(3, 2, 30, 10), func(t *Task) { t.Deadline = clock(24, 0) }),
+			},
+			slots: "3@08:00 2@08:30 1@09:00",
+			coins: 30,
+		},
+		{
+			name:   "prerequisites planned before their dependents",
+			budget: 1000,
+			tasks: []Task{
+				with(task(1, 3, 30, 10), func(t *Task) { t.Prerequisites = []Task{{ID: 2, Status: StatusPending}} }),
+				task(2, 1, 45, 10),
+				with(task(3, 2, 30, 10), func(t *Task) { t.Prerequisites = []Task{{ID: 9, Status: StatusDone}} }),
+			},
+			slots: "3@08:00 2@08:30 1@09:15",
+			coins: 30,
+		},
+		{
+			name:   "prerequisites not planned",
+			budget: 1000,
+			tasks: []Task{
+				with(task(1, 3, 30, 10), func(t *Task) { t.Prerequisites = []Task{{ID: 9, Status: StatusPending}} }),
+			},
+			unscheduled: "1: waiting on prerequisites",
+		},
+		{
+			name:        "over the budget",
+			budget:      50,
+			tasks:       []Task{task(1, 3, 30, 40), task(2, 2, 30, 30), task(3, 1, 30, 10)},
+			slots:       "1@08:00 3@08:30",
+			unscheduled: "2: over the daily budget",
+			coins:       50,
+		},
+		{
+			name:        "longer than the free time",
+			budget:      1000,
+			tasks:       []Task{event(1, 8, 180), task(2, 3, 90, 10), task(3, 1, 60, 10)},
+			slots:       "1@08:00 3@11:00",
+			unscheduled: "2: no free time slot left",
+			coins:       190,
+		},
+		{
+			name:   "short tasks take five minutes",
+			budget: 1000,
+			tasks:  []Task{task(1, 3, 1, 1), task(2, 2, 0, 0)},
+			slots:  "1@08:00 2@08:05",
+			coins:  1,
+		},
+		{
+			name:   "left out",
+			budget: 1000,
+			tasks: []Task{
+				with(task(1, 3, 30, 10), func(t *Task) { t.Status = StatusDone }),
+				task(2, 3, 30, 10),
+				with(event(3, 9, 60), func(t *Task) { start := tomorrow.Add(9 * time.Hour); t.EventStart = &start }),
+				with(task(4, 3, 30, 10), func(t *Task) { t.OccurrenceDate = &tomorrow }),
+				with(task(5, 1, 30, 10), func(t *Task) { t.OccurrenceDate = &day }),
+			},
+			hasOpenSubtasks: map[int]bool{2: true},
+			slots:           "5@08:00",
+			coins:           10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := BuildDayPlan(*clock(8, 0), *clock(12, 0), tt.budget, tt.tasks, tt.hasOpenSubtasks)
+
+			var slots, unscheduled []string
+			for _, slot := range plan.Slots {
+				slots = append(slots, fmt.Sprintf("%d@%s", slot.TaskID, slot.StartTime.Format("15:04")))
+			}
+			for _, u := range plan.Unscheduled {
+				unscheduled = append(unscheduled, fmt.Sprintf("%d: %s", u.Task.ID, u.Reason))
+			}
+			if got := strings.Join(slots, " "); got != tt.slots {
+				t.Errorf("slots = %q, want %q", got, tt.slots)
+			}
+			if got := strings.Join(unscheduled, ", "); got != tt.unscheduled {
+				t.Errorf("unscheduled = %q, want %q", got, tt.unscheduled)
+			}
+			if plan.PlannedCoins != tt.coins {
+				t.Errorf("planned coins = %d, want %d", plan.PlannedCoins, tt.coins)
+			}
+			if !plan.Date.Equal(day) {
+				t.Errorf("date = %v, want %v", plan.Date, day)
+			}
+		})
+	}
+}
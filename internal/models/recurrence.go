@@ -0,0 +1,307 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecurrenceFrequency is how often a recurring task comes back
+type RecurrenceFrequency string
+
+const (
+	RecurDaily           RecurrenceFrequency = "daily"
+	RecurWeekdays        RecurrenceFrequency = "weekdays"
+	RecurWeekly          RecurrenceFrequency = "weekly"
+	RecurMonthly         RecurrenceFrequency = "monthly"
+	RecurAfterCompletion RecurrenceFrequency = "after_completion"
+)
+
+// maxRecurrenceSearch bounds the days searched for the next occurrence
+const maxRecurrenceSearch = 5 * 366
+
+// Recurrence is the rule of a recurring task. It is stored on the task the
+// series started from, its template; every occurrence is a task of its own.
+type Recurrence struct {
+	Frequency  RecurrenceFrequency `json:"frequency"`
+	Interval   int                 `json:"interval,omitempty"`     // Every N days, weeks or months, or N days after completion
+	Weekdays   []time.Weekday      `json:"weekdays,omitempty"`     // Days of a weekly rule, 0 is Sunday
+	DayOfMonth int                 `json:"day_of_month,omitempty"` // Day of a monthly rule, -1 for the last day
+	Until      *time.Time          `json:"until,omitempty"`        // Last day an occurrence may fall on
+}
+
+// Validate checks that the rule is complete and fills in the default interval
+func (r *Recurrence) Validate() error {
+	if r.Interval == 0 {
+		r.Interval = 1
+	}
+	if r.Interval < 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	switch r.Frequency {
+	case RecurDaily, RecurWeekdays, RecurAfterCompletion:
+	case RecurWeekly:
+		if len(r.Weekdays) == 0 {
+			return fmt.Errorf("weekly recurrence needs at least one weekday")
+		}
+		for _, day := range r.Weekdays {
+			if day < time.Sunday || day > time.Saturday {
+				return fmt.Errorf("invalid weekday %d", day)
+			}
+		}
+	case RecurMonthly:
+		if r.DayOfMonth == 0 || r.DayOfMonth < -1 || r.DayOfMonth > 31 {
+			return fmt.Errorf("monthly recurrence needs a day of month from 1 to 31, or -1 for the last day")
+		}
+	default:
+		return fmt.Errorf("unknown recurrence frequency %q", r.Frequency)
+	}
+	return nil
+}
+
+// IsCalendar reports whether occurrences fall on fixed dates rather than
+// following the completion of the previous one
+func (r *Recurrence) IsCalendar() bool {
+	return r.Frequency != RecurAfterCompletion
+}
+
+// OccursOn reports whether a calendar rule anchored at the template's date
+// has an occurrence on date
+func (r *Recurrence) OccursOn(anchor, date time.Time) bool {
+	anchor, date = dateOnly(anchor), dateOnly(date)
+	if date.Before(anchor) || (r.Until != nil && date.After(dateOnly(*r.Until))) {
+		return false
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	switch r.Frequency {
+	case RecurDaily:
+		return daysBetween(anchor, date)%interval == 0
+	case RecurWeekdays:
+		return date.Weekday() != time.Saturday && date.Weekday() != time.Sunday
+	case RecurWeekly:
+		// Weeks start on Monday
+		weekStart := func(t time.Time) time.Time {
+			return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		}
+		if (daysBetween(weekStart(anchor), weekStart(date))/7)%interval != 0 {
+			return false
+		}
+		for _, day := range r.Weekdays {
+			if date.Weekday() == day {
+				return true
+			}
+		}
+		return false
+	case RecurMonthly:
+		months := (date.Year()-anchor.Year())*12 + int(date.Month()-anchor.Month())
+		if months%interval != 0 {
+			return false
+		}
+		// Days past the end of a short month fall on its last day
+		lastDay := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.Local).Day()
+		day := r.DayOfMonth
+		if day == -1 || day > lastDay {
+			day = lastDay
+		}
+		return date.Day() == day
+	}
+	return false
+}
+
+// NextOn returns the first occurrence of a calendar rule on or after from,
+// or nil if the series has ended
+func (r *Recurrence) NextOn(anchor, from time.Time) *time.Time {
+	day := dateOnly(from)
+	if a := dateOnly(anchor); day.Before(a) {
+		day = a
+	}
+	for i := 0; i < maxRecurrenceSearch; i++ {
+		if r.Until != nil && day.After(dateOnly(*r.Until)) {
+			return nil
+		}
+		if r.OccursOn(anchor, day) {
+			return &day
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return nil
+}
+
+// NextAfterCompletion returns the date of the occurrence following one
+// completed at completedAt, or nil if the series has ended
+func (r *Recurrence) NextAfterCompletion(completedAt time.Time) *time.Time {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	next := dateOnly(completedAt.Local()).AddDate(0, 0, interval)
+	if r.Until != nil && next.After(dateOnly(*r.Until)) {
+		return nil
+	}
+	return &next
+}
+
+// String describes the rule, e.g. "every 2 weeks on Mon, Thu"
+func (r *Recurrence) String() string {
+	every := func(unit string) string {
+		if r.Interval > 1 {
+			return fmt.Sprintf("every %d %ss", r.Interval, unit)
+		}
+		return "every " + unit
+	}
+
+	switch r.Frequency {
+	case RecurDaily:
+		return every("day")
+	case RecurWeekdays:
+		return "every weekday"
+	case RecurWeekly:
+		days := make([]string, len(r.Weekdays))
+		for i, day := range r.Weekdays {
+			days[i] = day.String()[:3]
+		}
+		return every("week") + " on " + strings.Join(days, ", ")
+	case RecurMonthly:
+		if r.DayOfMonth == -1 {
+			return every("month") + " on the last day"
+		}
+		return fmt.Sprintf("%s on day %d", every("month"), r.DayOfMonth)
+	case RecurAfterCompletion:
+		if r.Interval > 1 {
+			return fmt.Sprintf("%d days after completion", r.Interval)
+		}
+		return "1 day after completion"
+	}
+	return string(r.Frequency)
+}
+
+// ParseRecurrence parses a short rule such as "daily", "every 2 days",
+// "weekdays", "weekly:mon,thu", "monthly:15", "monthly:last" or "after:3",
+// as typed on the command line or in a form
+func ParseRecurrence(spec string) (*Recurrence, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	kind, arg, _ := strings.Cut(spec, ":")
+	r := &Recurrence{Interval: 1}
+
+	switch kind {
+	case "daily":
+		r.Frequency = RecurDaily
+	case "weekdays":
+		r.Frequency = RecurWeekdays
+	case "weekly":
+		r.Frequency = RecurWeekly
+		for _, name := range strings.Split(arg, ",") {
+			day, ok := weekdayNames[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("invalid weekday %q", name)
+			}
+			r.Weekdays = append(r.Weekdays, day)
+		}
+	case "monthly":
+		r.Frequency = RecurMonthly
+		if arg == "last" {
+			r.DayOfMonth = -1
+			break
+		}
+		day, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid day of month %q", arg)
+		}
+		r.DayOfMonth = day
+	case "after":
+		r.Frequency = RecurAfterCompletion
+		days, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number of days %q", arg)
+		}
+		r.Interval = days
+	default:
+		var n int
+		if _, err := fmt.Sscanf(spec, "every %d days", &n); err != nil {
+			return nil, fmt.Errorf("unknown recurrence %q", spec)
+		}
+		r.Frequency = RecurDaily
+		r.Interval = n
+	}
+
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Value implements the driver.Valuer interface for database storage
+func (r *Recurrence) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// dateOnly returns local midnight on the calendar day of t, so dates read
+// back from the database as UTC compare equal to local ones. Instants such as
+// completion times must be converted to local time first.
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// daysBetween counts the calendar days from a to b
+func daysBetween(a, b time.Time) int {
+	a = time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	b = time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// TaskSeries is a recurring task: the template holding the rule and the
+// occurrences created from it so far, oldest first
+type TaskSeries struct {
+	Template    Task         `json:"template"`
+	Occurrences []Task       `json:"occurrences"`
+	Streak      SeriesStreak `json:"streak"`
+}
+
+// SeriesStreak tracks how consistently a routine is kept up
+type SeriesStreak struct {
+	Current   int `json:"current"`   // Occurrences done in a row up to the latest due one
+	Best      int `json:"best"`      // Longest run of occurrences done in a row
+	Completed int `json:"completed"` // Occurrences done
+	Missed    int `json:"missed"`    // Occurrences whose day has passed without being done
+}
+
+// ComputeStreak counts the streaks of a series' occurrences, given oldest
+// first. Occurrences still to come neither extend nor break a streak.
+func ComputeStreak(occurrences []Task, today time.Time) SeriesStreak {
+	var streak SeriesStreak
+	today = dateOnly(today)
+	for _, t := range occurrences {
+		switch {
+		case t.Status == StatusDone:
+			streak.Completed++
+			streak.Current++
+			if streak.Current > streak.Best {
+				streak.Best = streak.Current
+			}
+		case t.OccurrenceDate != nil && dateOnly(*t.OccurrenceDate).Before(today):
+			streak.Missed++
+			streak.Current = 0
+		}
+	}
+	return streak
+}
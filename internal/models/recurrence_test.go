@@ -0,0 +1,139 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// date parses a YYYY-MM-DD day in local time
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		t.Fatalf("bad date %q: %v", s, err)
+	}
+	return d
+}
+
+func TestRecurrenceOccursOn(t *testing.T) {
+	until := time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		rule   Recurrence
+		anchor string
+		date   string
+		want   bool
+	}{
+		{"daily on the anchor", Recurrence{Frequency: RecurDaily, Interval: 1}, "2026-10-05", "2026-10-05", true},
+		{"daily before the anchor", Recurrence{Frequency: RecurDaily, Interval: 1}, "2026-10-05", "2026-10-04", false},
+		{"every other day on", Recurrence{Frequency: RecurDaily, Interval: 2}, "2026-10-05", "2026-10-07", true},
+		{"every other day off", Recurrence{Frequency: RecurDaily, Interval: 2}, "2026-10-05", "2026-10-06", false},
+		{"daily on until", Recurrence{Frequency: RecurDaily, Interval: 1, Until: &until}, "2026-10-05", "2026-10-10", true},
+		{"daily after until", Recurrence{Frequency: RecurDaily, Interval: 1, Until: &until}, "2026-10-05", "2026-10-11", false},
+		{"weekdays on Friday", Recurrence{Frequency: RecurWeekdays, Interval: 1}, "2026-10-05", "2026-10-09", true},
+		{"weekdays on Saturday", Recurrence{Frequency: RecurWeekdays, Interval: 1}, "2026-10-05", "2026-10-10", false},
+		{"fortnightly Thursday of the first week", Recurrence{Frequency: RecurWeekly, Interval: 2, Weekdays: []time.Weekday{time.Monday, time.Thursday}}, "2026-10-05", "2026-10-08", true},
+		{"fortnightly Thursday of the off week", Recurrence{Frequency: RecurWeekly, Interval: 2, Weekdays: []time.Weekday{time.Monday, time.Thursday}}, "2026-10-05", "2026-10-15", false},
+		{"fortnightly Monday of the next on week", Recurrence{Frequency: RecurWeekly, Interval: 2, Weekdays: []time.Weekday{time.Monday, time.Thursday}}, "2026-10-05", "2026-10-19", true},
+		{"weekly on another weekday", Recurrence{Frequency: RecurWeekly, Interval: 1, Weekdays: []time.Weekday{time.Monday}}, "2026-10-05", "2026-10-09", false},
+		{"monthly 31st in February", Recurrence{Frequency: RecurMonthly, Interval: 1, DayOfMonth: 31}, "2026-01-31", "2026-02-28", true},
+		{"monthly 31st in April", Recurrence{Frequency: RecurMonthly, Interval: 1, DayOfMonth: 31}, "2026-01-31", "2026-04-30", true},
+		{"monthly 31st the day before", Recurrence{Frequency: RecurMonthly, Interval: 1, DayOfMonth: 31}, "2026-01-31", "2026-04-29", false},
+		{"last day every other month off", Recurrence{Frequency: RecurMonthly, Interval: 2, DayOfMonth: -1}, "2026-01-31", "2026-02-28", false},
+		{"last day every other month on", Recurrence{Frequency: RecurMonthly, Interval: 2, DayOfMonth: -1}, "2026-01-31", "2026-03-31", true},
+		{"after completion never on the calendar", Recurrence{Frequency: RecurAfterCompletion, Interval: 1}, "2026-10-05", "2026-10-05", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.OccursOn(date(t, tt.anchor), date(t, tt.date)); got != tt.want {
+				t.Errorf("OccursOn(%s, %s) = %v, want %v", tt.anchor, tt.date, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecurrenceNextOn(t *testing.T) {
+	until := time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		rule   Recurrence
+		anchor string
+		from   string
+		want   string // Empty when the series has ended
+	}{
+		{"daily from before the anchor", Recurrence{Frequency: RecurDaily, Interval: 1}, "2026-10-05", "2026-10-01", "2026-10-05"},
+		{"daily from an occurrence", Recurrence{Frequency: RecurDaily, Interval: 3}, "2026-10-05", "2026-10-08", "2026-10-08"},
+		{"daily between occurrences", Recurrence{Frequency: RecurDaily, Interval: 3}, "2026-10-05", "2026-10-09", "2026-10-11"},
+		{"weekdays over the weekend", Recurrence{Frequency: RecurWeekdays, Interval: 1}, "2026-10-05", "2026-10-10", "2026-10-12"},
+		{"weekly to the next weekday", Recurrence{Frequency: RecurWeekly, Interval: 1, Weekdays: []time.Weekday{time.Monday, time.Thursday}}, "2026-10-05", "2026-10-06", "2026-10-08"},
+		{"fortnightly over the off week", Recurrence{Frequency: RecurWeekly, Interval: 2, Weekdays: []time.Weekday{time.Monday}}, "2026-10-05", "2026-10-06", "2026-10-19"},
+		{"monthly into a short month", Recurrence{Frequency: RecurMonthly, Interval: 1, DayOfMonth: 31}, "2026-01-31", "2026-02-01", "2026-02-28"},
+		{"on until", Recurrence{Frequency: RecurDaily, Interval: 1, Until: &until}, "2026-10-05", "2026-10-10", "2026-10-10"},
+		{"past until", Recurrence{Frequency: RecurDaily, Interval: 1, Until: &until}, "2026-10-05", "2026-10-11", ""},
+		{"next falls past until", Recurrence{Frequency: RecurDaily, Interval: 7, Until: &until}, "2026-10-05", "2026-10-06", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rule.NextOn(date(t, tt.anchor), date(t, tt.from))
+			switch {
+			case tt.want == "" && got != nil:
+				t.Errorf("NextOn(%s, %s) = %s, want nil", tt.anchor, tt.from, got.Format("2006-01-02"))
+			case tt.want != "" && got == nil:
+				t.Errorf("NextOn(%s, %s) = nil, want %s", tt.anchor, tt.from, tt.want)
+			case tt.want != "" && !got.Equal(date(t, tt.want)):
+				t.Errorf("NextOn(%s, %s) = %s, want %s", tt.anchor, tt.from, got.Format("2006-01-02"), tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeStreak(t *testing.T) {
+	today := time.Date(2026, 10, 10, 15, 0, 0, 0, time.Local)
+	occurrence := func(day string, status TaskStatus) Task {
+		d := date(t, day)
+		return Task{Status: status, OccurrenceDate: &d}
+	}
+	tests := []struct {
+		name        string
+		occurrences []Task
+		want        SeriesStreak
+	}{
+		{"none", nil, SeriesStreak{}},
+		{
+			"all done",
+			[]Task{occurrence("2026-10-08", StatusDone), occurrence("2026-10-09", StatusDone)},
+			SeriesStreak{Current: 2, Best: 2, Completed: 2},
+		},
+		{
+			"missed day breaks the streak",
+			[]Task{
+				occurrence("2026-10-05", StatusDone),
+				occurrence("2026-10-06", StatusDone),
+				occurrence("2026-10-07", StatusPending),
+				occurrence("2026-10-08", StatusDone),
+			},
+			SeriesStreak{Current: 1, Best: 2, Completed: 3, Missed: 1},
+		},
+		{
+			"today and later neither extend nor break it",
+			[]Task{
+				occurrence("2026-10-09", StatusDone),
+				occurrence("2026-10-10", StatusPending),
+				occurrence("2026-10-11", StatusPending),
+			},
+			SeriesStreak{Current: 1, Best: 1, Completed: 1},
+		},
+		{
+			"done ahead of its day counts",
+			[]Task{occurrence("2026-10-09", StatusDone), occurrence("2026-10-12", StatusDone)},
+			SeriesStreak{Current: 2, Best: 2, Completed: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputeStreak(tt.occurrences, today); got != tt.want {
+				t.Errorf("ComputeStreak() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
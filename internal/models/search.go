@@ -0,0 +1,24 @@
+package models
+
+// SearchHit is a task, contact or contact thread matching a search
+type SearchHit struct {
+	Type      string  `json:"type"` // task, contact, thread
+	ID        int     `json:"id"`
+	Title     string  `json:"title"`
+	Snippet   string  `json:"snippet"`              // HTML-escaped text around the match, matches wrapped in <mark>
+	ContactID *int    `json:"contact_id,omitempty"` // Contact a thread belongs to
+	Score     float64 `json:"score"`                // Lower is a better match
+}
+
+// SearchResults holds the hits of a search grouped by type, best first
+type SearchResults struct {
+	Query    string      `json:"query"`
+	Tasks    []SearchHit `json:"tasks"`
+	Contacts []SearchHit `json:"contacts"`
+	Threads  []SearchHit `json:"threads"`
+}
+
+// Count returns the number of hits of all types
+func (r *SearchResults) Count() int {
+	return len(r.Tasks) + len(r.Contacts) + len(r.Threads)
+}
@@ -0,0 +1,23 @@
+package models
+
+// Setting is a user-configurable value stored in the settings table
+type Setting struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Default     string `json:"default"`
+	Description string `json:"description"`
+}
+
+// CostMultipliers scale the coin cost of demanding tasks
+type CostMultipliers struct {
+	HighEnergy float64 `json:"high_energy"`
+	Hard       float64 `json:"hard"`
+}
+
+// DefaultCostMultipliers are used until the settings say otherwise
+var DefaultCostMultipliers = CostMultipliers{HighEnergy: 1.5, Hard: 1.3}
+
+// BudgetOverrideRequest sets or, with a null total, clears the budget of a single date
+type BudgetOverrideRequest struct {
+	TotalBudgetCoins *int `json:"total_budget_coins"`
+}
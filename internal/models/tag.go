@@ -0,0 +1,98 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxTagLength bounds the length of a tag name in characters
+const maxTagLength = 40
+
+// tagColorPattern matches the hex colors tags can have, e.g. #4f46e5
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Tag labels tasks. Names are unique regardless of case.
+type Tag struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"` // Hex color such as #4f46e5
+	Emoji     string    `json:"emoji,omitempty"`
+	TaskCount int       `json:"task_count,omitempty"` // Tasks with the tag, left out on the tags of a task
+	OpenCount int       `json:"open_count,omitempty"` // Of those, tasks not done yet
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagRequest creates a tag or changes one. On update only the fields sent
+// change; changing the name renames the tag on every task.
+type TagRequest struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+	Emoji *string `json:"emoji"`
+}
+
+// Validate normalizes the fields that were sent and checks them
+func (r *TagRequest) Validate() error {
+	if r.Name != nil {
+		name, err := NormalizeTagName(*r.Name)
+		if err != nil {
+			return err
+		}
+		r.Name = &name
+	}
+	if r.Color != nil {
+		color := strings.ToLower(strings.TrimSpace(*r.Color))
+		if color != "" && !tagColorPattern.MatchString(color) {
+			return fmt.Errorf("invalid color %q, expected a hex color like #4f46e5", *r.Color)
+		}
+		r.Color = &color
+	}
+	if r.Emoji != nil {
+		emoji := strings.TrimSpace(*r.Emoji)
+		if utf8.RuneCountInString(emoji) > 8 {
+			return fmt.Errorf("emoji %q is too long", emoji)
+		}
+		r.Emoji = &emoji
+	}
+	return nil
+}
+
+// TagMergeRequest moves every task of a tag over to another one
+type TagMergeRequest struct {
+	Into int `json:"into"` // ID of the tag that is kept
+}
+
+// NormalizeTagName trims a tag name and checks that it can be used in the
+// comma-separated lists of filters
+func NormalizeTagName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	switch {
+	case name == "":
+		return "", fmt.Errorf("tag name is required")
+	case strings.Contains(name, ","):
+		return "", fmt.Errorf("tag name %q cannot contain a comma", name)
+	case utf8.RuneCountInString(name) > maxTagLength:
+		return "", fmt.Errorf("tag name %q is longer than %d characters", name, maxTagLength)
+	}
+	return name, nil
+}
+
+// NormalizeTags normalizes tag names and drops repeated ones, ignoring case
+// and keeping the first spelling
+func NormalizeTags(names []string) (Tags, error) {
+	tags := Tags{}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name, err := NormalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"strings"
 	"time"
 )
 
@@ -18,6 +19,15 @@ const (
 	StatusBlocked    TaskStatus = "blocked"
 )
 
+// Valid reports whether the status is one of the known task statuses
+func (s TaskStatus) Valid() bool {
+	switch s {
+	case StatusPending, StatusInProgress, StatusDone, StatusBlocked:
+		return true
+	}
+	return false
+}
+
 // TaskType represents the type of task/event
 type TaskType string
 
@@ -29,6 +39,15 @@ const (
 	TypeMeeting     TaskType = "meeting"
 )
 
+// Valid reports whether the type is one of the known task types
+func (t TaskType) Valid() bool {
+	switch t {
+	case TypeTask, TypeAppointment, TypeEvent, TypeConcert, TypeMeeting:
+		return true
+	}
+	return false
+}
+
 // Task represents a task in our ADHD-friendly system
 type Task struct {
 	ID                     int       `json:"id" db:"id"`
@@ -52,12 +71,28 @@ type Task struct {
 	CreatedAt              time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt              time.Time `json:"updated_at" db:"updated_at"`
 	CompletedAt            *time.Time `json:"completed_at" db:"completed_at"`
+	Recurrence             *Recurrence `json:"recurrence,omitempty" db:"recurrence"`           // Set on the template of a recurring series
+	SeriesID               *int       `json:"series_id,omitempty" db:"series_id"`             // Template this occurrence was created from
+	OccurrenceDate         *time.Time `json:"occurrence_date,omitempty" db:"occurrence_date"` // Day this occurrence of a series is for
 	
 	// Computed fields
 	Subtasks      []Task      `json:"subtasks,omitempty"`
 	Prerequisites []Task      `json:"prerequisites,omitempty"`
 	Contacts      []Contact   `json:"contacts,omitempty"`
+	TagDetails    []Tag       `json:"tag_details,omitempty"` // Tags with their color and emoji, in the order of Tags
 	Attachments   []Attachment `json:"attachments,omitempty"`
+	StatusHistory []StatusChange `json:"status_history,omitempty"`
+	Rollup        *TaskRollup    `json:"rollup,omitempty"`
+	Calibration   *EstimateCalibration `json:"calibration,omitempty"`
+}
+
+// TaskRollup aggregates the leaves of a task's subtree
+type TaskRollup struct {
+	RemainingMins   int     `json:"remaining_minutes"`
+	TotalMoneyCost  int     `json:"total_money_cost"`
+	Leaves          int     `json:"leaves"`
+	DoneLeaves      int     `json:"done_leaves"`
+	ProgressPercent float64 `json:"progress_percent"`
 }
 
 // Tags represents a list of task tags
@@ -94,6 +129,7 @@ type DailyBudget struct {
 	Date            time.Time `json:"date" db:"date"`
 	TotalBudgetCoins int      `json:"total_budget_coins" db:"total_budget_coins"`
 	SpentCoins      int       `json:"spent_coins" db:"spent_coins"`
+	IsOverride      bool      `json:"is_override" db:"-"`
 	CreatedAt       time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -103,6 +139,25 @@ func (db *DailyBudget) RemainingCoins() int {
 	return db.TotalBudgetCoins - db.SpentCoins
 }
 
+// BudgetTransaction is an entry in the coin ledger of a day
+type BudgetTransaction struct {
+	ID        int       `json:"id" db:"id"`
+	Date      time.Time `json:"date" db:"date"`
+	TaskID    *int      `json:"task_id" db:"task_id"`
+	TaskTitle string    `json:"task_title,omitempty"`
+	Amount    int       `json:"amount" db:"amount"` // Coins spent, negative for refunds
+	Kind      string    `json:"kind" db:"kind"`     // completion, progress, adjustment
+	Note      string    `json:"note" db:"note"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// BudgetAdjustmentRequest represents a manual entry in the coin ledger
+type BudgetAdjustmentRequest struct {
+	TaskID *int   `json:"task_id"`
+	Amount int    `json:"amount"`
+	Note   string `json:"note"`
+}
+
 // TaskSchedule represents when a task is scheduled
 type TaskSchedule struct {
 	ID               int        `json:"id" db:"id"`
@@ -118,6 +173,27 @@ type TaskSchedule struct {
 	Task *Task `json:"task,omitempty"`
 }
 
+// ActualMinutes returns the tracked minutes of the entry, counting an open entry up to now
+func (s *TaskSchedule) ActualMinutes() int {
+	if s.ActualStartTime == nil {
+		return 0
+	}
+	end := time.Now()
+	if s.ActualEndTime != nil {
+		end = *s.ActualEndTime
+	}
+	return int(end.Sub(*s.ActualStartTime).Minutes())
+}
+
+// TimeReport compares a task's estimate with the time actually tracked on it
+type TimeReport struct {
+	TaskID           int            `json:"task_id"`
+	EstimatedMinutes int            `json:"estimated_minutes"`
+	ActualMinutes    int            `json:"actual_minutes"`
+	Running          bool           `json:"running"`
+	Entries          []TaskSchedule `json:"entries"`
+}
+
 // TaskPrerequisite represents a prerequisite relationship
 type TaskPrerequisite struct {
 	ID                 int       `json:"id" db:"id"`
@@ -126,6 +202,31 @@ type TaskPrerequisite struct {
 	CreatedAt          time.Time `json:"created_at" db:"created_at"`
 }
 
+// StatusChange records a status transition of a task and, for automatic
+// blocking/unblocking, the prerequisite change that caused it
+type StatusChange struct {
+	ID          int        `json:"id" db:"id"`
+	TaskID      int        `json:"task_id" db:"task_id"`
+	TaskTitle   string     `json:"task_title"`
+	OldStatus   TaskStatus `json:"old_status" db:"old_status"`
+	NewStatus   TaskStatus `json:"new_status" db:"new_status"`
+	CauseTaskID *int       `json:"cause_task_id" db:"cause_task_id"`
+	Reason      string     `json:"reason" db:"reason"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Message describes the change, e.g. "Meal Prep unblocked because Grocery Shopping was finished"
+func (c *StatusChange) Message() string {
+	if c.Reason == "" {
+		return fmt.Sprintf("%s moved from %s to %s", c.TaskTitle, c.OldStatus, c.NewStatus)
+	}
+	verb := "blocked"
+	if c.OldStatus == StatusBlocked {
+		verb = "unblocked"
+	}
+	return fmt.Sprintf("%s %s because %s", c.TaskTitle, verb, c.Reason)
+}
+
 // CreateTaskRequest represents the request to create a new task
 type CreateTaskRequest struct {
 	Title                 string     `json:"title"`
@@ -141,6 +242,168 @@ type CreateTaskRequest struct {
 	EventLocation         string     `json:"event_location"`
 	EventStart            *time.Time `json:"event_start"`
 	EventEnd              *time.Time `json:"event_end"`
+	ApplyCalibration      bool       `json:"apply_calibration"` // Replace the estimate with the calibrated one
+	Recurrence            *Recurrence `json:"recurrence"`       // Makes the task the template of a recurring series
+}
+
+// UpdateTaskRequest represents a partial task update; nil fields are left unchanged
+type UpdateTaskRequest struct {
+	Title                 *string     `json:"title"`
+	Description           *string     `json:"description"`
+	ParentID              *int        `json:"parent_id"` // 0 moves the task to the top level
+	EstimatedDurationMins *int        `json:"estimated_duration_minutes"`
+	Deadline              *time.Time  `json:"deadline"`
+	Priority              *int        `json:"priority"`
+	Status                *TaskStatus `json:"status"`
+	Tags                  *[]string   `json:"tags"`
+	EnergyLevel           *int        `json:"energy_level"`
+	Difficulty            *int        `json:"difficulty"`
+	TaskType              *TaskType   `json:"task_type"`
+	EventLocation         *string     `json:"event_location"`
+	EventStart            *time.Time  `json:"event_start"`
+	EventEnd              *time.Time  `json:"event_end"`
+	Clear                 []string    `json:"clear"` // Dates to remove: deadline, event_start or event_end
+}
+
+// Validate checks the status and the dates to clear
+func (r *UpdateTaskRequest) Validate() error {
+	if r.Status != nil && !r.Status.Valid() {
+		return fmt.Errorf("unknown status %q", *r.Status)
+	}
+	for _, field := range r.Clear {
+		var set bool
+		switch field {
+		case "deadline":
+			set = r.Deadline != nil
+		case "event_start":
+			set = r.EventStart != nil
+		case "event_end":
+			set = r.EventEnd != nil
+		default:
+			return fmt.Errorf("cannot clear %q, expected deadline, event_start or event_end", field)
+		}
+		if set {
+			return fmt.Errorf("%s is both set and cleared", field)
+		}
+	}
+	return nil
+}
+
+// Apply copies the set fields of the request onto the task. Status is not
+// applied here since status changes have side effects handled by the database.
+func (r *UpdateTaskRequest) Apply(t *Task) {
+	if r.Title != nil {
+		t.Title = *r.Title
+	}
+	if r.Description != nil {
+		t.Description = *r.Description
+	}
+	if r.ParentID != nil {
+		if *r.ParentID == 0 {
+			t.ParentID = nil
+		} else {
+			parentID := *r.ParentID
+			t.ParentID = &parentID
+		}
+	}
+	if r.EstimatedDurationMins != nil {
+		t.EstimatedDurationMins = *r.EstimatedDurationMins
+	}
+	if r.Deadline != nil {
+		t.Deadline = r.Deadline
+	}
+	if r.Priority != nil {
+		t.Priority = *r.Priority
+	}
+	if r.Tags != nil {
+		t.Tags = Tags(*r.Tags)
+	}
+	if r.EnergyLevel != nil {
+		t.EnergyLevel = *r.EnergyLevel
+	}
+	if r.Difficulty != nil {
+		t.Difficulty = *r.Difficulty
+	}
+	if r.TaskType != nil {
+		t.TaskType = *r.TaskType
+	}
+	if r.EventLocation != nil {
+		t.EventLocation = *r.EventLocation
+	}
+	if r.EventStart != nil {
+		t.EventStart = r.EventStart
+	}
+	if r.EventEnd != nil {
+		t.EventEnd = r.EventEnd
+	}
+	for _, field := range r.Clear {
+		switch field {
+		case "deadline":
+			t.Deadline = nil
+		case "event_start":
+			t.EventStart = nil
+		case "event_end":
+			t.EventEnd = nil
+		}
+	}
+}
+
+// Validate fills in what a new task left out, medium priority, energy and
+// difficulty and the plain task type, and checks the request
+func (r *CreateTaskRequest) Validate() error {
+	for _, level := range []*int{&r.Priority, &r.EnergyLevel, &r.Difficulty} {
+		if *level == 0 {
+			*level = 2
+		}
+	}
+	if r.TaskType == "" {
+		r.TaskType = TypeTask
+	}
+	return validateTask(r.Title, r.Priority, r.EnergyLevel, r.Difficulty, r.TaskType)
+}
+
+// Validate checks the editable fields of a task before it is saved
+func (t *Task) Validate() error {
+	return validateTask(t.Title, t.Priority, t.EnergyLevel, t.Difficulty, t.TaskType)
+}
+
+// validateTask checks the fields every task needs: a title, priority, energy
+// level and difficulty on the 1-3 scale, and a known type
+func validateTask(title string, priority, energy, difficulty int, taskType TaskType) error {
+	if strings.TrimSpace(title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	levels := []struct {
+		name  string
+		value int
+	}{{"priority", priority}, {"energy_level", energy}, {"difficulty", difficulty}}
+	for _, level := range levels {
+		if level.value < 1 || level.value > 3 {
+			return fmt.Errorf("%s must be between 1 and 3, got %d", level.name, level.value)
+		}
+	}
+	if !taskType.Valid() {
+		return fmt.Errorf("unknown task type %q", taskType)
+	}
+	return nil
+}
+
+// ApplyTo overwrites every editable field of the task with the request values,
+// used for full replacement (PUT) where omitted fields are cleared
+func (r *CreateTaskRequest) ApplyTo(t *Task) {
+	t.Title = r.Title
+	t.Description = r.Description
+	t.ParentID = r.ParentID
+	t.EstimatedDurationMins = r.EstimatedDurationMins
+	t.Deadline = r.Deadline
+	t.Priority = r.Priority
+	t.Tags = Tags(r.Tags)
+	t.EnergyLevel = r.EnergyLevel
+	t.Difficulty = r.Difficulty
+	t.TaskType = r.TaskType
+	t.EventLocation = r.EventLocation
+	t.EventStart = r.EventStart
+	t.EventEnd = r.EventEnd
 }
 
 // Contact represents a person or organization
@@ -154,20 +417,26 @@ type Contact struct {
 	AvatarURL string    `json:"avatar_url" db:"avatar_url"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// Role on the task, when loaded as one of its contacts
+	Role string `json:"role,omitempty" db:"role"`
 }
 
 // ContactThread represents a communication thread with a contact
 type ContactThread struct {
-	ID         int       `json:"id" db:"id"`
-	ContactID  int       `json:"contact_id" db:"contact_id"`
-	TaskID     *int      `json:"task_id" db:"task_id"`
-	Subject    string    `json:"subject" db:"subject"`
-	Message    string    `json:"message" db:"message"`
-	ThreadType string    `json:"thread_type" db:"thread_type"` // message, email, call, meeting
-	Direction  string    `json:"direction" db:"direction"`     // inbound, outbound
-	Status     string    `json:"status" db:"status"`           // sent, received, pending, failed
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	
+	ID         int        `json:"id" db:"id"`
+	ContactID  int        `json:"contact_id" db:"contact_id"`
+	TaskID     *int       `json:"task_id" db:"task_id"`
+	Subject    string     `json:"subject" db:"subject"`
+	Message    string     `json:"message" db:"message"`
+	ThreadType ThreadType `json:"thread_type" db:"thread_type"` // message, email, call, meeting
+	Direction  string     `json:"direction" db:"direction"`     // inbound, outbound
+	Status     string     `json:"status" db:"status"`           // sent, received, pending, failed
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+
+	// Title of the linked task, if any
+	TaskTitle string `json:"task_title,omitempty"`
+
 	// Associated contact
 	Contact *Contact `json:"contact,omitempty"`
 }
@@ -187,6 +456,28 @@ type Attachment struct {
 	CreatedAt        time.Time `json:"created_at" db:"created_at"`
 }
 
+// Attachment types
+const (
+	AttachmentDocument = "document"
+	AttachmentImage    = "image"
+	AttachmentAudio    = "audio"
+	AttachmentVideo    = "video"
+	AttachmentLink     = "link"
+)
+
+// AttachmentTypeFor returns the attachment type of a file of the given MIME type
+func AttachmentTypeFor(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return AttachmentImage
+	case strings.HasPrefix(mimeType, "audio/"):
+		return AttachmentAudio
+	case strings.HasPrefix(mimeType, "video/"):
+		return AttachmentVideo
+	}
+	return AttachmentDocument
+}
+
 // TaskContact represents the relationship between a task and contact
 type TaskContact struct {
 	ID        int       `json:"id" db:"id"`
@@ -196,9 +487,40 @@ type TaskContact struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
-// CalculateMoneyCost calculates the "cost" of a task in our money allegory
-func (t *Task) CalculateMoneyCost() int {
-	baseCost := t.EstimatedDurationMins
+// Calibrate attaches the suggested estimate correction for the task. When the
+// stored estimate has already been corrected, the suggestion is marked applied
+// so the correction is not counted twice.
+func (t *Task) Calibrate(c *Calibration, alreadyApplied bool) {
+	t.Calibration = c.For(t)
+	if t.Calibration != nil && alreadyApplied {
+		t.Calibration.Applied = true
+		t.Calibration.SuggestedDurationMins = t.EstimatedDurationMins
+	}
+}
+
+// ApplyCalibration replaces the estimate with the suggested one, reporting whether it changed
+func (t *Task) ApplyCalibration() bool {
+	if t.Calibration == nil || t.Calibration.Applied {
+		return false
+	}
+	t.EstimatedDurationMins = t.Calibration.SuggestedDurationMins
+	t.Calibration.Applied = true
+	return true
+}
+
+// CorrectedDurationMins returns the calibrated estimate when one is suggested, else the raw estimate
+func (t *Task) CorrectedDurationMins() int {
+	if t.Calibration != nil {
+		return t.Calibration.SuggestedDurationMins
+	}
+	return t.EstimatedDurationMins
+}
+
+// CalculateMoneyCost calculates the "cost" of a task in our money allegory,
+// based on the calibrated duration so habitual under-estimates cost what they really take.
+// The multipliers for high-energy and hard tasks come from the settings.
+func (t *Task) CalculateMoneyCost(multipliers CostMultipliers) int {
+	baseCost := t.CorrectedDurationMins()
 	
 	// Apply energy multiplier
 	energyMultiplier := 1.0
@@ -208,7 +530,7 @@ func (t *Task) CalculateMoneyCost() int {
 	case 2: // Medium energy
 		energyMultiplier = 1.0
 	case 3: // High energy
-		energyMultiplier = 1.5
+		energyMultiplier = multipliers.HighEnergy
 	}
 	
 	// Apply difficulty multiplier
@@ -219,7 +541,7 @@ func (t *Task) CalculateMoneyCost() int {
 	case 2: // Medium
 		difficultyMultiplier = 1.0
 	case 3: // Hard
-		difficultyMultiplier = 1.3
+		difficultyMultiplier = multipliers.Hard
 	}
 	
 	// Apply priority multiplier (higher priority costs more to reflect urgency)
@@ -263,6 +585,51 @@ func (t *Task) GetUrgencyColor() string {
 	return "normal"
 }
 
+// UrgencyRank orders the GetUrgencyColor buckets from most to least urgent (lower is more urgent)
+func (t *Task) UrgencyRank() int {
+	switch t.GetUrgencyColor() {
+	case "overdue":
+		return 0
+	case "urgent":
+		return 1
+	case "soon":
+		return 2
+	case "high-priority":
+		return 3
+	default:
+		return 4
+	}
+}
+
+// ComputeRollup fills in Rollup for the task and all its loaded subtasks.
+// Leaves count with their own duration and cost; a parent's own estimate is
+// ignored once it has subtasks, since the children describe the real work.
+func (t *Task) ComputeRollup() TaskRollup {
+	var rollup TaskRollup
+	if len(t.Subtasks) == 0 {
+		rollup.Leaves = 1
+		rollup.TotalMoneyCost = t.MoneyCost
+		if t.Status == StatusDone {
+			rollup.DoneLeaves = 1
+		} else {
+			rollup.RemainingMins = t.EstimatedDurationMins
+		}
+	}
+	for i := range t.Subtasks {
+		child := t.Subtasks[i].ComputeRollup()
+		rollup.RemainingMins += child.RemainingMins
+		rollup.TotalMoneyCost += child.TotalMoneyCost
+		rollup.Leaves += child.Leaves
+		rollup.DoneLeaves += child.DoneLeaves
+	}
+	if rollup.Leaves > 0 {
+		rollup.ProgressPercent = float64(rollup.DoneLeaves) / float64(rollup.Leaves) * 100
+	}
+
+	t.Rollup = &rollup
+	return rollup
+}
+
 // IsBlocked checks if a task is blocked by incomplete prerequisites
 func (t *Task) IsBlocked() bool {
 	for _, prereq := range t.Prerequisites {
@@ -273,6 +640,24 @@ func (t *Task) IsBlocked() bool {
 	return false
 }
 
+// SeriesTemplateID returns the ID of the template of the series the task
+// belongs to, or 0 if it does not recur
+func (t *Task) SeriesTemplateID() int {
+	if t.SeriesID != nil {
+		return *t.SeriesID
+	}
+	if t.Recurrence != nil {
+		return t.ID
+	}
+	return 0
+}
+
+// OccursAfter reports whether the task is the occurrence of a series for a
+// later day than the given one, and so not to be done yet
+func (t *Task) OccursAfter(day time.Time) bool {
+	return t.OccurrenceDate != nil && daysBetween(day, *t.OccurrenceDate) > 0
+}
+
 // IsEvent returns true if this is an event-type task
 func (t *Task) IsEvent() bool {
 	return t.TaskType == TypeAppointment || t.TaskType == TypeEvent || 
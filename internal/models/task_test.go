@@ -0,0 +1,61 @@
+package models
+
+import "testing"
+
+// leaf is a task without subtasks
+func leaf(status TaskStatus, mins, cost int) Task {
+	return Task{Status: status, EstimatedDurationMins: mins, MoneyCost: cost}
+}
+
+func TestComputeRollup(t *testing.T) {
+	tests := []struct {
+		name string
+		task Task
+		want TaskRollup
+	}{
+		{
+			name: "open leaf",
+			task: leaf(StatusPending, 30, 45),
+			want: TaskRollup{RemainingMins: 30, TotalMoneyCost: 45, Leaves: 1},
+		},
+		{
+			name: "done leaf",
+			task: leaf(StatusDone, 30, 45),
+			want: TaskRollup{TotalMoneyCost: 45, Leaves: 1, DoneLeaves: 1, ProgressPercent: 100},
+		},
+		{
+			name: "parent estimate ignored",
+			task: Task{
+				Status:                StatusPending,
+				EstimatedDurationMins: 500,
+				MoneyCost:             500,
+				Subtasks:              []Task{leaf(StatusDone, 20, 20), leaf(StatusPending, 40, 60)},
+			},
+			want: TaskRollup{RemainingMins: 40, TotalMoneyCost: 80, Leaves: 2, DoneLeaves: 1, ProgressPercent: 50},
+		},
+		{
+			name: "nested subtasks count their leaves",
+			task: Task{Subtasks: []Task{
+				leaf(StatusDone, 10, 10),
+				{Subtasks: []Task{leaf(StatusDone, 10, 10), leaf(StatusInProgress, 15, 20), leaf(StatusBlocked, 5, 5)}},
+			}},
+			want: TaskRollup{RemainingMins: 20, TotalMoneyCost: 45, Leaves: 4, DoneLeaves: 2, ProgressPercent: 50},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.task.ComputeRollup()
+			if got != tt.want {
+				t.Errorf("ComputeRollup() = %+v, want %+v", got, tt.want)
+			}
+			if tt.task.Rollup == nil || *tt.task.Rollup != got {
+				t.Errorf("Rollup = %+v, want it set to the result", tt.task.Rollup)
+			}
+			for _, sub := range tt.task.Subtasks {
+				if sub.Rollup == nil {
+					t.Errorf("subtask rollup not filled in")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+// Package storage keeps uploaded files on local disk, addressed by the
+// SHA-256 of their content so the same file is only ever stored once
+package storage
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTooLarge is returned for files over the size limit of the store
+	ErrTooLarge = errors.New("file too large")
+	// ErrEmpty is returned for files without content
+	ErrEmpty = errors.New("file is empty")
+)
+
+// uploadPrefix starts the names of files still being written
+const uploadPrefix = ".upload-"
+
+// pruneGrace is how long Remove and Prune leave files alone after they were
+// written, so uploads not yet recorded by their caller survive
+const pruneGrace = time.Hour
+
+// Store is a directory of files named after the hash of their content
+type Store struct {
+	root    string
+	maxSize int64
+
+	// mu orders storing a file against removing it, so a file is never
+	// removed between an upload finding it already there and touching it
+	mu sync.Mutex
+}
+
+// Object is a file kept in a store
+type Object struct {
+	Key      string // Hex SHA-256 of the content
+	Path     string // Where the file lives, relative to the root of the store
+	Size     int64
+	MimeType string
+}
+
+// New opens the store under root, creating the directory if needed. Files
+// over maxSize bytes are refused.
+func New(root string, maxSize int64) (*Store, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("size limit must be positive")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &Store{root: root, maxSize: maxSize}, nil
+}
+
+// MaxSize is the size limit of the store in bytes
+func (s *Store) MaxSize() int64 {
+	return s.maxSize
+}
+
+// relPath is where the file of a key lives relative to the root, fanned out
+// over directories named after the first two digits of the key
+func relPath(key string) string {
+	return filepath.Join(key[:2], key)
+}
+
+// validKey reports whether key looks like a key of this store, so nothing
+// else under the root is ever opened or removed
+func validKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil && strings.ToLower(key) == key
+}
+
+// Put stores the content of r. The name the file was uploaded under only
+// helps tell its MIME type when its content does not.
+func (s *Store) Put(r io.Reader, name string) (*Object, error) {
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	mimeType := DetectType(head, name)
+
+	tmp, err := os.CreateTemp(s.root, uploadPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(br, s.maxSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	case size > s.maxSize:
+		return nil, fmt.Errorf("%w: the limit is %d bytes", ErrTooLarge, s.maxSize)
+	case size == 0:
+		return nil, ErrEmpty
+	}
+
+	key := hex.EncodeToString(hash.Sum(nil))
+	obj := &Object{Key: key, Path: relPath(key), Size: size, MimeType: mimeType}
+	dest := filepath.Join(s.root, obj.Path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := os.Stat(dest); err == nil {
+		now := time.Now()
+		if err := os.Chtimes(dest, now, now); err != nil {
+			return nil, fmt.Errorf("failed to store file: %w", err)
+		}
+		return obj, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return nil, fmt.Errorf("failed to store file: %w", err)
+	}
+	return obj, nil
+}
+
+// Open opens the file of a key for reading
+func (s *Store) Open(key string) (*os.File, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("invalid storage key %q", key)
+	}
+	return os.Open(filepath.Join(s.root, relPath(key)))
+}
+
+// Remove deletes the file of a key unless it was written or stored again
+// within the last hour, when an upload may be about to record it; Prune
+// removes it later. A file already gone is not an error.
+func (s *Store) Remove(key string) error {
+	if !validKey(key) {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	if _, err := s.removeIdle(filepath.Join(s.root, relPath(key))); err != nil {
+		return fmt.Errorf("failed to remove file: %w", err)
+	}
+	return nil
+}
+
+// removeIdle removes the file at path if it is untouched for pruneGrace,
+// reporting whether it did
+func (s *Store) removeIdle(path string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if time.Since(info.ModTime()) < pruneGrace {
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return true, nil
+}
+
+// Prune removes the files whose key inUse does not know, along with
+// abandoned uploads, once they are untouched for an hour. It returns how
+// many files it removed.
+func (s *Store) Prune(inUse func(key string) bool) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(s.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		if !strings.HasPrefix(name, uploadPrefix) {
+			if !validKey(name) || path != filepath.Join(s.root, relPath(name)) || inUse(name) {
+				return nil
+			}
+		}
+		ok, err := s.removeIdle(path)
+		if ok {
+			removed++
+		}
+		return err
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to prune storage: %w", err)
+	}
+	return removed, nil
+}
+
+// DetectType works out the MIME type of a file from its first bytes, going
+// by the extension of its name when the content alone says little
+func DetectType(head []byte, name string) string {
+	sniffed := http.DetectContentType(head)
+	if sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/plain") {
+		if byName := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); byName != "" {
+			return byName
+		}
+	}
+	return sniffed
+}
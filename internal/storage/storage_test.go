@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keyOf is the key a store gives content
+func keyOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFile creates a file under the store root, aged by age
+func writeFile(t *testing.T, root, rel string, age time.Duration) string {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(rel), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Now().Add(-age)
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPrune(t *testing.T) {
+	used, unused, fresh := keyOf("used"), keyOf("unused"), keyOf("fresh")
+	tests := []struct {
+		name    string
+		rel     string
+		age     time.Duration
+		removed bool
+	}{
+		{"attached file", relPath(used), 2 * pruneGrace, false},
+		{"unused file", relPath(unused), 2 * pruneGrace, true},
+		{"unused file within the grace period", relPath(fresh), pruneGrace / 2, false},
+		{"abandoned upload", uploadPrefix + "123", 2 * pruneGrace, true},
+		{"upload in progress", uploadPrefix + "456", time.Minute, false},
+		{"file the store does not own", "notes.txt", 2 * pruneGrace, false},
+		{"key outside its directory", filepath.Join("00", unused), 2 * pruneGrace, false},
+	}
+
+	root := t.TempDir()
+	store, err := New(root, 1024)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	paths := make([]string, len(tests))
+	want := 0
+	for i, tt := range tests {
+		paths[i] = writeFile(t, root, tt.rel, tt.age)
+		if tt.removed {
+			want++
+		}
+	}
+
+	removed, err := store.Prune(func(key string) bool { return key == used })
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if removed != want {
+		t.Errorf("Prune() removed %d files, want %d", removed, want)
+	}
+	for i, tt := range tests {
+		_, err := os.Stat(paths[i])
+		if gone := errors.Is(err, os.ErrNotExist); gone != tt.removed {
+			t.Errorf("%s: removed = %v, want %v", tt.name, gone, tt.removed)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name    string
+		age     time.Duration
+		exists  bool
+		removed bool
+	}{
+		{"idle file", 2 * pruneGrace, true, true},
+		{"file stored within the grace period", time.Minute, true, false},
+		{"missing file", 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			store, err := New(root, 1024)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			key := keyOf(tt.name)
+			path := filepath.Join(root, relPath(key))
+			if tt.exists {
+				writeFile(t, root, relPath(key), tt.age)
+			}
+
+			if err := store.Remove(key); err != nil {
+				t.Fatalf("Remove: %v", err)
+			}
+			_, err = os.Stat(path)
+			if gone := errors.Is(err, os.ErrNotExist); tt.exists && gone != tt.removed {
+				t.Errorf("removed = %v, want %v", gone, tt.removed)
+			}
+		})
+	}
+
+	store, err := New(t.TempDir(), 1024)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, key := range []string{"", "../etc/passwd", strings.ToUpper(keyOf("x"))} {
+		if err := store.Remove(key); err == nil {
+			t.Errorf("Remove(%q) accepted an invalid key", key)
+		}
+	}
+}
+
+func TestPut(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		filename string
+		mimeType string
+		err      error
+	}{
+		{"text", "hello, world", "notes.txt", "text/plain; charset=utf-8", nil},
+		{"type from content", "%PDF-1.4\n", "scan", "application/pdf", nil},
+		{"type from name", `{"a": 1}`, "data.json", "application/json", nil},
+		{"empty", "", "empty.txt", "", ErrEmpty},
+		{"over the limit", strings.Repeat("x", 17), "big.txt", "", ErrTooLarge},
+		{"at the limit", strings.Repeat("x", 16), "limit.txt", "text/plain; charset=utf-8", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			store, err := New(root, 16)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			obj, err := store.Put(strings.NewReader(tt.content), tt.filename)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("Put() error = %v, want %v", err, tt.err)
+				}
+				entries, _ := os.ReadDir(root)
+				if len(entries) != 0 {
+					t.Errorf("Put() left %d entries behind", len(entries))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if obj.Key != keyOf(tt.content) || obj.Size != int64(len(tt.content)) || obj.MimeType != tt.mimeType {
+				t.Errorf("Put() = %+v, want key %s, size %d, type %q", *obj, keyOf(tt.content), len(tt.content), tt.mimeType)
+			}
+
+			f, err := store.Open(obj.Key)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer f.Close()
+			if data, _ := io.ReadAll(f); string(data) != tt.content {
+				t.Errorf("stored %q, want %q", data, tt.content)
+			}
+		})
+	}
+}
+
+func TestPutStoresIdenticalFilesOnce(t *testing.T) {
+	root := t.TempDir()
+	store, err := New(root, 1024)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	first, err := store.Put(strings.NewReader("same"), "a.txt")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	path := filepath.Join(root, first.Path)
+	old := time.Now().Add(-2 * pruneGrace)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := store.Put(strings.NewReader("same"), "b.txt")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if second.Key != first.Key || second.Path != first.Path {
+		t.Errorf("second upload stored as %s, want %s", second.Path, first.Path)
+	}
+	// Storing the file again renews it, so it is safe from removal until recorded
+	if info, err := os.Stat(path); err != nil || time.Since(info.ModTime()) >= pruneGrace {
+		t.Errorf("file not renewed by the second upload: %v", err)
+	}
+	if err := store.Remove(first.Key); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file renewed by an upload was removed: %v", err)
+	}
+}
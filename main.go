@@ -1,27 +1,125 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"oppgaave/internal/database"
 	"oppgaave/internal/handlers"
+	"oppgaave/internal/storage"
 
 	"github.com/gorilla/mux"
 )
 
+const usage = `usage: oppgaave <command> [arguments]
+
+Commands:
+  serve [--port N] [--attachments DIR] [--max-upload-mb N]
+                                   start the web server (default)
+  task add <title> [flags]         add a task
+  task list [flags]                list tasks, filtered by --status, --type or --tag
+  task done <id>                   mark a task as done
+  next [--energy N] [--minutes N]  suggest what to do next
+  budget [today|tomorrow|DATE]     show the coin budget of a day
+  export [--format json|ics] [--output FILE]
+                                   export all tasks, or events and deadlines as iCalendar
+  import ics <file> [--days N]     create and update tasks from an iCalendar file
+  migrate status|up|down [steps]   manage schema migrations
+  seed --demo                      add demo data to a new database
+
+Most commands accept --json for machine-readable output.`
+
 func main() {
-	// Initialize database
 	dbPath := getEnv("DATABASE_PATH", "./tasks.db")
+
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	var err error
+	switch args[0] {
+	case "serve":
+		err = runServe(dbPath, args[1:])
+	case "task":
+		err = runTask(dbPath, args[1:])
+	case "next":
+		err = runNext(dbPath, args[1:])
+	case "budget":
+		err = runBudget(dbPath, args[1:])
+	case "export":
+		err = runExport(dbPath, args[1:])
+	case "import":
+		err = runImport(dbPath, args[1:])
+	case "migrate":
+		err = runMigrate(dbPath, args[1:])
+	case "seed":
+		err = runSeed(dbPath, args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+	default:
+		err = fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// runServe starts the web server
+func runServe(dbPath string, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	port := fs.String("port", getEnv("PORT", "8080"), "port to listen on")
+	attachmentsPath := fs.String("attachments", getEnv("ATTACHMENTS_PATH", "./attachments"), "directory to store attachments in")
+	maxUploadMB := fs.Int("max-upload-mb", 25, "largest attachment accepted, in megabytes")
+	if value := os.Getenv("MAX_UPLOAD_MB"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("MAX_UPLOAD_MB must be a whole number")
+		}
+		*maxUploadMB = n
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files, err := storage.New(*attachmentsPath, int64(*maxUploadMB)<<20)
+	if err != nil {
+		return fmt.Errorf("failed to open attachment storage: %w", err)
+	}
+
 	db, err := database.New(dbPath)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer db.Close()
 
+	// Without FTS5 the search index is never created and search only answers
+	// 503, so refuse to serve a build that cannot search at all
+	if ok, err := db.SearchAvailable(); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("this build of SQLite has no FTS5, which search needs; build with -tags sqlite_fts5")
+	}
+
 	// Initialize handlers
-	h := handlers.New(db)
+	h := handlers.New(db, files)
+
+	// Housekeeping runs now and every hour: follow-ups come due while nobody
+	// looks, and files of attachments deleted along with their task or
+	// contact need sweeping up
+	go func() {
+		for {
+			generateFollowUps(db)
+			pruneAttachmentFiles(db, files)
+			time.Sleep(time.Hour)
+		}
+	}()
 
 	// Setup routes
 	r := mux.NewRouter()
@@ -35,29 +133,124 @@ func main() {
 	// HTMX endpoints for dynamic content
 	r.HandleFunc("/tasks", h.GetTaskList).Methods("GET")
 	r.HandleFunc("/tasks/radar", h.GetTaskRadar).Methods("GET")
+	r.HandleFunc("/tasks/next", h.GetNextTasks).Methods("GET")
 	r.HandleFunc("/tasks/create", h.CreateTask).Methods("GET", "POST")
+	r.HandleFunc("/tasks/bulk", h.BulkTaskList).Methods("POST")
 	r.HandleFunc("/tasks/{id}/status", h.UpdateTaskStatus).Methods("POST")
 	r.HandleFunc("/tasks/{id}/details", h.GetTaskDetails).Methods("GET")
+	r.HandleFunc("/tasks/{id:[0-9]+}/contacts", h.GetTaskContacts).Methods("GET")
+	r.HandleFunc("/tasks/{id:[0-9]+}/contacts", h.LinkTaskContact).Methods("POST")
+	r.HandleFunc("/tasks/{id:[0-9]+}/contacts/{contactId:[0-9]+}", h.UnlinkTaskContact).Methods("DELETE")
+	r.HandleFunc("/tasks/{id:[0-9]+}/attachments", h.GetTaskAttachments).Methods("GET")
+	r.HandleFunc("/tasks/{id:[0-9]+}/attachments", h.UploadTaskAttachment).Methods("POST")
+	r.HandleFunc("/tasks/{id:[0-9]+}/attachments/{attachmentId:[0-9]+}", h.DeleteTaskAttachment).Methods("DELETE")
+	r.HandleFunc("/attachments/{id:[0-9]+}", h.DownloadAttachment).Methods("GET")
 	r.HandleFunc("/budget-widget", h.GetBudgetWidget).Methods("GET")
+	r.HandleFunc("/plan/{date}", h.GetPlanView).Methods("GET")
+	r.HandleFunc("/plan/{date}", h.GeneratePlanView).Methods("POST")
+	r.HandleFunc("/settings", h.SettingsPage).Methods("GET", "POST")
+	r.HandleFunc("/calendar.ics", h.CalendarFeed).Methods("GET")
+	r.HandleFunc("/search", h.Search).Methods("GET")
 	
 	// Contact management endpoints
 	r.HandleFunc("/contacts", h.GetContacts).Methods("GET")
 	r.HandleFunc("/contacts/create", h.CreateContact).Methods("GET", "POST")
+	r.HandleFunc("/contacts/{id:[0-9]+}", h.DeleteContact).Methods("DELETE")
+	r.HandleFunc("/contacts/{id:[0-9]+}/edit", h.EditContact).Methods("GET", "POST")
+	r.HandleFunc("/contacts/{id:[0-9]+}/tasks", h.GetContactTasks).Methods("GET")
 	r.HandleFunc("/contacts/{id}/threads", h.GetContactThreads).Methods("GET")
+	r.HandleFunc("/contacts/{id:[0-9]+}/threads/{threadId:[0-9]+}/status", h.UpdateThreadStatus).Methods("POST")
 	r.HandleFunc("/contacts/{id}/message", h.CreateMessage).Methods("GET", "POST")
+	r.HandleFunc("/follow-ups", h.GetWaitingOn).Methods("GET")
 
 	// JSON API endpoints
 	api := r.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/tasks", h.GetTasksAPI).Methods("GET")
 	api.HandleFunc("/tasks", h.CreateTaskAPI).Methods("POST")
+	api.HandleFunc("/tasks/next", h.GetNextTasksAPI).Methods("GET")
+	api.HandleFunc("/tasks/bulk", h.BulkTasksAPI).Methods("POST")
+	api.HandleFunc("/search", h.SearchAPI).Methods("GET")
+	api.HandleFunc("/tags", h.GetTagsAPI).Methods("GET")
+	api.HandleFunc("/tags", h.CreateTagAPI).Methods("POST")
+	api.HandleFunc("/tags/{id:[0-9]+}", h.GetTagAPI).Methods("GET")
+	api.HandleFunc("/tags/{id:[0-9]+}", h.UpdateTagAPI).Methods("PATCH")
+	api.HandleFunc("/tags/{id:[0-9]+}", h.DeleteTagAPI).Methods("DELETE")
+	api.HandleFunc("/tags/{id:[0-9]+}/merge", h.MergeTagAPI).Methods("POST")
+	api.HandleFunc("/contacts", h.GetContactsAPI).Methods("GET")
+	api.HandleFunc("/contacts", h.CreateContactAPI).Methods("POST")
+	api.HandleFunc("/contacts/{id:[0-9]+}", h.GetContactAPI).Methods("GET")
+	api.HandleFunc("/contacts/{id:[0-9]+}", h.UpdateContactAPI).Methods("PATCH")
+	api.HandleFunc("/contacts/{id:[0-9]+}", h.DeleteContactAPI).Methods("DELETE")
+	api.HandleFunc("/contacts/{id:[0-9]+}/threads", h.GetContactThreadsAPI).Methods("GET")
+	api.HandleFunc("/contacts/{id:[0-9]+}/threads", h.CreateContactThreadAPI).Methods("POST")
+	api.HandleFunc("/contacts/{id:[0-9]+}/threads/{threadId:[0-9]+}", h.UpdateContactThreadAPI).Methods("PATCH")
+	api.HandleFunc("/contacts/{id:[0-9]+}/tasks", h.GetContactTasksAPI).Methods("GET")
+	api.HandleFunc("/contacts/{id:[0-9]+}/attachments", h.GetContactAttachmentsAPI).Methods("GET")
+	api.HandleFunc("/contacts/{id:[0-9]+}/attachments", h.UploadContactAttachmentAPI).Methods("POST")
+	api.HandleFunc("/attachments/{id:[0-9]+}", h.DeleteAttachmentAPI).Methods("DELETE")
+	api.HandleFunc("/follow-ups", h.GetWaitingOnAPI).Methods("GET")
+	api.HandleFunc("/follow-ups", h.GenerateFollowUpsAPI).Methods("POST")
+	api.HandleFunc("/calibration", h.GetCalibrationAPI).Methods("GET")
+	api.HandleFunc("/plan/{date}", h.GetPlanAPI).Methods("GET")
+	api.HandleFunc("/plan/{date}", h.GeneratePlanAPI).Methods("POST")
+	api.HandleFunc("/settings", h.GetSettingsAPI).Methods("GET")
+	api.HandleFunc("/settings", h.UpdateSettingsAPI).Methods("PUT")
+	api.HandleFunc("/import/ics", h.ImportCalendarAPI).Methods("POST")
+	api.HandleFunc("/budget/{date}", h.GetBudgetAPI).Methods("GET")
+	api.HandleFunc("/budget/{date}", h.SetBudgetAPI).Methods("PUT")
+	api.HandleFunc("/budget/{date}/transactions", h.GetBudgetTransactionsAPI).Methods("GET")
+	api.HandleFunc("/budget/{date}/transactions", h.CreateBudgetAdjustmentAPI).Methods("POST")
+	api.HandleFunc("/tasks/{id:[0-9]+}", h.GetTaskAPI).Methods("GET")
+	api.HandleFunc("/tasks/{id:[0-9]+}", h.UpdateTaskAPI).Methods("PUT", "PATCH")
+	api.HandleFunc("/tasks/{id:[0-9]+}", h.DeleteTaskAPI).Methods("DELETE")
+	api.HandleFunc("/tasks/{id:[0-9]+}/tree", h.GetTaskTreeAPI).Methods("GET")
+	api.HandleFunc("/tasks/{id:[0-9]+}/time-entries", h.GetTimeEntriesAPI).Methods("GET")
+	api.HandleFunc("/tasks/{id:[0-9]+}/history", h.GetStatusHistoryAPI).Methods("GET")
+	api.HandleFunc("/tasks/{id:[0-9]+}/series", h.GetSeriesAPI).Methods("GET")
+	api.HandleFunc("/tasks/{id:[0-9]+}/recurrence", h.SetRecurrenceAPI).Methods("PUT", "DELETE")
+	api.HandleFunc("/tasks/{id:[0-9]+}/contacts", h.GetTaskContactsAPI).Methods("GET")
+	api.HandleFunc("/tasks/{id:[0-9]+}/attachments", h.GetTaskAttachmentsAPI).Methods("GET")
+	api.HandleFunc("/tasks/{id:[0-9]+}/attachments", h.UploadTaskAttachmentAPI).Methods("POST")
+	api.HandleFunc("/tasks/{id:[0-9]+}/contacts/{contactId:[0-9]+}", h.LinkContactAPI).Methods("POST")
+	api.HandleFunc("/tasks/{id:[0-9]+}/contacts/{contactId:[0-9]+}", h.UnlinkContactAPI).Methods("DELETE")
+	api.HandleFunc("/tasks/{id:[0-9]+}/prerequisites/{prereqId:[0-9]+}", h.AddPrerequisiteAPI).Methods("POST")
+	api.HandleFunc("/tasks/{id:[0-9]+}/prerequisites/{prereqId:[0-9]+}", h.RemovePrerequisiteAPI).Methods("DELETE")
+
+	log.Printf("🚀 ADHD Task Manager starting on port %s", *port)
+	log.Printf("📊 Dashboard: http://localhost:%s", *port)
+	log.Printf("🔧 API: http://localhost:%s/api/tasks", *port)
+
+	if err := http.ListenAndServe(":"+*port, r); err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	return nil
+}
 
-	port := getEnv("PORT", "8080")
-	log.Printf("🚀 ADHD Task Manager starting on port %s", port)
-	log.Printf("📊 Dashboard: http://localhost:%s", port)
-	log.Printf("🔧 API: http://localhost:%s/api/tasks", port)
+// generateFollowUps creates the follow-up tasks that have come due
+func generateFollowUps(db *database.DB) {
+	tasks, err := db.GenerateFollowUps(time.Now())
+	if err != nil {
+		log.Printf("Error generating follow-ups: %v", err)
+		return
+	}
+	if len(tasks) > 0 {
+		log.Printf("⏳ Created %d follow-up tasks", len(tasks))
+	}
+}
 
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// pruneAttachmentFiles removes the stored files no attachment has any more
+func pruneAttachmentFiles(db *database.DB, files *storage.Store) {
+	inUse, err := db.AttachmentFiles()
+	if err != nil {
+		log.Printf("Error pruning attachments: %v", err)
+		return
+	}
+	removed, err := files.Prune(func(key string) bool { return inUse[key] })
+	if err != nil {
+		log.Printf("Error pruning attachments: %v", err)
+	}
+	if removed > 0 {
+		log.Printf("🧹 Removed %d unused attachment files", removed)
 	}
 }
 
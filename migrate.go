@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"oppgaave/internal/database"
+)
+
+const migrateUsage = "usage: oppgaave migrate status | up | down [steps]"
+
+// runMigrate handles the migrate subcommand: status lists the migrations,
+// up applies the pending ones and down rolls back the latest ones
+func runMigrate(dbPath string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATUS\tAPPLIED AT\tNAME")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state = "applied"
+				appliedAt = s.AppliedAt.Local().Format("2006-01-02 15:04")
+			}
+			switch {
+			case s.Unknown:
+				state = "unknown"
+			case s.Modified:
+				state = "modified"
+			case s.Unavailable:
+				state = "unavailable"
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, state, appliedAt, s.Name)
+		}
+		return tw.Flush()
+
+	case "up":
+		applied, err := db.MigrateUp()
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+		for _, m := range applied {
+			fmt.Printf("Applied %d: %s\n", m.Version, m.Name)
+		}
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive number\n%s", migrateUsage)
+			}
+		}
+		reverted, err := db.MigrateDown(steps)
+		for _, m := range reverted {
+			fmt.Printf("Rolled back %d: %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("No migrations to roll back")
+		}
+		return err
+	}
+
+	return errors.New(migrateUsage)
+}
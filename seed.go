@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+const seedUsage = "usage: oppgaave seed --demo"
+
+// runSeed handles the seed subcommand, which fills a new database with demo data
+func runSeed(dbPath string, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	demo := fs.Bool("demo", false, "add demo tasks and contacts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*demo {
+		return errors.New(seedUsage)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	seeded, err := db.SeedDemoData()
+	if err != nil {
+		return err
+	}
+	if seeded {
+		fmt.Println("Added demo tasks and contacts")
+	} else {
+		fmt.Println("Database already has tasks or contacts, demo data not added")
+	}
+	return nil
+}